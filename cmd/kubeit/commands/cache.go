@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/komailo/kubeit/internal/logger"
+	"github.com/komailo/kubeit/pkg/cache"
+	"github.com/komailo/kubeit/pkg/generate"
+)
+
+// Options specific to the cache command
+var cacheSetOptions generate.Options
+
+var cachePruneOlderThan time.Duration
+
+// CacheCmd is the base sub command to manage the chart cache
+var CacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the cache of pulled Helm charts",
+	Long:  ``,
+}
+
+var cacheListCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the cached Helm charts",
+	Args:  cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		chartCache, err := cache.Open(cacheSetOptions.CacheDir)
+		if err != nil {
+			return err
+		}
+
+		entries, err := chartCache.List()
+		if err != nil {
+			return err
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "REPOSITORY\tNAME\tVERSION\tDIGEST\tSIZE\tLAST USED")
+
+		for _, entry := range entries {
+			fmt.Fprintf(
+				writer,
+				"%s\t%s\t%s\t%s\t%d\t%s\n",
+				entry.Repository,
+				entry.Name,
+				entry.Version,
+				entry.Digest[:12],
+				entry.Size,
+				entry.LastUsed.Format(time.DateTime),
+			)
+		}
+
+		return writer.Flush()
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove cached Helm charts",
+	Long: `Remove the cached Helm charts that were not used within --older-than, or every
+cached chart when --older-than is not set.`,
+	Args: cobra.NoArgs,
+	RunE: func(_ *cobra.Command, _ []string) error {
+		chartCache, err := cache.Open(cacheSetOptions.CacheDir)
+		if err != nil {
+			return err
+		}
+
+		pruned, err := chartCache.Prune(cachePruneOlderThan)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range pruned {
+			logger.Infof("Removed %s from the chart cache", entry.Key)
+		}
+
+		fmt.Printf("Removed %d charts from %s\n", len(pruned), chartCache.Dir())
+
+		return nil
+	},
+}
+
+var cacheWarmCmd = &cobra.Command{
+	Use:   "warm [source-config-uri]",
+	Short: "Pull the Helm charts of a Kubeit configuration into the cache",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		cacheSetOptions.SourceConfigURI = args[0]
+
+		warmErrs, loadFileErrs := generate.WarmCache(&cacheSetOptions)
+
+		errorMap := make(map[string][]string) // Map to store errors per file
+		if len(loadFileErrs) != 0 {
+			for file, errList := range loadFileErrs {
+				for _, err := range errList {
+					errorMap[file] = append(errorMap[file], fmt.Sprintf("- %v", err))
+				}
+			}
+		}
+
+		for _, err := range warmErrs {
+			errorMap["Cache Errors"] = append(
+				errorMap["Cache Errors"],
+				fmt.Sprintf("- %v", err),
+			)
+		}
+
+		// If there are errors, format them nicely
+		if len(errorMap) > 0 {
+			var formattedErrors []string
+			for file, errList := range errorMap {
+				formattedErrors = append(formattedErrors,
+					fmt.Sprintf("%s:\n  %s", file, strings.Join(errList, "\n  ")))
+			}
+
+			return logger.RedactError(fmt.Errorf("\n%s", strings.Join(formattedErrors, "\n")))
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	// Register subcommands
+	CacheCmd.AddCommand(cacheListCmd)
+	CacheCmd.AddCommand(cachePruneCmd)
+	CacheCmd.AddCommand(cacheWarmCmd)
+
+	CacheCmd.PersistentFlags().StringVar(
+		&cacheSetOptions.CacheDir,
+		"cache-dir",
+		"",
+		"Directory of the chart cache. Defaults to $"+cache.DirEnv+" or the kubeit directory in the user cache directory.",
+	)
+
+	cachePruneCmd.Flags().DurationVar(
+		&cachePruneOlderThan,
+		"older-than",
+		0,
+		"Only remove charts that were not used for this long, e.g. 720h",
+	)
+}
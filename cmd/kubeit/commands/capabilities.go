@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/komailo/kubeit/internal/logger"
+	"github.com/komailo/kubeit/pkg/generate"
+)
+
+var (
+	capabilitiesName       string
+	capabilitiesOutputFile string
+)
+
+// CapabilitiesCmd is the base sub command to manage capability profiles
+var CapabilitiesCmd = &cobra.Command{
+	Use:   "capabilities",
+	Short: "Manage the Kubernetes capability profiles charts are rendered against",
+	Long:  ``,
+}
+
+var capabilitiesExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the capabilities of the current cluster",
+	Long: `Export the Kubernetes version and API versions of the cluster of the current
+kubeconfig context as a Capabilities profile. The profile can be passed to
+generate with --capabilities-file, or added to a Kubeit configuration and
+selected by NamedValues with spec.capabilitiesRef.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		data, err := generate.ExportCapabilities(capabilitiesName)
+		if err != nil {
+			return logger.RedactError(err)
+		}
+
+		if capabilitiesOutputFile == "" {
+			_, err := cmd.OutOrStdout().Write(data)
+			return err
+		}
+
+		if err := os.WriteFile(capabilitiesOutputFile, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write capabilities file: %w", err)
+		}
+
+		logger.Infof("Exported capabilities to %s", capabilitiesOutputFile)
+
+		return nil
+	},
+}
+
+func init() {
+	CapabilitiesCmd.AddCommand(capabilitiesExportCmd)
+
+	capabilitiesExportCmd.Flags().StringVar(
+		&capabilitiesName,
+		"name",
+		"cluster",
+		"Name of the exported Capabilities profile.",
+	)
+
+	capabilitiesExportCmd.Flags().StringVarP(
+		&capabilitiesOutputFile,
+		"output",
+		"o",
+		"",
+		"File to write the profile to instead of the standard output.",
+	)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/komailo/kubeit/internal/logger"
+	"github.com/komailo/kubeit/pkg/cache"
 	"github.com/komailo/kubeit/pkg/generate"
 )
 
@@ -34,13 +35,17 @@ var GenerateCmd = &cobra.Command{
 		}
 
 		// Create the work directory
-		if err := os.MkdirAll(workDir, os.ModePerm); err != nil {
+		if err := os.MkdirAll(workDir, 0o755); err != nil {
 			logger.Fatalf("Failed to create work directory: %v", err)
 		}
 		logger.Debugf("Work directory created at: %s", workDir)
 
-		// Create the output directory
-		if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		// Create the output directory, nothing is written to it when streaming to stdout
+		if generateSetOptions.OutputLayout == generate.OutputLayoutStdout {
+			return
+		}
+
+		if err := os.MkdirAll(outputDir, 0o755); err != nil {
 			logger.Fatalf("Failed to create output directory: %v", err)
 		}
 	},
@@ -53,8 +58,8 @@ var GenerateCmd = &cobra.Command{
 		logger.Debugf("Work directory deleted: %s", workDir)
 
 		// If there was an error in RunE, return it now
-		if err, ok := cmd.Context().Value("cmdError").(error); ok && err != nil {
-			return err // Return the stored error after cleanup
+		if err, ok := cmd.Context().Value(cmdErrorKey).(error); ok && err != nil {
+			return logger.RedactError(err) // Return the stored error after cleanup
 		}
 		return nil
 	},
@@ -65,6 +70,7 @@ func init() {
 	GenerateCmd.AddCommand(GenerateManifestCmd)
 	GenerateCmd.AddCommand(generateCliDocsCmd)
 	GenerateCmd.AddCommand(GenerateDockerLabelsCmd)
+	GenerateCmd.AddCommand(GenerateValuesCmd)
 
 	// Bind the output-dir flag to generateOpts
 	GenerateCmd.PersistentFlags().StringVarP(
@@ -83,10 +89,59 @@ func init() {
 		"Working directory where temporary artifacts and results will be stored.",
 	)
 
+	GenerateCmd.PersistentFlags().StringVar(
+		&generateSetOptions.AgeKeyFile,
+		"age-key-file",
+		"",
+		"File with the age identities used to decrypt encrypted values. Defaults to the identity in $"+generate.AgeKeyEnv+" or the file in $"+generate.AgeKeyFileEnv+".",
+	)
+
 	GenerateCmd.PersistentFlags().StringVar(
 		&generateSetOptions.KubeVersion,
 		"kube-version",
 		"",
 		"Kubernetes server version where the generated artifacts will be deployed.",
 	)
+
+	GenerateCmd.PersistentFlags().StringArrayVar(
+		&generateSetOptions.APIVersions,
+		"api-versions",
+		nil,
+		"Kubernetes API version, e.g. monitoring.coreos.com/v1, available for .Capabilities.APIVersions, can be provided multiple times.",
+	)
+
+	GenerateCmd.PersistentFlags().StringVar(
+		&generateSetOptions.CapabilitiesFile,
+		"capabilities-file",
+		"",
+		"Capabilities profile of the cluster, exported with kubeit capabilities export. Takes precedence over the profile of the NamedValues, --kube-version and --api-versions take precedence over it.",
+	)
+
+	GenerateCmd.PersistentFlags().StringVar(
+		&generateSetOptions.ClusterStateDir,
+		"cluster-state",
+		"",
+		"Directory of Kubernetes object files that Helm lookup calls return while rendering, instead of nothing.",
+	)
+
+	GenerateCmd.PersistentFlags().StringArrayVar(
+		&generateSetOptions.Variables,
+		"var",
+		nil,
+		"Variable in the form name=value that can be referenced in values, can be provided multiple times. Takes precedence over the generated variables.",
+	)
+
+	GenerateCmd.PersistentFlags().StringVar(
+		&generateSetOptions.CacheDir,
+		"cache-dir",
+		"",
+		"Directory of the chart cache. Defaults to $"+cache.DirEnv+" or the kubeit directory in the user cache directory.",
+	)
+
+	GenerateCmd.PersistentFlags().BoolVar(
+		&generateSetOptions.Offline,
+		"offline",
+		false,
+		"Only use charts from the chart cache and fail instead of pulling charts that are not cached.",
+	)
 }
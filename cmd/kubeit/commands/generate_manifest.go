@@ -59,4 +59,46 @@ func init() {
 		nil,
 		"Name of the NamedValues to use while generating manifests, multiple names can be provided by using args multiple times and they will be used in the order they are provided",
 	)
+
+	GenerateManifestCmd.PersistentFlags().IntVar(
+		&generateSetOptions.Parallelism,
+		"parallelism",
+		generate.DefaultParallelism,
+		"Number of applications to render at the same time.",
+	)
+
+	GenerateManifestCmd.PersistentFlags().BoolVar(
+		&generateSetOptions.ContinueOnError,
+		"continue-on-error",
+		false,
+		"Render every application that can be rendered instead of stopping at the first failure, write the ones that succeeded and print a summary of the applications that failed. Without it nothing is written when an application fails.",
+	)
+
+	GenerateManifestCmd.PersistentFlags().StringVar(
+		&generateSetOptions.OutputLayout,
+		"output-layout",
+		generate.OutputLayoutPerApplication,
+		"How the manifests are written, one of "+strings.Join(generate.OutputLayouts, ", ")+". single writes manifests.yaml, per-application writes <app>.yaml, per-object writes <app>/<kind>/<name>.yaml, json writes manifests.json as a List, stdout writes a YAML stream to the standard output and tar writes the per-application layout to manifests.tar.",
+	)
+
+	GenerateManifestCmd.PersistentFlags().BoolVar(
+		&generateSetOptions.Kustomization,
+		"kustomization",
+		false,
+		"Also write a kustomization.yaml with the written manifests as its resources, for the directory and tar output layouts.",
+	)
+
+	GenerateManifestCmd.PersistentFlags().BoolVar(
+		&generateSetOptions.Clean,
+		"clean",
+		false,
+		"Remove the contents of the output directory before writing the manifests. Without it nothing is written when the output directory has files that would not be overwritten, such as the manifests of removed objects.",
+	)
+
+	GenerateManifestCmd.PersistentFlags().BoolVar(
+		&generateSetOptions.UpdateLock,
+		"update-lock",
+		false,
+		"Resolve the charts again instead of using the versions in the lock file, and write the resolved versions to the lock file.",
+	)
 }
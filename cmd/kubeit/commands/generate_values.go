@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/komailo/kubeit/internal/logger"
+	"github.com/komailo/kubeit/pkg/generate"
+)
+
+var GenerateValuesCmd = &cobra.Command{
+	Use:   "values [source-config-uri]",
+	Short: "Generate the merged Helm values of each application from a Kubeit configuration",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		generateSetOptions.SourceConfigURI = args[0]
+
+		generateErrs, loadFileErrs := generate.Values(&generateSetOptions)
+
+		errorMap := make(map[string][]string) // Map to store errors per file
+		if len(loadFileErrs) != 0 {
+			for file, errList := range loadFileErrs {
+				for _, err := range errList {
+					errorMap[file] = append(errorMap[file], fmt.Sprintf("- %v", err))
+				}
+			}
+		}
+
+		for _, err := range generateErrs {
+			errorMap["Generate Errors"] = append(
+				errorMap["Generate Errors"],
+				fmt.Sprintf("- %v", err),
+			)
+		}
+
+		// If there are errors, format them nicely
+		if len(errorMap) > 0 {
+			var formattedErrors []string
+			for file, errList := range errorMap {
+				formattedErrors = append(formattedErrors,
+					fmt.Sprintf("%s:\n  %s", file, strings.Join(errList, "\n  ")))
+			}
+			finalErr := fmt.Errorf("\n%s", strings.Join(formattedErrors, "\n"))
+			cmd.SetContext(context.WithValue(cmd.Context(), cmdErrorKey, finalErr))
+			logger.Errorf("Error generating values: %v", finalErr)
+
+		}
+	},
+}
+
+func init() {
+	GenerateValuesCmd.PersistentFlags().StringArrayVarP(
+		&generateSetOptions.NamedValues,
+		"named-values",
+		"e",
+		nil,
+		"Name of the NamedValues to use while generating values, multiple names can be provided by using args multiple times and they will be used in the order they are provided",
+	)
+
+	GenerateValuesCmd.PersistentFlags().StringVar(
+		&generateSetOptions.ValuesFormat,
+		"format",
+		generate.ValuesFormatYAML,
+		"Format of the generated values files, yaml or json.",
+	)
+
+	GenerateValuesCmd.PersistentFlags().BoolVar(
+		&generateSetOptions.RevealSensitive,
+		"reveal-sensitive",
+		false,
+		"Write the values of sensitive and encrypted entries, and of keys matching a sensitive key pattern, in plaintext instead of masking them.",
+	)
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/komailo/kubeit/internal/logger"
+	"github.com/komailo/kubeit/pkg/cache"
+	"github.com/komailo/kubeit/pkg/generate"
+	"github.com/komailo/kubeit/pkg/lock"
+)
+
+// Options specific to the lock command
+var lockSetOptions generate.Options
+
+// LockCmd resolves the charts of a Kubeit configuration and writes the lock file
+var LockCmd = &cobra.Command{
+	Use:   "lock [source-config-uri]",
+	Short: "Pin the chart versions and digests of a Kubeit configuration",
+	Long: `Resolve the chart of every HelmApplication and write the exact chart version and
+archive digest to ` + lock.FileName + ` in the source directory. Manifests are generated
+from the locked charts until the lock file is updated again.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		lockSetOptions.SourceConfigURI = args[0]
+
+		lockErrs, loadFileErrs := generate.Lock(&lockSetOptions)
+
+		errorMap := make(map[string][]string) // Map to store errors per file
+		if len(loadFileErrs) != 0 {
+			for file, errList := range loadFileErrs {
+				for _, err := range errList {
+					errorMap[file] = append(errorMap[file], fmt.Sprintf("- %v", err))
+				}
+			}
+		}
+
+		for _, err := range lockErrs {
+			errorMap["Lock Errors"] = append(
+				errorMap["Lock Errors"],
+				fmt.Sprintf("- %v", err),
+			)
+		}
+
+		// If there are errors, format them nicely
+		if len(errorMap) > 0 {
+			var formattedErrors []string
+			for file, errList := range errorMap {
+				formattedErrors = append(formattedErrors,
+					fmt.Sprintf("%s:\n  %s", file, strings.Join(errList, "\n  ")))
+			}
+
+			return logger.RedactError(fmt.Errorf("\n%s", strings.Join(formattedErrors, "\n")))
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	LockCmd.Flags().StringVar(
+		&lockSetOptions.CacheDir,
+		"cache-dir",
+		"",
+		"Directory of the chart cache. Defaults to $"+cache.DirEnv+" or the kubeit directory in the user cache directory.",
+	)
+}
@@ -33,17 +33,29 @@ func init() {
 		"Increase verbosity (-v = debug, -vv = trace). By default only info, warnings and errors are shown.",
 	)
 
+	RootCmd.PersistentFlags().StringArrayVar(
+		&globalSetOpts.SensitiveKeys,
+		"sensitive-key",
+		nil,
+		"Additional key pattern whose values are masked in logs and errors, can be provided multiple times. Patterns with glob characters match the full dotted key path, other patterns match any key containing them.",
+	)
+
 	// Initialize the logger after flags are parsed
 	cobra.OnInitialize(initLogger)
 
 	// Register subcommands
+	RootCmd.AddCommand(CacheCmd)
+	RootCmd.AddCommand(CapabilitiesCmd)
 	RootCmd.AddCommand(GenerateCmd)
+	RootCmd.AddCommand(LockCmd)
+	RootCmd.AddCommand(ValuesCmd)
 	RootCmd.AddCommand(VersionCmd)
 	RootCmd.SilenceUsage = true
 }
 
 func initLogger() {
 	logger.SetLevelFromVerbosity(globalSetOpts.Verbosity + 1)
+	logger.SetSensitiveKeyPatterns(globalSetOpts.SensitiveKeys)
 }
 
 // NewRootCommand returns the root command instead of executing it
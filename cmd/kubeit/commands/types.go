@@ -1,5 +1,6 @@
 package commands
 
 type globalOptions struct {
-	Verbosity int
+	Verbosity     int
+	SensitiveKeys []string
 }
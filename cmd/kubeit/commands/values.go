@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/komailo/kubeit/pkg/generate"
+)
+
+// Options specific to the values command
+var valuesSetOptions generate.Options
+
+// ValuesCmd is the base sub command to inspect the values of applications
+var ValuesCmd = &cobra.Command{
+	Use:   "values",
+	Short: "Inspect the values of applications",
+	Long:  ``,
+}
+
+func init() {
+	// Register subcommands
+	ValuesCmd.AddCommand(ValuesExplainCmd)
+
+	ValuesCmd.PersistentFlags().StringArrayVarP(
+		&valuesSetOptions.NamedValues,
+		"named-values",
+		"e",
+		nil,
+		"Name of the NamedValues to use while generating values, multiple names can be provided by using args multiple times and they will be used in the order they are provided",
+	)
+
+	ValuesCmd.PersistentFlags().StringVar(
+		&valuesSetOptions.AgeKeyFile,
+		"age-key-file",
+		"",
+		"File with the age identities used to decrypt encrypted values. Defaults to the identity in $"+generate.AgeKeyEnv+" or the file in $"+generate.AgeKeyFileEnv+".",
+	)
+
+	ValuesCmd.PersistentFlags().StringVar(
+		&valuesSetOptions.KubeVersion,
+		"kube-version",
+		"",
+		"Kubernetes server version that value entry conditions are evaluated against.",
+	)
+
+	ValuesCmd.PersistentFlags().StringArrayVar(
+		&valuesSetOptions.Variables,
+		"var",
+		nil,
+		"Variable in the form name=value that can be referenced in values, can be provided multiple times. Takes precedence over the generated variables.",
+	)
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/komailo/kubeit/internal/logger"
+	"github.com/komailo/kubeit/pkg/generate"
+)
+
+var valuesExplainOptions generate.ExplainOptions
+
+var ValuesExplainCmd = &cobra.Command{
+	Use:   "explain [source-config-uri]",
+	Short: "Explain where each value of an application comes from",
+	Long: `Print the merged values of an application. Each value is annotated with the
+value entry that set it and is followed by the values it overrides, struck through.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(_ *cobra.Command, args []string) error {
+		valuesSetOptions.SourceConfigURI = args[0]
+
+		// Strike through overridden values with ANSI escapes only on a terminal
+		if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			valuesExplainOptions.ANSI = true
+		}
+
+		explainErrs, loadFileErrs := generate.ExplainValues(
+			&valuesSetOptions,
+			valuesExplainOptions,
+			os.Stdout,
+		)
+
+		errorMap := make(map[string][]string) // Map to store errors per file
+		if len(loadFileErrs) != 0 {
+			for file, errList := range loadFileErrs {
+				for _, err := range errList {
+					errorMap[file] = append(errorMap[file], fmt.Sprintf("- %v", err))
+				}
+			}
+		}
+
+		for _, err := range explainErrs {
+			errorMap["Explain Errors"] = append(
+				errorMap["Explain Errors"],
+				fmt.Sprintf("- %v", err),
+			)
+		}
+
+		// If there are errors, format them nicely
+		if len(errorMap) > 0 {
+			var formattedErrors []string
+			for file, errList := range errorMap {
+				formattedErrors = append(formattedErrors,
+					fmt.Sprintf("%s:\n  %s", file, strings.Join(errList, "\n  ")))
+			}
+
+			return logger.RedactError(fmt.Errorf("\n%s", strings.Join(formattedErrors, "\n")))
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	ValuesExplainCmd.Flags().StringVar(
+		&valuesExplainOptions.App,
+		"app",
+		"",
+		"Name of the HelmApplication to explain the values of",
+	)
+
+	ValuesExplainCmd.Flags().StringVar(
+		&valuesExplainOptions.Key,
+		"key",
+		"",
+		"Only explain the value at this key path, e.g. controller.image.tag",
+	)
+
+	if err := ValuesExplainCmd.MarkFlagRequired("app"); err != nil {
+		logger.Fatalf("Failed to mark the app flag as required: %v", err)
+	}
+}
@@ -9,6 +9,7 @@ func init() {
 		FullTimestamp:   true,
 		TimestampFormat: "2006-01-02 15:04:05",
 	})
+	log.AddHook(redactHook{})
 }
 
 func SetLevelFromVerbosity(v int) {
@@ -0,0 +1,213 @@
+package logger
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RedactedValue replaces sensitive values in logs and errors
+const RedactedValue = "******"
+
+// minSensitiveValueLength is the length below which values matched by a sensitive key
+// pattern are not masked. Masking very short values such as "1" or "on" would garble
+// every log line they appear in. Values registered explicitly are always masked.
+const minSensitiveValueLength = 4
+
+// DefaultSensitiveKeyPatterns are the key patterns whose values are always masked.
+// Patterns with glob characters are matched against the full dotted key path, other
+// patterns match any key segment that contains them, ignoring case.
+var DefaultSensitiveKeyPatterns = []string{"password", "token", "secret", "*.key"}
+
+type redactor struct {
+	mu          sync.RWMutex
+	keyPatterns []string
+	values      map[string]struct{}
+	replacer    *strings.Replacer
+}
+
+var sensitive = &redactor{
+	keyPatterns: DefaultSensitiveKeyPatterns,
+	values:      map[string]struct{}{},
+}
+
+// SetSensitiveKeyPatterns adds key patterns to the default sensitive key patterns
+func SetSensitiveKeyPatterns(patterns []string) {
+	sensitive.mu.Lock()
+	defer sensitive.mu.Unlock()
+
+	sensitive.keyPatterns = append(
+		append([]string{}, DefaultSensitiveKeyPatterns...),
+		patterns...,
+	)
+}
+
+// IsSensitiveKey reports whether the dotted key path matches a sensitive key pattern
+func IsSensitiveKey(keyPath string) bool {
+	sensitive.mu.RLock()
+	defer sensitive.mu.RUnlock()
+
+	lowerKeyPath := strings.ToLower(keyPath)
+
+	for _, pattern := range sensitive.keyPatterns {
+		pattern = strings.ToLower(pattern)
+
+		if strings.ContainsAny(pattern, "*?[") {
+			if matched, _ := path.Match(pattern, lowerKeyPath); matched {
+				return true
+			}
+
+			continue
+		}
+
+		for _, segment := range strings.Split(lowerKeyPath, ".") {
+			if strings.Contains(segment, pattern) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// AddSensitiveValue registers a value to be masked in logs and errors, whatever its
+// length
+func AddSensitiveValue(value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+
+	sensitive.mu.Lock()
+	defer sensitive.mu.Unlock()
+
+	if _, ok := sensitive.values[value]; ok {
+		return
+	}
+
+	sensitive.values[value] = struct{}{}
+	sensitive.replacer = nil
+}
+
+// AddSensitiveValues walks a values tree and registers the leaves whose dotted key path
+// matches a sensitive key pattern, unless they are shorter than minSensitiveValueLength.
+// When all is true every leaf is registered, short ones included.
+func AddSensitiveValues(keyPath string, values any, all bool) {
+	switch typed := values.(type) {
+	case map[string]any:
+		for key, value := range typed {
+			AddSensitiveValues(joinKeyPath(keyPath, key), value, all)
+		}
+	case []any:
+		for i, value := range typed {
+			AddSensitiveValues(fmt.Sprintf("%s[%d]", keyPath, i), value, all)
+		}
+	case nil:
+	default:
+		value := fmt.Sprint(typed)
+
+		switch {
+		case all:
+			AddSensitiveValue(value)
+		case IsSensitiveKey(keyPath) && len(strings.TrimSpace(value)) >= minSensitiveValueLength:
+			AddSensitiveValue(value)
+		}
+	}
+}
+
+func joinKeyPath(keyPath, key string) string {
+	if keyPath == "" {
+		return key
+	}
+
+	return keyPath + "." + key
+}
+
+// Redact masks the registered sensitive values in s
+func Redact(s string) string {
+	sensitive.mu.RLock()
+	replacer := sensitive.replacer
+	sensitive.mu.RUnlock()
+
+	if replacer == nil {
+		replacer = sensitive.buildReplacer()
+	}
+
+	return replacer.Replace(s)
+}
+
+func (r *redactor) buildReplacer() *strings.Replacer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	values := make([]string, 0, len(r.values))
+	for value := range r.values {
+		values = append(values, value)
+	}
+
+	// Replace longer values first so a value that contains another one is fully masked
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+
+		return values[i] < values[j]
+	})
+
+	oldNew := make([]string, 0, 2*len(values))
+	for _, value := range values {
+		oldNew = append(oldNew, value, RedactedValue)
+	}
+
+	r.replacer = strings.NewReplacer(oldNew...)
+
+	return r.replacer
+}
+
+// redactedError masks the sensitive values in the message of the wrapped error
+type redactedError struct {
+	err error
+}
+
+func (e redactedError) Error() string {
+	return Redact(e.err.Error())
+}
+
+func (e redactedError) Unwrap() error {
+	return e.err
+}
+
+// RedactError wraps err so its message has the sensitive values masked
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return redactedError{err: err}
+}
+
+// redactHook masks sensitive values in every log entry before it is written
+type redactHook struct{}
+
+func (redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (redactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = Redact(entry.Message)
+
+	for key, value := range entry.Data {
+		switch typed := value.(type) {
+		case string:
+			entry.Data[key] = Redact(typed)
+		case error:
+			entry.Data[key] = Redact(typed.Error())
+		}
+	}
+
+	return nil
+}
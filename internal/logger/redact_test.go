@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsSensitiveKey(t *testing.T) {
+	SetSensitiveKeyPatterns([]string{"apiKey"})
+	t.Cleanup(func() { SetSensitiveKeyPatterns(nil) })
+
+	testCases := []struct {
+		keyPath  string
+		expected bool
+	}{
+		{"database.password", true},
+		{"database.adminPassword", true},
+		{"auth.token", true},
+		{"existingSecret", true},
+		{"tls.key", true},
+		{"certs.server.key", true},
+		{"service.apikey", true},
+		{"image.tag", false},
+		{"keycloak.enabled", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.keyPath, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsSensitiveKey(tc.keyPath))
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	AddSensitiveValues("", map[string]any{
+		"database": map[string]any{
+			"host":     "db.example.com",
+			"password": "hunter22",
+		},
+		"replicas": 3,
+		"auth":     map[string]any{"token": "on"},
+	}, false)
+	AddSensitiveValues("apiKeys", []any{"key-0123456789"}, true)
+	AddSensitiveValues("pin", "913", true)
+
+	assert.Equal(
+		t,
+		"password=******, host=db.example.com, keys=******, enabled=on, pin=******",
+		Redact("password=hunter22, host=db.example.com, keys=key-0123456789, enabled=on, pin=913"),
+		"Expected short values masked only when registered explicitly",
+	)
+
+	err := RedactError(fmt.Errorf("failed to connect: %w", errors.New("bad password hunter22")))
+	assert.Equal(t, "failed to connect: bad password ******", err.Error())
+	assert.NoError(t, RedactError(nil))
+}
+
+func TestRedactHook(t *testing.T) {
+	AddSensitiveValue("s3cr3t-value")
+
+	var output bytes.Buffer
+
+	hookedLogger := logrus.New()
+	hookedLogger.SetOutput(&output)
+	hookedLogger.AddHook(redactHook{})
+
+	hookedLogger.WithField("value", "s3cr3t-value").Infof("value is %s", "s3cr3t-value")
+
+	assert.NotContains(t, output.String(), "s3cr3t-value")
+	assert.Contains(t, output.String(), "value is ******")
+}
@@ -0,0 +1,265 @@
+package loader
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/komailo/kubeit/internal/logger"
+	"github.com/komailo/kubeit/pkg/api"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+// ownedValues is a list of value entries together with the resource that declares them
+type ownedValues struct {
+	Owner  api.Object
+	Values []v1.ValueEntry
+}
+
+// valueEntries returns the value entries of every loaded resource. The returned slices
+// share their backing arrays with the resources so entries can be updated in place.
+func (l *Loader) valueEntries() []ownedValues {
+	var entries []ownedValues
+
+	for _, helmApplication := range l.HelmApplications {
+		entries = append(entries, ownedValues{
+			Owner:  helmApplication,
+			Values: helmApplication.Spec.Values,
+		})
+	}
+
+	for _, namedValues := range l.NamedValues {
+		entries = append(entries, ownedValues{
+			Owner:  namedValues,
+			Values: namedValues.Spec.Values,
+		})
+	}
+
+	return entries
+}
+
+// registerSensitiveValues registers the values of sensitive entries, and the values of
+// keys matching a sensitive key pattern, with the logger so they are masked in logs.
+func (l *Loader) registerSensitiveValues() {
+	for _, owned := range l.valueEntries() {
+		for _, value := range owned.Values {
+			switch value.Type {
+			case "raw":
+				var raw any
+				if err := json.Unmarshal(value.Data, &raw); err == nil {
+					logger.AddSensitiveValues("", raw, value.Sensitive)
+				}
+			case "mapping", "string", "literal", "json":
+				var mappings map[string]any
+				if err := json.Unmarshal(value.Data, &mappings); err == nil {
+					for key, mapping := range mappings {
+						logger.AddSensitiveValues(key, mapping, value.Sensitive)
+					}
+				}
+			}
+		}
+	}
+}
+
+// FileKey resolves a file referenced by a resource relative to the file the resource
+// was loaded from, and returns its path relative to the source root.
+func (l *Loader) FileKey(owner api.Object, filePath string) (string, error) {
+	if filepath.IsAbs(filePath) {
+		return "", fmt.Errorf("file %s must be a path relative to the resource", filePath)
+	}
+
+	key := path.Clean(path.Join(path.Dir(l.sourceFiles[owner]), filepath.ToSlash(filePath)))
+	if key == ".." || strings.HasPrefix(key, "../") {
+		return "", fmt.Errorf("file %s is outside of the source directory", filePath)
+	}
+
+	return key, nil
+}
+
+// ReadFile returns the content of a file referenced by a resource. Files are read from
+// disk for file sources and from the embedded files for Docker image sources.
+func (l *Loader) ReadFile(owner api.Object, filePath string) ([]byte, error) {
+	key, err := l.FileKey(owner, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.SourceMeta.Scheme == "docker" {
+		data, ok := l.Files[key]
+		if !ok {
+			return nil, fmt.Errorf("file %s is not embedded in the image", key)
+		}
+
+		return data, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(l.rootDir, filepath.FromSlash(key)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %w", key, err)
+	}
+
+	return data, nil
+}
+
+// LocalPath returns the path on disk of a file referenced by a resource. Only file
+// sources have files on disk, files of Docker image sources are read with ReadFile.
+func (l *Loader) LocalPath(owner api.Object, filePath string) (string, error) {
+	key, err := l.FileKey(owner, filePath)
+	if err != nil {
+		return "", err
+	}
+
+	if l.SourceMeta.Scheme == "docker" {
+		return "", fmt.Errorf("file %s is embedded in the image and not on disk", key)
+	}
+
+	return filepath.Join(l.rootDir, filepath.FromSlash(key)), nil
+}
+
+// EmbedFiles reads every file referenced by a file value entry, the files of the
+// kustomizations of HelmApplications and the relative CA files of HelmRepositories
+// into Files and rewrites the references to be
+// relative to the source root, so the resources and the files can be embedded in a
+// Docker image together.
+func (l *Loader) EmbedFiles() []error {
+	var errs []error
+
+	for _, helmApplication := range l.HelmApplications {
+		if helmApplication.Spec.Kustomize == nil || helmApplication.Spec.Kustomize.Path == "" {
+			continue
+		}
+
+		kustomizePath := helmApplication.Spec.Kustomize.Path
+
+		key, err := l.FileKey(helmApplication, kustomizePath)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		files, err := l.KustomizationFiles(helmApplication, kustomizePath)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for fileKey, data := range files {
+			l.Files[fileKey] = data
+		}
+
+		helmApplication.Spec.Kustomize.Path = key
+	}
+
+	for _, helmRepository := range l.HelmRepositories {
+		repositoryTLS := helmRepository.Spec.TLS
+		if repositoryTLS == nil {
+			continue
+		}
+
+		// The labels of an image can be read by anyone who can pull it, so the client
+		// certificate and key are never embedded and must be on the disk of the machine
+		// rendering the image
+		for _, clientFile := range []struct{ field, path string }{
+			{"certFile", repositoryTLS.CertFile},
+			{"keyFile", repositoryTLS.KeyFile},
+		} {
+			if clientFile.path != "" && !filepath.IsAbs(clientFile.path) {
+				errs = append(errs, fmt.Errorf(
+					"HelmRepository %s: spec.tls.%s %s must be an absolute path to be "+
+						"used from a Docker image, client credentials are not embedded",
+					helmRepository.Metadata.Name, clientFile.field, clientFile.path,
+				))
+			}
+		}
+
+		// Absolute paths are read from the disk of the machine rendering the image
+		if repositoryTLS.CAFile == "" || filepath.IsAbs(repositoryTLS.CAFile) {
+			continue
+		}
+
+		fileKey, err := l.embedFile(helmRepository, repositoryTLS.CAFile)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		repositoryTLS.CAFile = fileKey
+	}
+
+	for _, owned := range l.valueEntries() {
+		for i, value := range owned.Values {
+			if value.Type != "file" {
+				continue
+			}
+
+			var files map[string]string
+			if err := json.Unmarshal(value.Data, &files); err != nil {
+				errs = append(errs, fmt.Errorf("failed to unmarshal file values: %w", err))
+				continue
+			}
+
+			for key, filePath := range files {
+				fileKey, err := l.embedFile(owned.Owner, filePath)
+				if err != nil {
+					errs = append(errs, err)
+					continue
+				}
+
+				files[key] = fileKey
+			}
+
+			data, err := json.Marshal(files)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to marshal file values: %w", err))
+				continue
+			}
+
+			owned.Values[i].Data = data
+		}
+	}
+
+	return errs
+}
+
+func (l *Loader) embedFile(owner api.Object, filePath string) (string, error) {
+	key, err := l.FileKey(owner, filePath)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := l.ReadFile(owner, filePath)
+	if err != nil {
+		return "", err
+	}
+
+	l.Files[key] = data
+
+	return key, nil
+}
+
+// MarshalFiles encodes the embedded files so they can be attached as a Docker label
+func (l *Loader) MarshalFiles() (string, error) {
+	jsonFiles, err := json.Marshal(l.Files)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal embedded files: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(jsonFiles), nil
+}
+
+func (l *Loader) unmarshalFiles(base64Files string) error {
+	jsonFiles, err := base64.StdEncoding.DecodeString(base64Files)
+	if err != nil {
+		return fmt.Errorf("failed to decode base64 files: %w", err)
+	}
+
+	if err := json.Unmarshal(jsonFiles, &l.Files); err != nil {
+		return fmt.Errorf("failed to unmarshal embedded files: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,207 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/komailo/kubeit/pkg/api"
+)
+
+// KustomizationFileNames are the file names kustomize loads a kustomization from
+var KustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// isKustomizationDir reports whether a directory on disk holds a kustomization
+func isKustomizationDir(dir string) bool {
+	for _, name := range KustomizationFileNames {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// KustomizationFiles returns the files of a kustomization directory referenced by a
+// resource, together with the files and local kustomizations it references as
+// resources, bases and components, keyed by their path relative to the source root.
+// Remote kustomizations are not supported as rendering does not reach the network.
+func (l *Loader) KustomizationFiles(owner api.Object, dirPath string) (map[string][]byte, error) {
+	key, err := l.FileKey(owner, dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+
+	if err := l.collectKustomization(key, files, make(map[string]bool)); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func (l *Loader) collectKustomization(
+	dirKey string,
+	files map[string][]byte,
+	visited map[string]bool,
+) error {
+	if visited[dirKey] {
+		return nil
+	}
+
+	visited[dirKey] = true
+
+	dirFiles, err := l.dirFiles(dirKey)
+	if err != nil {
+		return err
+	}
+
+	var kustomizationData []byte
+
+	for key, data := range dirFiles {
+		files[key] = data
+	}
+
+	for _, name := range KustomizationFileNames {
+		if data, ok := dirFiles[path.Join(dirKey, name)]; ok {
+			kustomizationData = data
+			break
+		}
+	}
+
+	if kustomizationData == nil {
+		return fmt.Errorf("directory %s has no kustomization file", dirKey)
+	}
+
+	var kustomization struct {
+		Resources  []string `json:"resources"`
+		Bases      []string `json:"bases"`
+		Components []string `json:"components"`
+	}
+
+	if err := k8syaml.Unmarshal(kustomizationData, &kustomization); err != nil {
+		return fmt.Errorf("failed to decode the kustomization of %s: %w", dirKey, err)
+	}
+
+	references := append(kustomization.Resources, kustomization.Bases...)
+	references = append(references, kustomization.Components...)
+
+	for _, reference := range references {
+		if strings.Contains(reference, "://") || strings.HasPrefix(reference, "git@") ||
+			strings.HasPrefix(reference, "github.com/") {
+			return fmt.Errorf(
+				"remote resource %s of the kustomization %s is not supported",
+				reference,
+				dirKey,
+			)
+		}
+
+		referenceKey := path.Clean(path.Join(dirKey, reference))
+		if referenceKey == ".." || strings.HasPrefix(referenceKey, "../") {
+			return fmt.Errorf(
+				"resource %s of the kustomization %s is outside of the source directory",
+				reference,
+				dirKey,
+			)
+		}
+
+		if data, ok, err := l.sourceFile(referenceKey); err != nil {
+			return err
+		} else if ok {
+			files[referenceKey] = data
+			continue
+		}
+
+		if err := l.collectKustomization(referenceKey, files, visited); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dirFiles returns the files in a directory of the source and its subdirectories.
+// Files are read from disk for file sources and from the embedded files for Docker
+// image sources.
+func (l *Loader) dirFiles(dirKey string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+
+	if l.SourceMeta.Scheme == "docker" {
+		for key, data := range l.Files {
+			if strings.HasPrefix(key, dirKey+"/") {
+				files[key] = data
+			}
+		}
+
+		if len(files) == 0 {
+			return nil, fmt.Errorf("directory %s is not embedded in the image", dirKey)
+		}
+
+		return files, nil
+	}
+
+	dir := filepath.Join(l.rootDir, filepath.FromSlash(dirKey))
+
+	err := filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(l.rootDir, filePath)
+		if err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(relativePath)] = data
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dirKey, err)
+	}
+
+	return files, nil
+}
+
+// sourceFile returns the content of a file of the source, and false when the key is
+// not a file, such as a directory
+func (l *Loader) sourceFile(key string) ([]byte, bool, error) {
+	if l.SourceMeta.Scheme == "docker" {
+		data, ok := l.Files[key]
+		return data, ok, nil
+	}
+
+	filePath := filepath.Join(l.rootDir, filepath.FromSlash(key))
+
+	info, err := os.Stat(filePath)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.IsDir()) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read file %s: %w", key, err)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read file %s: %w", key, err)
+	}
+
+	return data, true, nil
+}
@@ -6,8 +6,10 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"k8s.io/apimachinery/pkg/util/yaml"
@@ -19,6 +21,7 @@ import (
 	"github.com/komailo/kubeit/internal/logger"
 	"github.com/komailo/kubeit/pkg/api"
 	v1 "github.com/komailo/kubeit/pkg/api/v1"
+	"github.com/komailo/kubeit/pkg/lock"
 	"github.com/komailo/kubeit/pkg/utils"
 )
 
@@ -28,9 +31,23 @@ type Loader struct {
 	registry         map[string]map[string]registeredType
 	SourceMeta       api.SourceMeta
 	HelmApplications []*v1.HelmApplication
+	HelmRepositories []*v1.HelmRepository
 	NamedValues      []*v1.NamedValues
+	Capabilities     []*v1.Capabilities
 	KindsCount       map[string]int
 	ResourceCount    int
+	// ImageMetadata is populated when the resources are loaded from a Docker image
+	ImageMetadata utils.ImageMetadata
+	// Files holds the files referenced by the resources that are embedded in a Docker
+	// image, keyed by their path relative to the source root
+	Files map[string][]byte
+	// Lock pins the charts of the HelmApplications, it is nil when the source has no
+	// lock file
+	Lock *lock.Lock
+	// sourceFiles maps each loaded resource to the file it was loaded from, relative to
+	// the source root. Resources loaded from a Docker image have no source file.
+	sourceFiles map[api.Object]string
+	rootDir     string
 }
 
 type registeredType struct {
@@ -43,8 +60,10 @@ type registeredType struct {
 // NewDecoder creates a new decoder with registered types
 func NewLoader() *Loader {
 	l := &Loader{
-		registry:   make(map[string]map[string]registeredType),
-		KindsCount: make(map[string]int),
+		registry:    make(map[string]map[string]registeredType),
+		KindsCount:  make(map[string]int),
+		Files:       make(map[string][]byte),
+		sourceFiles: make(map[api.Object]string),
 	}
 
 	register(
@@ -54,6 +73,13 @@ func NewLoader() *Loader {
 		func() *v1.HelmApplication { return &v1.HelmApplication{} },
 		&l.HelmApplications,
 	)
+	register(
+		l,
+		"HelmRepository",
+		"kubeit.komailo.github.io/v1alpha1",
+		func() *v1.HelmRepository { return &v1.HelmRepository{} },
+		&l.HelmRepositories,
+	)
 	register(
 		l,
 		"NamedValues",
@@ -61,6 +87,13 @@ func NewLoader() *Loader {
 		func() *v1.NamedValues { return &v1.NamedValues{} },
 		&l.NamedValues,
 	)
+	register(
+		l,
+		"Capabilities",
+		"kubeit.komailo.github.io/v1alpha1",
+		func() *v1.Capabilities { return &v1.Capabilities{} },
+		&l.Capabilities,
+	)
 
 	return l
 }
@@ -96,7 +129,7 @@ type TypeMeta struct {
 	APIVersion string `json:"apiVersion" yaml:"apiVersion"`
 }
 
-func (l *Loader) unmarshal(data []byte) error {
+func (l *Loader) unmarshal(data []byte, sourceFile string) error {
 	var meta TypeMeta
 	if err := yaml.Unmarshal(data, &meta); err != nil {
 		return fmt.Errorf("failed to decode type metadata: %w", err)
@@ -118,6 +151,7 @@ func (l *Loader) unmarshal(data []byte) error {
 	}
 
 	rt.AppendFn(obj)
+	l.sourceFiles[obj] = sourceFile
 
 	l.ResourceCount++
 	l.KindsCount[meta.Kind]++
@@ -126,7 +160,7 @@ func (l *Loader) unmarshal(data []byte) error {
 }
 
 // UnmarshalMulti decodes a YAML file into the appropriate API object
-func (l *Loader) unmarshalMulti(data []byte) []error {
+func (l *Loader) unmarshalMulti(data []byte, sourceFile string) []error {
 	var errors []error
 
 	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
@@ -137,7 +171,7 @@ func (l *Loader) unmarshalMulti(data []byte) []error {
 			break // End of input
 		}
 
-		unmarshalErr := l.unmarshal(rawMessage)
+		unmarshalErr := l.unmarshal(rawMessage, sourceFile)
 		if unmarshalErr != nil {
 			errors = append(errors, unmarshalErr)
 			continue
@@ -152,6 +186,11 @@ func (l *Loader) fromDir() map[string][]error {
 
 	errs := make(map[string][]error)
 
+	l.rootDir = dirPath
+	if info, err := os.Stat(dirPath); err == nil && !info.IsDir() {
+		l.rootDir = filepath.Dir(dirPath)
+	}
+
 	walkErr := filepath.Walk(dirPath, func(filePath string, info os.FileInfo, err error) error {
 		if err != nil {
 			errs[filePath] = append(errs[filePath], fmt.Errorf("error accessing file: %w", err))
@@ -165,11 +204,34 @@ func (l *Loader) fromDir() map[string][]error {
 				return filepath.SkipDir
 			}
 
+			// Charts referenced by spec.chart.path are not Kubeit resources
+			if _, err := os.Stat(filepath.Join(filePath, "Chart.yaml")); err == nil &&
+				filePath != filepath.Clean(dirPath) {
+				logger.Debugf("Skipping chart directory to load Kubeit resources from: %s", filePath)
+				return filepath.SkipDir
+			}
+
+			// Kustomizations referenced by spec.kustomize.path are not Kubeit resources
+			if isKustomizationDir(filePath) && filePath != filepath.Clean(dirPath) {
+				logger.Debugf(
+					"Skipping kustomization directory to load Kubeit resources from: %s",
+					filePath,
+				)
+
+				return filepath.SkipDir
+			}
+
 			logger.Debugf("Found directory to walk to Kubeit resources from: %s", filePath)
 
 			return nil
 		}
 
+		// The lock file and chart archives are not resources, the lock file is loaded
+		// after the walk
+		if filePath == l.LockPath() || filepath.Ext(filePath) == ".tgz" {
+			return nil
+		}
+
 		logger.Infof("Loading file: %s", filePath)
 
 		data, err := os.ReadFile(filePath)
@@ -178,7 +240,13 @@ func (l *Loader) fromDir() map[string][]error {
 			return nil
 		}
 
-		unmarhsalErr := l.unmarshalMulti(data)
+		sourceFile, err := filepath.Rel(l.rootDir, filePath)
+		if err != nil {
+			errs[filePath] = append(errs[filePath], fmt.Errorf("failed to get relative path: %w", err))
+			return nil
+		}
+
+		unmarhsalErr := l.unmarshalMulti(data, filepath.ToSlash(sourceFile))
 		if unmarhsalErr != nil {
 			errs[filePath] = append(errs[filePath], unmarhsalErr...)
 		}
@@ -192,9 +260,26 @@ func (l *Loader) fromDir() map[string][]error {
 		)
 	}
 
+	lockFile, err := lock.Load(l.LockPath())
+	if err != nil {
+		errs[l.LockPath()] = append(errs[l.LockPath()], err)
+	}
+
+	l.Lock = lockFile
+
 	return errs
 }
 
+// LockPath returns the path of the lock file of a file source, or an empty string for
+// other sources
+func (l *Loader) LockPath() string {
+	if l.SourceMeta.Scheme != "file" {
+		return ""
+	}
+
+	return filepath.Join(l.rootDir, lock.FileName)
+}
+
 func (l *Loader) fromDockerImage() map[string][]error {
 	imageRef := l.SourceMeta.Source
 	errs := make(map[string][]error)
@@ -235,6 +320,8 @@ func (l *Loader) fromDockerImage() map[string][]error {
 		return errs
 	}
 
+	l.ImageMetadata = utils.ImageMetadataFromInspect(imageInspect)
+
 	labelKey := common.KubeitDomain + "/resources"
 
 	base64Resource, ok := imageInspect.Config.Labels[labelKey]
@@ -257,16 +344,56 @@ func (l *Loader) fromDockerImage() map[string][]error {
 		return errs
 	}
 
-	logger.Debugf("Decoded resources:\n%s", decodedResources)
-
-	unmarhsalErr := l.unmarshalMulti(decodedResources)
+	unmarhsalErr := l.unmarshalMulti(decodedResources, "")
 	if unmarhsalErr != nil {
 		errs[imageRef] = append(errs[imageRef], unmarhsalErr...)
 	}
 
+	// Sensitive values must be known before the resources are logged
+	l.registerSensitiveValues()
+	logger.Debugf("Decoded resources:\n%s", decodedResources)
+
+	if base64Files, ok := imageInspect.Config.Labels[common.KubeitDomain+"/files"]; ok {
+		if err := l.unmarshalFiles(base64Files); err != nil {
+			errs[imageRef] = append(errs[imageRef], err)
+		}
+	}
+
+	if base64Lock, ok := imageInspect.Config.Labels[common.KubeitDomain+"/lock"]; ok {
+		if err := l.unmarshalLock(base64Lock); err != nil {
+			errs[imageRef] = append(errs[imageRef], err)
+		}
+	}
+
 	return errs
 }
 
+// MarshalLock encodes the lock file so it can be attached as a Docker label
+func (l *Loader) MarshalLock() (string, error) {
+	data, err := l.Lock.Marshal()
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func (l *Loader) unmarshalLock(base64Lock string) error {
+	data, err := base64.StdEncoding.DecodeString(base64Lock)
+	if err != nil {
+		return fmt.Errorf("failed to decode base64 lock file: %w", err)
+	}
+
+	lockFile, err := lock.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	l.Lock = lockFile
+
+	return nil
+}
+
 func (l *Loader) checkResourceUniqueness() map[string][]error {
 	errors := make(map[string][]error)
 
@@ -315,9 +442,13 @@ func (l *Loader) Marshal() (strings.Builder, []error) {
 
 	var resourcesYaml strings.Builder
 
-	for _, versions := range l.registry {
-		for _, rt := range versions {
-			for _, resource := range rt.GetAll() {
+	// The kinds and versions are marshalled in order so the output is the same on every
+	// run, the resources of a kind keep the order they are loaded in
+	for _, kind := range slices.Sorted(maps.Keys(l.registry)) {
+		versions := l.registry[kind]
+
+		for _, version := range slices.Sorted(maps.Keys(versions)) {
+			for _, resource := range versions[version].GetAll() {
 				yamlStr, err := marshalResourceToYAML(resource)
 				if err != nil {
 					errs = append(errs, err)
@@ -358,6 +489,8 @@ func (l *Loader) FromSourceURI(sourceConfigURI string) map[string][]error {
 	default: // this should never happen as SourceConfigURIParser would error out
 	}
 
+	l.registerSensitiveValues()
+
 	if len(errs) == 0 {
 		validateErrs := l.Validate()
 		if len(validateErrs) != 0 {
@@ -283,3 +283,106 @@ func TestLoader_FindResourcesByName(t *testing.T) {
 	resources := FindResourcesByName(loader.NamedValues, []string{"staging", "canary"})
 	assert.Len(t, resources, 2, "Expected 1 resource but got %d", len(resources))
 }
+
+func TestLoader_Files(t *testing.T) {
+	loader := NewLoader()
+
+	errors := loader.FromSourceURI("testdata/files")
+	require.Empty(t, errors, "Expected no errors but got some")
+	require.Len(t, loader.HelmApplications, 1)
+	require.Len(t, loader.NamedValues, 1)
+
+	helmApplication := loader.HelmApplications[0]
+	namedValues := loader.NamedValues[0]
+
+	key, err := loader.FileKey(namedValues, "../.files/production.conf")
+	require.NoError(t, err)
+	assert.Equal(t, ".files/production.conf", key)
+
+	data, err := loader.ReadFile(namedValues, "../.files/production.conf")
+	require.NoError(t, err)
+	assert.Equal(t, "log_level = warn\n", string(data))
+
+	_, err = loader.FileKey(helmApplication, "../outside.txt")
+	require.Error(t, err, "Expected an error for a file outside of the source directory")
+
+	_, err = loader.FileKey(helmApplication, "/etc/passwd")
+	require.Error(t, err, "Expected an error for an absolute file path")
+
+	embedErrs := loader.EmbedFiles()
+	require.Empty(t, embedErrs)
+
+	assert.Equal(t, map[string][]byte{
+		".files/ca.crt":          []byte("-----BEGIN CERTIFICATE-----\ntest\n-----END CERTIFICATE-----\n"),
+		".files/production.conf": []byte("log_level = warn\n"),
+	}, loader.Files)
+	assert.JSONEq(
+		t,
+		`{"config": ".files/production.conf"}`,
+		string(namedValues.Spec.Values[0].Data),
+		"Expected file references to be rewritten relative to the source root",
+	)
+
+	encodedFiles, err := loader.MarshalFiles()
+	require.NoError(t, err)
+
+	imageLoader := NewLoader()
+	imageLoader.SourceMeta.Scheme = "docker"
+	require.NoError(t, imageLoader.unmarshalFiles(encodedFiles))
+	assert.Equal(t, loader.Files, imageLoader.Files)
+
+	data, err = imageLoader.ReadFile(namedValues, ".files/production.conf")
+	require.NoError(t, err)
+	assert.Equal(t, "log_level = warn\n", string(data))
+
+	_, err = imageLoader.ReadFile(namedValues, ".files/missing.conf")
+	require.Error(t, err, "Expected an error for a file that is not embedded")
+}
+
+func TestLoader_SourceLocation(t *testing.T) {
+	loader := NewLoader()
+
+	errors := loader.FromSourceURI("testdata/files")
+	require.Empty(t, errors, "Expected no errors but got some")
+
+	file, line := loader.SourceLocation(loader.HelmApplications[0], 1)
+	assert.Equal(t, "helm_application.yaml", file)
+	assert.Equal(t, 16, line)
+
+	file, line = loader.SourceLocation(loader.NamedValues[0], 0)
+	assert.Equal(t, "named-values/production.yaml", file)
+	assert.Equal(t, 8, line)
+
+	imageLoader := NewLoader()
+	file, line = imageLoader.SourceLocation(loader.HelmApplications[0], 0)
+	assert.Empty(t, file)
+	assert.Zero(t, line)
+}
+
+func TestLoader_Lock(t *testing.T) {
+	loader := NewLoader()
+
+	errors := loader.FromSourceURI("testdata/locked")
+	require.Empty(t, errors, "Expected the lock file not to be loaded as a resource")
+	require.Len(t, loader.HelmApplications, 1)
+	assert.Equal(t, "kubeit.lock", filepath.Base(loader.LockPath()))
+
+	require.NotNil(t, loader.Lock)
+
+	application, ok := loader.Lock.Find("locked-app")
+	require.True(t, ok)
+	assert.Equal(t, "4.12.0", application.Version)
+
+	encodedLock, err := loader.MarshalLock()
+	require.NoError(t, err)
+
+	imageLoader := NewLoader()
+	imageLoader.SourceMeta.Scheme = "docker"
+	require.NoError(t, imageLoader.unmarshalLock(encodedLock))
+	assert.Equal(t, loader.Lock, imageLoader.Lock)
+	assert.Empty(t, imageLoader.LockPath())
+
+	unlocked := NewLoader()
+	require.Empty(t, unlocked.FromSourceURI("testdata/files"))
+	assert.Nil(t, unlocked.Lock)
+}
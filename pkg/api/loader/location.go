@@ -0,0 +1,85 @@
+package loader
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/komailo/kubeit/pkg/api"
+)
+
+// SourceFile returns the file a resource was loaded from, relative to the source root.
+// It is empty for resources loaded from a Docker image.
+func (l *Loader) SourceFile(obj api.Object) string {
+	return l.sourceFiles[obj]
+}
+
+// SourceLocation returns the file and line of the value entry at index in the
+// spec.values of a resource. The line is 0 when the location cannot be determined,
+// for example for resources loaded from a Docker image.
+func (l *Loader) SourceLocation(obj api.Object, index int) (string, int) {
+	sourceFile := l.sourceFiles[obj]
+	if sourceFile == "" || l.rootDir == "" {
+		return sourceFile, 0
+	}
+
+	data, err := os.ReadFile(filepath.Join(l.rootDir, filepath.FromSlash(sourceFile)))
+	if err != nil {
+		return sourceFile, 0
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+	for {
+		var document yaml.Node
+		if err := decoder.Decode(&document); err != nil {
+			if !errors.Is(err, io.EOF) {
+				return sourceFile, 0
+			}
+
+			break
+		}
+
+		if len(document.Content) == 0 {
+			continue
+		}
+
+		root := document.Content[0]
+
+		kind := mappingValue(root, "kind")
+		name := mappingValue(mappingValue(root, "metadata"), "name")
+
+		if kind == nil || name == nil ||
+			kind.Value != obj.GetTypeMeta().Kind || name.Value != obj.GetObjectMeta().Name {
+			continue
+		}
+
+		values := mappingValue(mappingValue(root, "spec"), "values")
+		if values == nil || values.Kind != yaml.SequenceNode || index >= len(values.Content) {
+			return sourceFile, root.Line
+		}
+
+		return sourceFile, values.Content[index].Line
+	}
+
+	return sourceFile, 0
+}
+
+// mappingValue returns the value node of key in a YAML mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
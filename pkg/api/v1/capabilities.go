@@ -0,0 +1,55 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/komailo/kubeit/pkg/api"
+)
+
+// Capabilities is a profile of the Kubernetes version and the API versions of a
+// cluster. Charts are rendered against it, so .Capabilities checks in templates take
+// the same path as they would when installed in the cluster. NamedValues select a
+// profile with spec.capabilitiesRef.
+type Capabilities struct {
+	api.BaseObject `                 json:",inline"`
+	Spec           CapabilitiesSpec `json:"spec"`
+}
+
+type CapabilitiesSpec struct {
+	// KubeVersion is the Kubernetes version of the cluster, e.g. v1.31.2
+	KubeVersion string `json:"kubeVersion,omitempty"`
+	// APIVersions are the API group versions, e.g. monitoring.coreos.com/v1, and the
+	// resources, e.g. monitoring.coreos.com/v1/ServiceMonitor, served by the cluster
+	APIVersions []string `json:"apiVersions,omitempty"`
+	// ClusterScopedKinds are the kinds served without a namespace, e.g.
+	// rbac.authorization.k8s.io/v1/ClusterRole, in addition to the built-in ones.
+	// Objects of other kinds rendered without a namespace are put in the namespace of
+	// the application.
+	ClusterScopedKinds []string `json:"clusterScopedKinds,omitempty"`
+}
+
+// Custom validation function for Capabilities
+func (c Capabilities) Validate() error {
+	if c.Spec.KubeVersion == "" && len(c.Spec.APIVersions) == 0 {
+		return errors.New("either spec.kubeVersion or spec.apiVersions must be provided")
+	}
+
+	for i, apiVersion := range c.Spec.APIVersions {
+		if apiVersion == "" {
+			return fmt.Errorf("spec.apiVersions[%d] must not be empty", i)
+		}
+	}
+
+	for i, kind := range c.Spec.ClusterScopedKinds {
+		if !strings.Contains(kind, "/") {
+			return fmt.Errorf(
+				"spec.clusterScopedKinds[%d] must be an API version and kind, e.g. v1/Namespace",
+				i,
+			)
+		}
+	}
+
+	return nil
+}
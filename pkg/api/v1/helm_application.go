@@ -2,6 +2,7 @@ package v1
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/komailo/kubeit/pkg/api"
 )
@@ -16,12 +17,90 @@ type HelmApplication struct {
 type HelmApplicationSpec struct {
 	Chart  ChartSpec    `json:"chart"            validate:"required"`
 	Values []ValueEntry `json:"values,omitempty"`
+	Hooks  *HooksSpec   `json:"hooks,omitempty"`
+	CRDs   *CRDsSpec    `json:"crds,omitempty"`
+	// CommonLabels are added to every rendered object
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+	// CommonAnnotations are added to every rendered object
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+	// PropagateToPodTemplates also adds the common labels and annotations to the pod
+	// templates of workloads, so a change of the config hash rolls out their pods
+	PropagateToPodTemplates bool `json:"propagateToPodTemplates,omitempty"`
+	// Patches are applied to the rendered objects in their order
+	Patches []Patch `json:"patches,omitempty"`
+	// Kustomize builds the rendered objects with a kustomization
+	Kustomize *KustomizeSpec `json:"kustomize,omitempty"`
+	// Namespace controls the Namespace object of spec.chart.namespace
+	Namespace *NamespaceSpec `json:"namespace,omitempty"`
+}
+
+// NamespaceSpec controls the Namespace object the rendered objects are in
+type NamespaceSpec struct {
+	// Create writes a Namespace object for spec.chart.namespace before the other objects
+	Create bool `json:"create,omitempty"`
+	// Labels are added to the Namespace object, e.g. the Pod Security admission levels
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are added to the Namespace object
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// KustomizeSpec references a kustomization the rendered objects are built with
+type KustomizeSpec struct {
+	// Path is a kustomization directory relative to the file of the resource. The
+	// rendered objects are added to its resources and the build output is written
+	// instead of them.
+	Path string `json:"path,omitempty"`
+}
+
+// CRDPolicy is how the CRDs in the crds directory of a chart are written
+type CRDPolicy string
+
+const (
+	// CRDPolicyInclude writes the CRDs before the other manifests
+	CRDPolicyInclude CRDPolicy = "Include"
+	// CRDPolicySkip leaves the CRDs out
+	CRDPolicySkip CRDPolicy = "Skip"
+	// CRDPolicySeparate writes the CRDs to their own file in the crds output directory
+	CRDPolicySeparate CRDPolicy = "Separate"
+)
+
+// CRDsSpec controls how the CRDs of the chart are written
+type CRDsSpec struct {
+	// Policy is Include, Skip or Separate, Include when empty
+	Policy CRDPolicy `json:"policy,omitempty"`
+}
+
+// HookPolicy is how the Helm hooks of a chart are written
+type HookPolicy string
+
+const (
+	// HookPolicyInline writes the hooks after the manifests, as helm template does
+	HookPolicyInline HookPolicy = "Inline"
+	// HookPolicySeparate writes the hooks to their own file next to the manifests
+	HookPolicySeparate HookPolicy = "Separate"
+	// HookPolicyDrop leaves the hooks out
+	HookPolicyDrop HookPolicy = "Drop"
+)
+
+// HooksSpec controls how the Helm hooks of the chart are written
+type HooksSpec struct {
+	// Policy is Inline, Separate or Drop, Inline when empty
+	Policy HookPolicy `json:"policy,omitempty"`
+	// ArgoCD translates the Helm hook annotations to their Argo CD sync hook equivalents
+	ArgoCD bool `json:"argoCD,omitempty"`
+	// ExcludeTests leaves out the chart tests, the resources annotated with
+	// helm.sh/hook: test
+	ExcludeTests bool `json:"excludeTests,omitempty"`
 }
 
 type ChartSpec struct {
-	Repository  string `json:"repository,omitempty"`
-	Name        string `json:"name,omitempty"`
-	URL         string `json:"url,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	// RepositoryRef is the name of the HelmRepository to pull the chart from
+	RepositoryRef string `json:"repositoryRef,omitempty"`
+	Name          string `json:"name,omitempty"`
+	URL           string `json:"url,omitempty"`
+	// Path is a chart directory or archive relative to the file of the resource
+	Path        string `json:"path,omitempty"`
 	Version     string `json:"version"`
 	ReleaseName string `json:"releaseName"`
 	Namespace   string `json:"namespace,omitempty"`
@@ -29,9 +108,71 @@ type ChartSpec struct {
 
 // Custom validation function for HelmApplication
 func (c HelmApplication) Validate() error {
-	if c.Spec.Chart.URL == "" && (c.Spec.Chart.Repository == "" || c.Spec.Chart.Name == "") {
+	chart := c.Spec.Chart
+
+	if hooks := c.Spec.Hooks; hooks != nil {
+		switch hooks.Policy {
+		case "", HookPolicyInline, HookPolicySeparate, HookPolicyDrop:
+		default:
+			return fmt.Errorf(
+				"spec.hooks.policy must be one of %s, %s or %s",
+				HookPolicyInline,
+				HookPolicySeparate,
+				HookPolicyDrop,
+			)
+		}
+	}
+
+	if crds := c.Spec.CRDs; crds != nil {
+		switch crds.Policy {
+		case "", CRDPolicyInclude, CRDPolicySkip, CRDPolicySeparate:
+		default:
+			return fmt.Errorf(
+				"spec.crds.policy must be one of %s, %s or %s",
+				CRDPolicyInclude,
+				CRDPolicySkip,
+				CRDPolicySeparate,
+			)
+		}
+	}
+
+	if err := validatePatches(c.Spec.Patches); err != nil {
+		return err
+	}
+
+	if namespace := c.Spec.Namespace; namespace != nil {
+		if namespace.Create && chart.Namespace == "" {
+			return errors.New("spec.namespace.create requires spec.chart.namespace")
+		}
+
+		if !namespace.Create && (len(namespace.Labels) != 0 || len(namespace.Annotations) != 0) {
+			return errors.New(
+				"spec.namespace.labels and spec.namespace.annotations require spec.namespace.create",
+			)
+		}
+	}
+
+	if chart.Path != "" {
+		if chart.URL != "" || chart.Repository != "" || chart.RepositoryRef != "" ||
+			chart.Name != "" {
+			return errors.New(
+				"spec.chart.path cannot be combined with spec.chart.url, spec.chart.repository, spec.chart.repositoryRef or spec.chart.name",
+			)
+		}
+
+		return nil
+	}
+
+	if chart.Repository != "" && chart.RepositoryRef != "" {
+		return errors.New(
+			"spec.chart.repository and spec.chart.repositoryRef cannot both be provided",
+		)
+	}
+
+	if chart.URL == "" &&
+		((chart.Repository == "" && chart.RepositoryRef == "") || chart.Name == "") {
 		return errors.New(
-			"either spec.chart.url must be provided or both spec.chart.repository and spec.chart.name must be provided",
+			"either spec.chart.url, spec.chart.path or both spec.chart.repository, or spec.chart.repositoryRef, and spec.chart.name must be provided",
 		)
 	}
 
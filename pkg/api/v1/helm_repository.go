@@ -0,0 +1,92 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/komailo/kubeit/pkg/api"
+)
+
+// HelmRepository holds the settings used to pull charts from a chart repository or an
+// OCI registry. HelmApplications reference it with spec.chart.repositoryRef.
+type HelmRepository struct {
+	api.BaseObject `                   json:",inline"`
+	Spec           HelmRepositorySpec `json:"spec"`
+}
+
+type HelmRepositorySpec struct {
+	// URL of the chart repository, or of the registry path with an oci:// scheme
+	URL  string          `json:"url"            validate:"required"`
+	Auth *RepositoryAuth `json:"auth,omitempty"`
+	TLS  *RepositoryTLS  `json:"tls,omitempty"`
+	// PlainHTTP pulls from an OCI registry over HTTP instead of HTTPS
+	PlainHTTP bool `json:"plainHTTP,omitempty"`
+	// Proxy is the URL of the proxy to pull through. The proxy environment variables
+	// are used when it is empty.
+	Proxy string `json:"proxy,omitempty"`
+}
+
+// RepositoryAuth holds the credentials of a repository. Secrets are read from the
+// environment or from files so they are never part of the resources.
+type RepositoryAuth struct {
+	Username     string        `json:"username,omitempty"`
+	UsernameFrom *SecretSource `json:"usernameFrom,omitempty"`
+	PasswordFrom *SecretSource `json:"passwordFrom,omitempty"`
+	// PassCredentials also sends the credentials when a chart is downloaded from
+	// another domain than the repository
+	PassCredentials bool `json:"passCredentials,omitempty"`
+}
+
+// SecretSource reads a secret from an environment variable or a file
+type SecretSource struct {
+	Env  string `json:"env,omitempty"`
+	File string `json:"file,omitempty"`
+}
+
+// RepositoryTLS holds the TLS settings of a repository. Relative paths are resolved
+// against the file of the resource, absolute paths are read as they are. Only the CA
+// file is embedded in Docker images, the client certificate and key must be absolute
+// paths to be used from an image.
+type RepositoryTLS struct {
+	CAFile                string `json:"caFile,omitempty"`
+	CertFile              string `json:"certFile,omitempty"`
+	KeyFile               string `json:"keyFile,omitempty"`
+	InsecureSkipTLSVerify bool   `json:"insecureSkipTLSVerify,omitempty"`
+}
+
+// Custom validation function for HelmRepository
+func (c HelmRepository) Validate() error {
+	if c.Spec.URL == "" {
+		return errors.New("spec.url must be provided")
+	}
+
+	if auth := c.Spec.Auth; auth != nil {
+		if auth.Username != "" && auth.UsernameFrom != nil {
+			return errors.New(
+				"spec.auth.username and spec.auth.usernameFrom cannot both be provided",
+			)
+		}
+
+		if err := auth.UsernameFrom.validate("spec.auth.usernameFrom"); err != nil {
+			return err
+		}
+
+		if err := auth.PasswordFrom.validate("spec.auth.passwordFrom"); err != nil {
+			return err
+		}
+	}
+
+	if tls := c.Spec.TLS; tls != nil && (tls.CertFile == "") != (tls.KeyFile == "") {
+		return errors.New("spec.tls.certFile and spec.tls.keyFile must be provided together")
+	}
+
+	return nil
+}
+
+func (s *SecretSource) validate(field string) error {
+	if s != nil && (s.Env == "") == (s.File == "") {
+		return fmt.Errorf("exactly one of %s.env and %s.file must be provided", field, field)
+	}
+
+	return nil
+}
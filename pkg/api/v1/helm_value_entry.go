@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/komailo/kubeit/common"
 	"github.com/komailo/kubeit/pkg/api"
+	"github.com/komailo/kubeit/pkg/condition"
 	"github.com/komailo/kubeit/pkg/utils"
 )
 
@@ -19,6 +21,11 @@ var ValidValueTypes = []string{
 	"named",
 	"mapping",
 	"raw",
+	"string",
+	"json",
+	"file",
+	"literal",
+	"encrypted",
 }
 
 var typesWithNoData = []string{"named"}
@@ -35,6 +42,11 @@ type HelmValuesSpec struct {
 type ValueEntry struct {
 	Type string          `json:"type"           validate:"required"`
 	Data json.RawMessage `json:"data,omitempty"` // Handle different structures
+	// Sensitive masks every value of the entry in logs and errors
+	Sensitive bool `json:"sensitive,omitempty"`
+	// When is a condition evaluated against the render context, the entry is skipped
+	// when it is false
+	When string `json:"when,omitempty"`
 }
 
 type GenerateValueMappings map[string]string
@@ -67,6 +79,33 @@ func (c HelmValuesSpec) Validate() error {
 	return nil
 }
 
+// ErrSOPSNotSupported is returned for encrypted entries holding a SOPS document. Only
+// values documents encrypted as a whole with age are supported.
+var ErrSOPSNotSupported = errors.New(
+	"SOPS encrypted values are not supported, encrypt the whole values document with age " +
+		"and use the ASCII armored ciphertext as data",
+)
+
+// IsSOPSEncrypted reports whether the data of an encrypted entry is a SOPS document,
+// either as a map with sops metadata or as a string with ENC[...] values
+func IsSOPSEncrypted(data json.RawMessage) bool {
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(data, &document); err == nil {
+		_, ok := document["sops"]
+
+		return ok || strings.Contains(string(data), "ENC[")
+	}
+
+	var ciphertext string
+	if err := json.Unmarshal(data, &ciphertext); err != nil {
+		return false
+	}
+
+	return strings.Contains(ciphertext, "ENC[") ||
+		strings.HasPrefix(strings.TrimSpace(ciphertext), "sops:") ||
+		strings.Contains(ciphertext, "\nsops:")
+}
+
 // Custom unmarshal function for ValueEntry
 func (v *ValueEntry) UnmarshalJSON(data []byte) error {
 	// Define an alias to avoid infinite recursion
@@ -85,6 +124,12 @@ func (v *ValueEntry) UnmarshalJSON(data []byte) error {
 
 	v.Data = aux.Data // Preserve raw data for later decoding
 
+	if v.When != "" {
+		if _, err := condition.Parse(v.When); err != nil {
+			return fmt.Errorf("invalid when: %w", err)
+		}
+	}
+
 	// Validate Data type based on Type
 	switch v.Type {
 	case "mapping":
@@ -92,11 +137,30 @@ func (v *ValueEntry) UnmarshalJSON(data []byte) error {
 		if err := json.Unmarshal(v.Data, &mappings); err != nil {
 			return fmt.Errorf("invalid mappings format: %w", err)
 		}
+	case "string", "literal", "file":
+		var mappings map[string]string
+		if err := json.Unmarshal(v.Data, &mappings); err != nil {
+			return fmt.Errorf("invalid %s format: %w", v.Type, err)
+		}
+	case "json":
+		var jsonValues map[string]any
+		if err := json.Unmarshal(v.Data, &jsonValues); err != nil {
+			return fmt.Errorf("invalid json format: %w", err)
+		}
 	case "raw":
 		var raw map[string]any
 		if err := json.Unmarshal(v.Data, &raw); err != nil {
 			return fmt.Errorf("invalid raw format: %w", err)
 		}
+	case "encrypted":
+		if IsSOPSEncrypted(v.Data) {
+			return ErrSOPSNotSupported
+		}
+
+		var ciphertext string
+		if err := json.Unmarshal(v.Data, &ciphertext); err != nil {
+			return fmt.Errorf("invalid encrypted format, expected an age armored string: %w", err)
+		}
 	case "named":
 		if v.Data != nil {
 			return errors.New("named values must not have data")
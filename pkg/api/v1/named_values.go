@@ -11,4 +11,15 @@ type NamedValues struct {
 
 type NamedValuesSpec struct {
 	Values []ValueEntry `json:"values" validate:"required"`
+	// CapabilitiesRef is the name of the Capabilities profile of the cluster the
+	// values are for
+	CapabilitiesRef string `json:"capabilitiesRef,omitempty"`
+	// Patches are applied to the rendered objects of every application the values are
+	// used for, after the patches of the application
+	Patches []Patch `json:"patches,omitempty"`
+}
+
+// Custom validation function for NamedValues
+func (c NamedValues) Validate() error {
+	return validatePatches(c.Spec.Patches)
 }
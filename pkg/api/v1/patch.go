@@ -0,0 +1,42 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Patch modifies the rendered objects matching its target after Helm rendered them,
+// for what a chart has no value for
+type Patch struct {
+	Target PatchTarget `json:"target,omitempty"`
+	// StrategicMerge is a strategic merge patch, it falls back to a JSON merge patch
+	// for kinds that are not built into Kubernetes
+	StrategicMerge json.RawMessage `json:"strategicMerge,omitempty"`
+	// JSON6902 is a list of JSON patch operations
+	JSON6902 json.RawMessage `json:"json6902,omitempty"`
+}
+
+// PatchTarget selects the objects a patch applies to. Empty fields match every
+// object.
+type PatchTarget struct {
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// LabelSelector is a label selector, e.g. app=web,tier!=cache
+	LabelSelector string `json:"labelSelector,omitempty"`
+}
+
+// validatePatches checks that every patch has exactly one of its patch types
+func validatePatches(patches []Patch) error {
+	for i, patch := range patches {
+		if (len(patch.StrategicMerge) == 0) == (len(patch.JSON6902) == 0) {
+			return fmt.Errorf(
+				"exactly one of spec.patches[%d].strategicMerge and spec.patches[%d].json6902 must be provided",
+				i,
+				i,
+			)
+		}
+	}
+
+	return nil
+}
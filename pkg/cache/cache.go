@@ -0,0 +1,408 @@
+// Package cache stores Helm charts on disk so they are only downloaded once.
+//
+// Charts are stored as content-addressed blobs named after their sha256 digest. A ref
+// maps the repository, name and version of a chart to the digest of its blob. Files are
+// written to a temporary file and renamed into place, so concurrent runs sharing a
+// cache never see partially written charts.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// DirEnv overrides the default cache directory
+const DirEnv = "KUBEIT_CACHE_DIR"
+
+const (
+	blobsDir = "blobs/sha256"
+	refsDir  = "refs"
+)
+
+// Key identifies a chart in the cache. Charts referenced by URL have an empty name.
+type Key struct {
+	Repository string `json:"repository"`
+	Name       string `json:"name,omitempty"`
+	Version    string `json:"version"`
+}
+
+func (k Key) String() string {
+	if k.Name == "" {
+		return fmt.Sprintf("%s@%s", k.Repository, k.Version)
+	}
+
+	return fmt.Sprintf("%s/%s@%s", k.Repository, k.Name, k.Version)
+}
+
+// Entry is a chart stored in the cache
+type Entry struct {
+	Key
+	Digest   string    `json:"digest"`
+	Size     int64     `json:"size"`
+	LastUsed time.Time `json:"-"`
+}
+
+// Cache is a chart cache in a directory
+type Cache struct {
+	dir string
+}
+
+// DefaultDir returns the cache directory from $KUBEIT_CACHE_DIR, or the kubeit
+// directory in the user cache directory
+func DefaultDir() (string, error) {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir, nil
+	}
+
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the user cache directory: %w", err)
+	}
+
+	return filepath.Join(userCacheDir, "kubeit", "charts"), nil
+}
+
+// New returns the cache in dir, creating the directory when it does not exist
+func New(dir string) (*Cache, error) {
+	for _, subDir := range []string{blobsDir, refsDir} {
+		if err := os.MkdirAll(filepath.Join(dir, subDir), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create cache directory: %w", err)
+		}
+	}
+
+	return &Cache{dir: dir}, nil
+}
+
+// Open returns the cache in dir, or in the default directory when dir is empty
+func Open(dir string) (*Cache, error) {
+	if dir == "" {
+		var err error
+
+		dir, err = DefaultDir()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return New(dir)
+}
+
+// Dir returns the directory of the cache
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Get returns the path of a cached chart. The chart is verified against its digest
+// and removed from the cache when it does not match.
+func (c *Cache) Get(key Key) (string, bool, error) {
+	entry, err := c.readRef(c.refPath(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, err
+	}
+
+	return c.verify(entry)
+}
+
+// Find returns the cached chart with the highest version matching a version
+// constraint. An empty constraint matches any version.
+func (c *Cache) Find(repository, name, constraint string) (string, Entry, bool, error) {
+	var versionConstraint *semver.Constraints
+
+	if constraint != "" {
+		var err error
+
+		versionConstraint, err = semver.NewConstraint(constraint)
+		if err != nil {
+			return "", Entry{}, false, fmt.Errorf("invalid version constraint %s: %w", constraint, err)
+		}
+	}
+
+	entries, err := c.List()
+	if err != nil {
+		return "", Entry{}, false, err
+	}
+
+	var (
+		best        Entry
+		bestVersion *semver.Version
+	)
+
+	for _, entry := range entries {
+		if entry.Repository != repository || entry.Name != name {
+			continue
+		}
+
+		version, err := semver.NewVersion(entry.Version)
+		if err != nil {
+			continue
+		}
+
+		if versionConstraint != nil && !versionConstraint.Check(version) {
+			continue
+		}
+
+		if bestVersion == nil || version.GreaterThan(bestVersion) {
+			best = entry
+			bestVersion = version
+		}
+	}
+
+	if bestVersion == nil {
+		return "", Entry{}, false, nil
+	}
+
+	path, ok, err := c.verify(best)
+
+	return path, best, ok, err
+}
+
+// Put stores the chart at chartPath in the cache and returns the path of the cached
+// copy
+func (c *Cache) Put(key Key, chartPath string) (string, error) {
+	source, err := os.Open(chartPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open chart: %w", err)
+	}
+	defer source.Close()
+
+	blobs := filepath.Join(c.dir, blobsDir)
+
+	tempBlob, err := os.CreateTemp(blobs, ".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(tempBlob.Name())
+
+	hash := sha256.New()
+
+	size, err := io.Copy(io.MultiWriter(tempBlob, hash), source)
+	if closeErr := tempBlob.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	entry := Entry{Key: key, Digest: hex.EncodeToString(hash.Sum(nil)), Size: size}
+
+	if err := os.Chmod(tempBlob.Name(), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	if err := os.Rename(tempBlob.Name(), c.blobPath(entry.Digest)); err != nil {
+		return "", fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	encodedEntry, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cache ref: %w", err)
+	}
+
+	if err := writeFileAtomic(c.refPath(key), encodedEntry); err != nil {
+		return "", fmt.Errorf("failed to write cache ref: %w", err)
+	}
+
+	return c.blobPath(entry.Digest), nil
+}
+
+// List returns the cached charts ordered by key
+func (c *Cache) List() ([]Entry, error) {
+	refFiles, err := os.ReadDir(filepath.Join(c.dir, refsDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache refs: %w", err)
+	}
+
+	var entries []Entry
+
+	for _, refFile := range refFiles {
+		if refFile.IsDir() || !strings.HasSuffix(refFile.Name(), ".json") {
+			continue
+		}
+
+		entry, err := c.readRef(filepath.Join(c.dir, refsDir, refFile.Name()))
+		if err != nil {
+			// Refs removed by a concurrent prune are skipped
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key.String() < entries[j].Key.String()
+	})
+
+	return entries, nil
+}
+
+// Prune removes the charts that were not used for longer than olderThan, or every
+// chart when olderThan is 0, together with blobs no longer referenced
+func (c *Cache) Prune(olderThan time.Duration) ([]Entry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []Entry
+
+	referenced := map[string]bool{}
+
+	for _, entry := range entries {
+		if olderThan > 0 && time.Since(entry.LastUsed) < olderThan {
+			referenced[entry.Digest] = true
+			continue
+		}
+
+		if err := os.Remove(c.refPath(entry.Key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return pruned, fmt.Errorf("failed to remove cache ref of %s: %w", entry.Key, err)
+		}
+
+		pruned = append(pruned, entry)
+	}
+
+	blobFiles, err := os.ReadDir(filepath.Join(c.dir, blobsDir))
+	if err != nil {
+		return pruned, fmt.Errorf("failed to read cache blobs: %w", err)
+	}
+
+	for _, blobFile := range blobFiles {
+		digest := strings.TrimSuffix(blobFile.Name(), ".tgz")
+		if referenced[digest] || strings.HasPrefix(blobFile.Name(), ".tmp-") {
+			continue
+		}
+
+		err := os.Remove(filepath.Join(c.dir, blobsDir, blobFile.Name()))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return pruned, fmt.Errorf("failed to remove cache blob %s: %w", digest, err)
+		}
+	}
+
+	return pruned, nil
+}
+
+// verify checks the blob of an entry against its digest and marks the entry as used
+func (c *Cache) verify(entry Entry) (string, bool, error) {
+	blobPath := c.blobPath(entry.Digest)
+
+	digest, err := fileDigest(blobPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+
+	if err != nil {
+		return "", false, err
+	}
+
+	if digest != entry.Digest {
+		// The blob is corrupted, drop it so the chart is downloaded again
+		if err := os.Remove(blobPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", false, fmt.Errorf("failed to remove corrupted cache blob: %w", err)
+		}
+
+		return "", false, nil
+	}
+
+	now := time.Now()
+	if err := os.Chtimes(c.refPath(entry.Key), now, now); err != nil &&
+		!errors.Is(err, fs.ErrNotExist) {
+		return "", false, fmt.Errorf("failed to update cache ref: %w", err)
+	}
+
+	return blobPath, true, nil
+}
+
+func (c *Cache) readRef(refPath string) (Entry, error) {
+	data, err := os.ReadFile(refPath)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to read cache ref: %w", err)
+	}
+
+	info, err := os.Stat(refPath)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to read cache ref: %w", err)
+	}
+
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return Entry{}, fmt.Errorf("failed to decode cache ref %s: %w", refPath, err)
+	}
+
+	entry.LastUsed = info.ModTime()
+
+	return entry, nil
+}
+
+func (c *Cache) refPath(key Key) string {
+	hash := sha256.Sum256([]byte(key.Repository + "\x00" + key.Name + "\x00" + key.Version))
+
+	return filepath.Join(c.dir, refsDir, hex.EncodeToString(hash[:])+".json")
+}
+
+func (c *Cache) blobPath(digest string) string {
+	return filepath.Join(c.dir, blobsDir, digest+".tgz")
+}
+
+func fileDigest(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open cache blob: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read cache blob: %w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it into
+// place
+func writeFileAtomic(path string, data []byte) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(data)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	if err := os.Chmod(tempFile.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	if err := os.Rename(tempFile.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeChart(t *testing.T, content string) string {
+	t.Helper()
+
+	chartPath := filepath.Join(t.TempDir(), "chart.tgz")
+	require.NoError(t, os.WriteFile(chartPath, []byte(content), 0o644))
+
+	return chartPath
+}
+
+func TestCache_PutGet(t *testing.T) {
+	chartCache, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	key := Key{Repository: "https://charts.example.com", Name: "app", Version: "1.0.0"}
+
+	_, ok, err := chartCache.Get(key)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	cachedPath, err := chartCache.Put(key, writeChart(t, "chart"))
+	require.NoError(t, err)
+
+	path, ok, err := chartCache.Get(key)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, cachedPath, path)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "chart", string(content))
+
+	// Another version of the chart is a different entry
+	_, ok, err = chartCache.Get(Key{Repository: key.Repository, Name: key.Name, Version: "2.0.0"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestCache_GetCorrupted(t *testing.T) {
+	chartCache, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	key := Key{Repository: "https://charts.example.com", Name: "app", Version: "1.0.0"}
+
+	cachedPath, err := chartCache.Put(key, writeChart(t, "chart"))
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(cachedPath, []byte("tampered"), 0o644))
+
+	_, ok, err := chartCache.Get(key)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoFileExists(t, cachedPath)
+}
+
+func TestCache_Find(t *testing.T) {
+	chartCache, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	for _, version := range []string{"1.0.0", "1.2.0", "2.0.0"} {
+		key := Key{Repository: "https://charts.example.com", Name: "app", Version: version}
+
+		_, err := chartCache.Put(key, writeChart(t, version))
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		constraint string
+		version    string
+		found      bool
+	}{
+		{constraint: "", version: "2.0.0", found: true},
+		{constraint: "^1.0", version: "1.2.0", found: true},
+		{constraint: "~1.0.0", version: "1.0.0", found: true},
+		{constraint: ">=3.0.0", found: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			path, entry, ok, err := chartCache.Find("https://charts.example.com", "app", tt.constraint)
+			require.NoError(t, err)
+			assert.Equal(t, tt.found, ok)
+
+			if !tt.found {
+				return
+			}
+
+			assert.Equal(t, tt.version, entry.Version)
+
+			content, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.version, string(content))
+		})
+	}
+
+	_, _, _, err = chartCache.Find("https://charts.example.com", "app", "not a version")
+	assert.Error(t, err)
+}
+
+func TestCache_Prune(t *testing.T) {
+	chartCache, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	oldKey := Key{Repository: "https://charts.example.com", Name: "old", Version: "1.0.0"}
+	newKey := Key{Repository: "https://charts.example.com", Name: "new", Version: "1.0.0"}
+
+	oldPath, err := chartCache.Put(oldKey, writeChart(t, "old"))
+	require.NoError(t, err)
+
+	newPath, err := chartCache.Put(newKey, writeChart(t, "new"))
+	require.NoError(t, err)
+
+	lastUsed := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, os.Chtimes(chartCache.refPath(oldKey), lastUsed, lastUsed))
+
+	pruned, err := chartCache.Prune(24 * time.Hour)
+	require.NoError(t, err)
+	require.Len(t, pruned, 1)
+	assert.Equal(t, oldKey, pruned[0].Key)
+	assert.NoFileExists(t, oldPath)
+	assert.FileExists(t, newPath)
+
+	pruned, err = chartCache.Prune(0)
+	require.NoError(t, err)
+	require.Len(t, pruned, 1)
+	assert.NoFileExists(t, newPath)
+
+	entries, err := chartCache.List()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestCache_ConcurrentPut(t *testing.T) {
+	chartCache, err := New(t.TempDir())
+	require.NoError(t, err)
+
+	key := Key{Repository: "https://charts.example.com", Name: "app", Version: "1.0.0"}
+	chartPath := writeChart(t, "chart")
+
+	var wg sync.WaitGroup
+
+	for range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := chartCache.Put(key, chartPath)
+			assert.NoError(t, err)
+
+			_, ok, err := chartCache.Get(key)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}()
+	}
+
+	wg.Wait()
+
+	entries, err := chartCache.List()
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
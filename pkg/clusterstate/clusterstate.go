@@ -0,0 +1,357 @@
+// Package clusterstate serves a directory of Kubernetes objects as a read-only API
+// server, so Helm lookup calls return the same objects on every render without a
+// cluster.
+package clusterstate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// host is the address of the simulated API server, requests never leave the process
+const host = "http://cluster-state.kubeit.invalid"
+
+// State holds the objects of a simulated cluster
+type State struct {
+	objects []*unstructured.Unstructured
+}
+
+// Load reads the objects in the YAML and JSON files of a directory and its
+// subdirectories. Files can hold multiple documents and List objects.
+func Load(dir string) (*State, error) {
+	state := &State{}
+	seen := make(map[string]string)
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+
+		objects, err := readObjects(path)
+		if err != nil {
+			return err
+		}
+
+		for _, object := range objects {
+			if object.GetAPIVersion() == "" || object.GetKind() == "" || object.GetName() == "" {
+				return fmt.Errorf("object in %s must have an apiVersion, kind and name", path)
+			}
+
+			key := objectKey(object)
+			if previous, ok := seen[key]; ok {
+				return fmt.Errorf("%s in %s is already defined in %s", key, path, previous)
+			}
+
+			seen[key] = path
+			state.objects = append(state.objects, object)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cluster state: %w", err)
+	}
+
+	return state, nil
+}
+
+// readObjects decodes the objects of a file, expanding List objects into their items
+func readObjects(path string) ([]*unstructured.Unstructured, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*unstructured.Unstructured
+
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+
+	for {
+		object := &unstructured.Unstructured{}
+
+		err := decoder.Decode(&object.Object)
+		if errors.Is(err, io.EOF) {
+			return objects, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+
+		if len(object.Object) == 0 {
+			continue
+		}
+
+		if object.IsList() {
+			err := object.EachListItem(func(item runtime.Object) error {
+				itemObject, ok := item.(*unstructured.Unstructured)
+				if !ok {
+					return fmt.Errorf("unexpected list item %T", item)
+				}
+
+				objects = append(objects, itemObject)
+
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode list in %s: %w", path, err)
+			}
+
+			continue
+		}
+
+		objects = append(objects, object)
+	}
+}
+
+func objectKey(object *unstructured.Unstructured) string {
+	key := object.GetAPIVersion() + "/" + object.GetKind() + " "
+	if object.GetNamespace() != "" {
+		key += object.GetNamespace() + "/"
+	}
+
+	return key + object.GetName()
+}
+
+// RESTClientGetter returns a Helm REST client getter whose REST config is served by
+// the state. Only the REST config is available, Helm only uses it for lookup when
+// rendering client-only.
+func (s *State) RESTClientGetter() *RESTClientGetter {
+	return &RESTClientGetter{state: s}
+}
+
+// RESTClientGetter implements the REST client getter of Helm actions
+type RESTClientGetter struct {
+	state *State
+}
+
+func (g *RESTClientGetter) ToRESTConfig() (*rest.Config, error) {
+	return &rest.Config{Host: host, Transport: g.state}, nil
+}
+
+func (g *RESTClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	return nil, errors.New("the cluster state only serves lookups")
+}
+
+func (g *RESTClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	return nil, errors.New("the cluster state only serves lookups")
+}
+
+// RoundTrip serves the discovery, get and list requests of lookup calls
+func (s *State) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return statusResponse(
+			req,
+			http.StatusMethodNotAllowed,
+			metav1.StatusReasonMethodNotAllowed,
+			"the cluster state is read-only",
+		), nil
+	}
+
+	groupVersion, parts, ok := splitPath(req.URL.Path)
+	if !ok {
+		return statusResponse(req, http.StatusNotFound, metav1.StatusReasonNotFound,
+			"the server could not find the requested resource"), nil
+	}
+
+	resources := s.resources(groupVersion)
+
+	var namespace, resource, name string
+
+	switch {
+	case len(parts) == 0:
+		return jsonResponse(req, http.StatusOK, &metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+			GroupVersion: groupVersion.String(),
+			APIResources: resources,
+		}), nil
+	case len(parts) >= 3 && parts[0] == "namespaces":
+		namespace, resource = parts[1], parts[2]
+		parts = parts[3:]
+	default:
+		resource = parts[0]
+		parts = parts[1:]
+	}
+
+	if len(parts) == 1 {
+		name = parts[0]
+	}
+
+	kind := ""
+
+	for _, apiResource := range resources {
+		if apiResource.Name == resource {
+			kind = apiResource.Kind
+		}
+	}
+
+	if kind == "" || len(parts) > 1 {
+		return statusResponse(req, http.StatusNotFound, metav1.StatusReasonNotFound,
+			"the server could not find the requested resource"), nil
+	}
+
+	matched := s.find(groupVersion.WithKind(kind), namespace, name)
+
+	if name != "" {
+		if len(matched) == 0 {
+			return statusResponse(req, http.StatusNotFound, metav1.StatusReasonNotFound,
+				fmt.Sprintf("%s %q not found", resource, name)), nil
+		}
+
+		return jsonResponse(req, http.StatusOK, matched[0].Object), nil
+	}
+
+	items := make([]any, 0, len(matched))
+	for _, object := range matched {
+		items = append(items, object.Object)
+	}
+
+	return jsonResponse(req, http.StatusOK, map[string]any{
+		"apiVersion": groupVersion.String(),
+		"kind":       kind + "List",
+		"metadata":   map[string]any{},
+		"items":      items,
+	}), nil
+}
+
+// splitPath splits a request path into its group version and the path after it
+func splitPath(path string) (schema.GroupVersion, []string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		return schema.GroupVersion{Version: parts[1]}, parts[2:], true
+	case len(parts) >= 3 && parts[0] == "apis":
+		return schema.GroupVersion{Group: parts[1], Version: parts[2]}, parts[3:], true
+	default:
+		return schema.GroupVersion{}, nil, false
+	}
+}
+
+// resources returns the resources of a group version, the built-in kinds and the
+// kinds of the objects in the state. Every resource is reported as namespaced, objects
+// without a namespace are returned for any namespace, so lookups of cluster-scoped
+// objects work either way.
+func (s *State) resources(groupVersion schema.GroupVersion) []metav1.APIResource {
+	kinds := make(map[string]bool)
+
+	for kind := range scheme.Scheme.KnownTypes(groupVersion) {
+		kinds[kind] = true
+	}
+
+	for _, object := range s.objects {
+		if object.GroupVersionKind().GroupVersion() == groupVersion {
+			kinds[object.GetKind()] = true
+		}
+	}
+
+	names := make([]string, 0, len(kinds))
+	for kind := range kinds {
+		names = append(names, kind)
+	}
+
+	sort.Strings(names)
+
+	resources := make([]metav1.APIResource, 0, len(names))
+
+	for _, kind := range names {
+		plural, singular := meta.UnsafeGuessKindToResource(groupVersion.WithKind(kind))
+
+		resources = append(resources, metav1.APIResource{
+			Name:         plural.Resource,
+			SingularName: singular.Resource,
+			Namespaced:   true,
+			Kind:         kind,
+			Verbs:        metav1.Verbs{"get", "list"},
+		})
+	}
+
+	return resources
+}
+
+// find returns the objects of a kind in a namespace, with the given name when it is
+// not empty. An empty namespace matches every namespace.
+func (s *State) find(
+	gvk schema.GroupVersionKind,
+	namespace, name string,
+) []*unstructured.Unstructured {
+	var matched []*unstructured.Unstructured
+
+	for _, object := range s.objects {
+		if object.GroupVersionKind() != gvk {
+			continue
+		}
+
+		if namespace != "" && object.GetNamespace() != "" && object.GetNamespace() != namespace {
+			continue
+		}
+
+		if name != "" && object.GetName() != name {
+			continue
+		}
+
+		matched = append(matched, object)
+	}
+
+	return matched
+}
+
+func jsonResponse(req *http.Request, statusCode int, body any) *http.Response {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return statusResponse(req, http.StatusInternalServerError,
+			metav1.StatusReasonInternalError, err.Error())
+	}
+
+	return &http.Response{
+		StatusCode: statusCode,
+		Status:     http.StatusText(statusCode),
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+		Request:    req,
+	}
+}
+
+func statusResponse(
+	req *http.Request,
+	statusCode int,
+	reason metav1.StatusReason,
+	message string,
+) *http.Response {
+	return jsonResponse(req, statusCode, &metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   metav1.StatusFailure,
+		Code:     int32(statusCode),
+		Reason:   reason,
+		Message:  message,
+	})
+}
@@ -0,0 +1,156 @@
+package clusterstate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+func writeState(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		wantObjects int
+		wantErr     string
+	}{
+		{
+			name: "documents, lists and json",
+			files: map[string]string{
+				"secrets.yaml": `apiVersion: v1
+kind: Secret
+metadata:
+  name: web
+  namespace: apps
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: web
+      namespace: apps
+  - apiVersion: v1
+    kind: Namespace
+    metadata:
+      name: apps
+`,
+				"nested/monitor.json": `{"apiVersion": "monitoring.coreos.com/v1", "kind": "ServiceMonitor", "metadata": {"name": "web", "namespace": "apps"}}`,
+				"README.md":           "not an object",
+			},
+			wantObjects: 4,
+		},
+		{
+			name: "duplicate object",
+			files: map[string]string{
+				"a.yaml": "apiVersion: v1\nkind: Secret\nmetadata:\n  name: web\n  namespace: apps\n",
+				"b.yaml": "apiVersion: v1\nkind: Secret\nmetadata:\n  name: web\n  namespace: apps\n",
+			},
+			wantErr: "v1/Secret apps/web in",
+		},
+		{
+			name:    "object without name",
+			files:   map[string]string{"a.yaml": "apiVersion: v1\nkind: Secret\n"},
+			wantErr: "must have an apiVersion, kind and name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := Load(writeState(t, tt.files))
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Len(t, state.objects, tt.wantObjects)
+		})
+	}
+}
+
+func TestState_RoundTrip(t *testing.T) {
+	state, err := Load(writeState(t, map[string]string{"state.yaml": `apiVersion: v1
+kind: Secret
+metadata:
+  name: web
+  namespace: apps
+data:
+  password: c2VjcmV0
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: api
+  namespace: other
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: apps
+`}))
+	require.NoError(t, err)
+
+	config, err := state.RESTClientGetter().ToRESTConfig()
+	require.NoError(t, err)
+
+	client, err := dynamic.NewForConfig(config)
+	require.NoError(t, err)
+
+	secrets := client.Resource(schema.GroupVersionResource{Version: "v1", Resource: "secrets"})
+	ctx := context.Background()
+
+	secret, err := secrets.Namespace("apps").Get(ctx, "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "c2VjcmV0", secret.Object["data"].(map[string]any)["password"])
+
+	_, err = secrets.Namespace("other").Get(ctx, "web", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err), "Expected a not found error, got %v", err)
+
+	list, err := secrets.Namespace("apps").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, list.Items, 1)
+
+	list, err = secrets.List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, list.Items, 2)
+
+	namespaces := client.Resource(schema.GroupVersionResource{Version: "v1", Resource: "namespaces"})
+
+	namespace, err := namespaces.Get(ctx, "apps", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "apps", namespace.GetName())
+
+	// Kinds without objects are known so lookups of them return nothing
+	configMaps := client.Resource(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+
+	list, err = configMaps.Namespace("apps").List(ctx, metav1.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, list.Items)
+
+	err = secrets.Namespace("apps").Delete(ctx, "web", metav1.DeleteOptions{})
+	assert.True(t, apierrors.IsMethodNotSupported(err), "Expected the state to be read-only, got %v", err)
+}
@@ -0,0 +1,567 @@
+// Package condition implements the small expression language used by the when clause
+// of value entries.
+//
+// An expression compares identifiers from the render context with string literals:
+//
+//	kubeVersion >= "1.29"
+//	namedValues contains "production" && namespace != "kube-system"
+//	!(sourceScheme == "docker") || variables.dockerImageTag matches "^v[0-9]+"
+//
+// The identifiers are namedValues, kubeVersion, namespace, sourceScheme and
+// variables.<name>. Ordering operators compare versions when both sides are versions
+// and strings otherwise.
+package condition
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// Context holds the values that expressions are evaluated against
+type Context struct {
+	NamedValues  []string
+	KubeVersion  string
+	Namespace    string
+	SourceScheme string
+	Variables    map[string]string
+}
+
+// Expression is a parsed condition
+type Expression struct {
+	source string
+	root   node
+}
+
+// Parse parses a condition expression
+func Parse(expression string) (*Expression, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %w", expression, err)
+	}
+
+	p := &parser{tokens: tokens}
+
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = fmt.Errorf("unexpected %s", p.peek())
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %w", expression, err)
+	}
+
+	return &Expression{source: expression, root: root}, nil
+}
+
+// Evaluate parses and evaluates a condition expression
+func Evaluate(expression string, ctx Context) (bool, error) {
+	parsed, err := Parse(expression)
+	if err != nil {
+		return false, err
+	}
+
+	return parsed.Evaluate(ctx)
+}
+
+// Evaluate evaluates the expression against the context
+func (e *Expression) Evaluate(ctx Context) (bool, error) {
+	value, err := e.root.eval(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to evaluate condition %q: %w", e.source, err)
+	}
+
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("condition %q does not evaluate to a boolean", e.source)
+	}
+
+	return result, nil
+}
+
+// String returns the source of the expression
+func (e *Expression) String() string {
+	return e.source
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenOperator
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of condition"
+	case tokenString:
+		return fmt.Sprintf("string %q", t.value)
+	default:
+		return fmt.Sprintf("%q", t.value)
+	}
+}
+
+var symbolOperators = []string{"&&", "||", "==", "!=", ">=", "<=", ">", "<", "!"}
+
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(expression); {
+		c := expression[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, value: "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, value: ")"})
+			i++
+		case c == '"' || c == '\'':
+			value, next, err := readString(expression, i)
+			if err != nil {
+				return nil, err
+			}
+
+			tokens = append(tokens, token{kind: tokenString, value: value})
+			i = next
+		case isIdentChar(c):
+			start := i
+			for i < len(expression) && isIdentChar(expression[i]) {
+				i++
+			}
+
+			word := expression[start:i]
+			if word == "contains" || word == "matches" {
+				tokens = append(tokens, token{kind: tokenOperator, value: word})
+			} else {
+				tokens = append(tokens, token{kind: tokenIdent, value: word})
+			}
+		default:
+			operator := ""
+
+			for _, candidate := range symbolOperators {
+				if strings.HasPrefix(expression[i:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+
+			if operator == "" {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+
+			tokens = append(tokens, token{kind: tokenOperator, value: operator})
+			i += len(operator)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '.' || c == '-' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func readString(expression string, start int) (string, int, error) {
+	quote := expression[start]
+
+	var value strings.Builder
+
+	for i := start + 1; i < len(expression); i++ {
+		switch expression[i] {
+		case '\\':
+			if i+1 < len(expression) {
+				i++
+				value.WriteByte(expression[i])
+			}
+		case quote:
+			return value.String(), i + 1, nil
+		default:
+			value.WriteByte(expression[i])
+		}
+	}
+
+	return "", 0, errors.New("unterminated string")
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) acceptOperator(operators ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOperator {
+		return "", false
+	}
+
+	for _, operator := range operators {
+		if t.value == operator {
+			p.next()
+			return operator, true
+		}
+	}
+
+	return "", false
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.acceptOperator("||"); !ok {
+			return left, nil
+		}
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = logicalNode{operator: "||", left: left, right: right}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.acceptOperator("&&"); !ok {
+			return left, nil
+		}
+
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		left = logicalNode{operator: "&&", left: left, right: right}
+	}
+}
+
+func (p *parser) parseNot() (node, error) {
+	if _, ok := p.acceptOperator("!"); ok {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return notNode{operand: operand}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	operator, ok := p.acceptOperator("==", "!=", ">=", "<=", ">", "<", "contains", "matches")
+	if !ok {
+		return left, nil
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	if operator == "matches" {
+		literal, ok := right.(literalNode)
+		if !ok {
+			return nil, errors.New("matches must be followed by a string")
+		}
+
+		pattern, err := regexp.Compile(literal.value.(string))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern: %w", err)
+		}
+
+		return matchNode{operand: left, pattern: pattern}, nil
+	}
+
+	return comparisonNode{operator: operator, left: left, right: right}, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, fmt.Errorf("expected \")\" but found %s", closing)
+		}
+
+		return inner, nil
+	case tokenString:
+		return literalNode{value: t.value}, nil
+	case tokenIdent:
+		switch {
+		case t.value == "true" || t.value == "false":
+			return literalNode{value: t.value == "true"}, nil
+		case t.value[0] >= '0' && t.value[0] <= '9':
+			// Bare numbers such as 1.29 are compared as strings
+			return literalNode{value: t.value}, nil
+		case isKnownIdentifier(t.value):
+			return identNode{name: t.value}, nil
+		default:
+			return nil, fmt.Errorf("unknown identifier %q", t.value)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected %s", t)
+	}
+}
+
+const variablesPrefix = "variables."
+
+func isKnownIdentifier(name string) bool {
+	switch name {
+	case "namedValues", "kubeVersion", "namespace", "sourceScheme":
+		return true
+	}
+
+	return strings.HasPrefix(name, variablesPrefix) && len(name) > len(variablesPrefix)
+}
+
+type node interface {
+	eval(ctx Context) (any, error)
+}
+
+type literalNode struct {
+	value any
+}
+
+func (n literalNode) eval(_ Context) (any, error) {
+	return n.value, nil
+}
+
+type identNode struct {
+	name string
+}
+
+func (n identNode) eval(ctx Context) (any, error) {
+	switch n.name {
+	case "namedValues":
+		return ctx.NamedValues, nil
+	case "kubeVersion":
+		return ctx.KubeVersion, nil
+	case "namespace":
+		return ctx.Namespace, nil
+	case "sourceScheme":
+		return ctx.SourceScheme, nil
+	}
+
+	// Unknown variables evaluate to an empty string
+	return ctx.Variables[strings.TrimPrefix(n.name, variablesPrefix)], nil
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n notNode) eval(ctx Context) (any, error) {
+	value, err := evalBool(n.operand, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return !value, nil
+}
+
+type logicalNode struct {
+	operator string
+	left     node
+	right    node
+}
+
+func (n logicalNode) eval(ctx Context) (any, error) {
+	left, err := evalBool(n.left, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short circuit like most languages do
+	if (n.operator == "&&" && !left) || (n.operator == "||" && left) {
+		return left, nil
+	}
+
+	return evalBool(n.right, ctx)
+}
+
+type matchNode struct {
+	operand node
+	pattern *regexp.Regexp
+}
+
+func (n matchNode) eval(ctx Context) (any, error) {
+	value, err := evalString(n.operand, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.pattern.MatchString(value), nil
+}
+
+type comparisonNode struct {
+	operator string
+	left     node
+	right    node
+}
+
+func (n comparisonNode) eval(ctx Context) (any, error) {
+	left, err := n.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := n.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.operator == "contains" {
+		return evalContains(left, right)
+	}
+
+	if list, ok := left.([]string); ok {
+		return nil, fmt.Errorf("%s can not be used with a list %v", n.operator, list)
+	}
+
+	if leftBool, ok := left.(bool); ok {
+		rightBool, ok := right.(bool)
+		if !ok || (n.operator != "==" && n.operator != "!=") {
+			return nil, fmt.Errorf("%s can not compare %v with %v", n.operator, left, right)
+		}
+
+		return (leftBool == rightBool) == (n.operator == "=="), nil
+	}
+
+	leftString, leftOk := left.(string)
+	rightString, rightOk := right.(string)
+
+	if !leftOk || !rightOk {
+		return nil, fmt.Errorf("%s can not compare %v with %v", n.operator, left, right)
+	}
+
+	result := compare(leftString, rightString)
+
+	switch n.operator {
+	case "==":
+		return result == 0, nil
+	case "!=":
+		return result != 0, nil
+	case ">":
+		return result > 0, nil
+	case ">=":
+		return result >= 0, nil
+	case "<":
+		return result < 0, nil
+	default:
+		return result <= 0, nil
+	}
+}
+
+// compare compares two versions when both sides parse as versions, and two strings
+// otherwise
+func compare(left, right string) int {
+	leftVersion, leftErr := semver.NewVersion(left)
+	rightVersion, rightErr := semver.NewVersion(right)
+
+	if leftErr == nil && rightErr == nil {
+		return leftVersion.Compare(rightVersion)
+	}
+
+	return strings.Compare(left, right)
+}
+
+func evalContains(left, right any) (any, error) {
+	item, ok := right.(string)
+	if !ok {
+		return nil, fmt.Errorf("contains expects a string but found %v", right)
+	}
+
+	switch container := left.(type) {
+	case []string:
+		for _, element := range container {
+			if element == item {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	case string:
+		return strings.Contains(container, item), nil
+	default:
+		return nil, fmt.Errorf("contains can not be used with %v", left)
+	}
+}
+
+func evalBool(n node, ctx Context) (bool, error) {
+	value, err := n.eval(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expected a boolean but found %v", value)
+	}
+
+	return result, nil
+}
+
+func evalString(n node, ctx Context) (string, error) {
+	value, err := n.eval(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	result, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("expected a string but found %v", value)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,89 @@
+package condition
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	ctx := Context{
+		NamedValues:  []string{"staging", "eu-west"},
+		KubeVersion:  "v1.29.3",
+		Namespace:    "apps",
+		SourceScheme: "docker",
+		Variables: map[string]string{
+			"dockerImageTag": "v2.1.0",
+		},
+	}
+
+	testCases := []struct {
+		expression string
+		expected   bool
+	}{
+		{`kubeVersion >= "1.29"`, true},
+		{`kubeVersion < "1.29"`, false},
+		{`kubeVersion > 1.28.10`, true},
+		{`kubeVersion == "1.29.3"`, true},
+		{`namedValues contains "staging"`, true},
+		{`namedValues contains "production"`, false},
+		{`!(namedValues contains "production")`, true},
+		{`namespace == 'apps' && sourceScheme == "docker"`, true},
+		{`namespace != "apps" || sourceScheme == "file"`, false},
+		{`namespace contains "pp"`, true},
+		{`variables.dockerImageTag matches "^v2\\."`, true},
+		{`variables.missing == ""`, true},
+		{`true && !false`, true},
+		{`"b" > "a"`, true},
+		{`namedValues contains "production" || kubeVersion >= "1.29" && namespace == "apps"`, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expression, func(t *testing.T) {
+			result, err := Evaluate(tc.expression, ctx)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	testCases := []string{
+		``,
+		`kubeVersion >=`,
+		`unknown == "x"`,
+		`namespace == "apps`,
+		`(namespace == "apps"`,
+		`namespace == "apps")`,
+		`namespace ~ "apps"`,
+		`namespace matches "("`,
+		`namespace matches kubeVersion`,
+	}
+
+	for _, expression := range testCases {
+		t.Run(expression, func(t *testing.T) {
+			_, err := Parse(expression)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestEvaluate_Errors(t *testing.T) {
+	ctx := Context{NamedValues: []string{"staging"}, Namespace: "apps"}
+
+	testCases := []string{
+		`namespace`,
+		`namedValues == "staging"`,
+		`namespace contains true`,
+		`!namespace`,
+		`true > false`,
+	}
+
+	for _, expression := range testCases {
+		t.Run(expression, func(t *testing.T) {
+			_, err := Evaluate(expression, ctx)
+			require.Error(t, err)
+		})
+	}
+}
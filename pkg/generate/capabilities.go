@@ -0,0 +1,214 @@
+package generate
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	"k8s.io/client-go/discovery"
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/komailo/kubeit/common"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+// CapabilitiesKind is the kind of a capabilities profile
+const CapabilitiesKind = "Capabilities"
+
+// renderCapabilities holds the Kubernetes version and API versions charts are
+// rendered against
+type renderCapabilities struct {
+	// KubeVersion is nil when the Helm default is used
+	KubeVersion *chartutil.KubeVersion
+	APIVersions []string
+	// ClusterScopedKinds are the cluster-scoped kinds of the profile, as API version
+	// and kind
+	ClusterScopedKinds []string
+}
+
+// resolveCapabilities returns the capabilities to render with. The profile of the last
+// selected NamedValues referencing one is used first, a capabilities file replaces it
+// and --kube-version and --api-versions take precedence over both.
+func resolveCapabilities(
+	loaderInt *loader.Loader,
+	generateSetOptions *Options,
+) (renderCapabilities, error) {
+	var profile v1.CapabilitiesSpec
+
+	for _, name := range generateSetOptions.NamedValues {
+		namedValues := loader.FindResourcesByName(loaderInt.NamedValues, []string{name})
+
+		for _, namedValue := range namedValues {
+			capabilitiesRef := namedValue.Spec.CapabilitiesRef
+			if capabilitiesRef == "" {
+				continue
+			}
+
+			profiles := loader.FindResourcesByName(
+				loaderInt.Capabilities,
+				[]string{capabilitiesRef},
+			)
+			if len(profiles) == 0 {
+				return renderCapabilities{}, fmt.Errorf(
+					"Capabilities %s referenced by NamedValues %s not found",
+					capabilitiesRef,
+					name,
+				)
+			}
+
+			profile = profiles[0].Spec
+		}
+	}
+
+	if generateSetOptions.CapabilitiesFile != "" {
+		fileProfile, err := LoadCapabilitiesFile(generateSetOptions.CapabilitiesFile)
+		if err != nil {
+			return renderCapabilities{}, err
+		}
+
+		profile = fileProfile.Spec
+	}
+
+	if generateSetOptions.KubeVersion != "" {
+		profile.KubeVersion = generateSetOptions.KubeVersion
+	}
+
+	capabilities := renderCapabilities{
+		APIVersions: append(
+			append([]string{}, profile.APIVersions...),
+			generateSetOptions.APIVersions...,
+		),
+		ClusterScopedKinds: profile.ClusterScopedKinds,
+	}
+
+	if profile.KubeVersion != "" {
+		kubeVersion, err := chartutil.ParseKubeVersion(profile.KubeVersion)
+		if err != nil {
+			return renderCapabilities{}, fmt.Errorf(
+				"invalid kube version '%s': %w",
+				profile.KubeVersion,
+				err,
+			)
+		}
+
+		capabilities.KubeVersion = kubeVersion
+	}
+
+	return capabilities, nil
+}
+
+// kubeVersion returns the Kubernetes version charts are rendered against
+func (c renderCapabilities) kubeVersion() string {
+	if c.KubeVersion == nil {
+		return chartutil.DefaultCapabilities.KubeVersion.Version
+	}
+
+	return c.KubeVersion.Version
+}
+
+// LoadCapabilitiesFile reads a capabilities profile, a Capabilities resource usually
+// exported from a cluster with kubeit capabilities export
+func LoadCapabilitiesFile(path string) (*v1.Capabilities, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capabilities file: %w", err)
+	}
+
+	capabilities := &v1.Capabilities{}
+	if err := k8syaml.UnmarshalStrict(data, capabilities); err != nil {
+		return nil, fmt.Errorf("failed to decode capabilities file %s: %w", path, err)
+	}
+
+	if capabilities.Kind != CapabilitiesKind ||
+		capabilities.APIVersion != common.APIVersionV1Alpha1 {
+		return nil, fmt.Errorf(
+			"capabilities file %s must be a %s resource of %s",
+			path,
+			CapabilitiesKind,
+			common.APIVersionV1Alpha1,
+		)
+	}
+
+	if err := capabilities.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid capabilities file %s: %w", path, err)
+	}
+
+	return capabilities, nil
+}
+
+// ExportCapabilities returns the capabilities of the cluster of the current kubeconfig
+// context as a Capabilities profile with the given name
+func ExportCapabilities(name string) ([]byte, error) {
+	discoveryClient, err := cli.New().RESTClientGetter().ToDiscoveryClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+
+	discoveryClient.Invalidate()
+
+	serverVersion, err := discoveryClient.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the Kubernetes version: %w", err)
+	}
+
+	apiVersions, err := action.GetVersionSet(discoveryClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the API versions: %w", err)
+	}
+
+	sort.Strings(apiVersions)
+
+	clusterScopedKinds, err := clusterScopedKindsOf(discoveryClient)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := k8syaml.Marshal(map[string]any{
+		"apiVersion": common.APIVersionV1Alpha1,
+		"kind":       CapabilitiesKind,
+		"metadata":   map[string]any{"name": name},
+		"spec": v1.CapabilitiesSpec{
+			KubeVersion:        serverVersion.GitVersion,
+			APIVersions:        apiVersions,
+			ClusterScopedKinds: clusterScopedKinds,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode capabilities: %w", err)
+	}
+
+	return data, nil
+}
+
+// clusterScopedKindsOf returns the kinds a cluster serves without a namespace, as API
+// version and kind. Groups that fail discovery are left out, the same way
+// action.GetVersionSet leaves them out of the API versions.
+func clusterScopedKindsOf(discoveryClient discovery.DiscoveryInterface) ([]string, error) {
+	_, resourceLists, err := discovery.ServerGroupsAndResources(discoveryClient)
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("failed to get the API resources: %w", err)
+	}
+
+	var kinds []string
+
+	for _, resourceList := range resourceLists {
+		for _, resource := range resourceList.APIResources {
+			// Subresources, e.g. namespaces/status, have the kind of their resource
+			if resource.Namespaced || strings.Contains(resource.Name, "/") {
+				continue
+			}
+
+			kinds = append(kinds, resourceList.GroupVersion+"/"+resource.Kind)
+		}
+	}
+
+	sort.Strings(kinds)
+
+	return slices.Compact(kinds), nil
+}
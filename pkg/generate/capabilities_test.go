@@ -0,0 +1,215 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/komailo/kubeit/pkg/api/loader"
+)
+
+const capabilitiesResources = `apiVersion: kubeit.komailo.github.io/v1alpha1
+kind: Capabilities
+metadata:
+  name: production-cluster
+spec:
+  kubeVersion: v1.30.1
+  apiVersions:
+    - monitoring.coreos.com/v1
+---
+apiVersion: kubeit.komailo.github.io/v1alpha1
+kind: NamedValues
+metadata:
+  name: production
+spec:
+  capabilitiesRef: production-cluster
+  values: []
+---
+apiVersion: kubeit.komailo.github.io/v1alpha1
+kind: NamedValues
+metadata:
+  name: missing
+spec:
+  capabilitiesRef: missing
+  values: []
+`
+
+func TestResolveCapabilities(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeFiles(t, sourceDir, map[string]string{"resources.yaml": capabilitiesResources})
+
+	loaderInt := loader.NewLoader()
+	require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+
+	capabilitiesFile := filepath.Join(t.TempDir(), "capabilities.yaml")
+	require.NoError(t, os.WriteFile(capabilitiesFile, []byte(`apiVersion: kubeit.komailo.github.io/v1alpha1
+kind: Capabilities
+metadata:
+  name: staging
+spec:
+  kubeVersion: v1.29.0
+  apiVersions:
+    - cert-manager.io/v1
+  clusterScopedKinds:
+    - cert-manager.io/v1/ClusterIssuer
+`), 0o644))
+
+	tests := []struct {
+		name                   string
+		options                Options
+		wantKubeVersion        string
+		wantAPIVersions        []string
+		wantClusterScopedKinds []string
+		wantErr                string
+	}{
+		{
+			name:            "defaults",
+			wantKubeVersion: "v1.20.0",
+			wantAPIVersions: []string{},
+		},
+		{
+			name:            "flags",
+			options:         Options{KubeVersion: "1.31", APIVersions: []string{"example.com/v1"}},
+			wantKubeVersion: "v1.31.0",
+			wantAPIVersions: []string{"example.com/v1"},
+		},
+		{
+			name:            "named values profile",
+			options:         Options{NamedValues: []string{"production"}},
+			wantKubeVersion: "v1.30.1",
+			wantAPIVersions: []string{"monitoring.coreos.com/v1"},
+		},
+		{
+			name: "capabilities file and flags take precedence",
+			options: Options{
+				NamedValues:      []string{"production"},
+				CapabilitiesFile: capabilitiesFile,
+				KubeVersion:      "v1.32.0",
+				APIVersions:      []string{"example.com/v1"},
+			},
+			wantKubeVersion:        "v1.32.0",
+			wantAPIVersions:        []string{"cert-manager.io/v1", "example.com/v1"},
+			wantClusterScopedKinds: []string{"cert-manager.io/v1/ClusterIssuer"},
+		},
+		{
+			name:    "missing profile",
+			options: Options{NamedValues: []string{"missing"}},
+			wantErr: "Capabilities missing referenced by NamedValues missing not found",
+		},
+		{
+			name:    "invalid kube version",
+			options: Options{KubeVersion: "latest"},
+			wantErr: "invalid kube version 'latest'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capabilities, err := resolveCapabilities(loaderInt, &tt.options)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantKubeVersion, capabilities.kubeVersion())
+			assert.Equal(t, tt.wantAPIVersions, capabilities.APIVersions)
+			assert.Equal(t, tt.wantClusterScopedKinds, capabilities.ClusterScopedKinds)
+		})
+	}
+}
+
+func TestLoadCapabilitiesFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			content: `apiVersion: kubeit.komailo.github.io/v1alpha1
+kind: Capabilities
+metadata:
+  name: cluster
+spec:
+  kubeVersion: v1.30.0
+`,
+		},
+		{
+			name:    "wrong kind",
+			content: "apiVersion: kubeit.komailo.github.io/v1alpha1\nkind: NamedValues\n",
+			wantErr: "must be a Capabilities resource",
+		},
+		{
+			name: "unknown field",
+			content: `apiVersion: kubeit.komailo.github.io/v1alpha1
+kind: Capabilities
+spec:
+  apiVersion: [example.com/v1]
+`,
+			wantErr: "failed to decode capabilities file",
+		},
+		{
+			name: "empty profile",
+			content: `apiVersion: kubeit.komailo.github.io/v1alpha1
+kind: Capabilities
+spec: {}
+`,
+			wantErr: "either spec.kubeVersion or spec.apiVersions must be provided",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "capabilities.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o644))
+
+			capabilities, err := LoadCapabilitiesFile(path)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "v1.30.0", capabilities.Spec.KubeVersion)
+		})
+	}
+}
+
+func TestHelmRenderer_Manifest_Capabilities(t *testing.T) {
+	sourceDir := t.TempDir()
+
+	writeFiles(t, sourceDir, map[string]string{
+		"app.yaml":              localChartApplication,
+		"charts/web/Chart.yaml": "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+		"charts/web/templates/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+data:
+  kubeVersion: {{ .Capabilities.KubeVersion.Version }}
+  monitoring: "{{ .Capabilities.APIVersions.Has "monitoring.coreos.com/v1" }}"
+`,
+	})
+
+	loaderInt := loader.NewLoader()
+	require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+
+	outputDir := t.TempDir()
+	renderer := newTestRenderer(t, t.TempDir(), true)
+
+	require.NoError(t, renderer.manifest(loaderInt.HelmApplications[0], loaderInt, &Options{
+		OutputDir:   outputDir,
+		WorkDir:     t.TempDir(),
+		KubeVersion: "1.30.2",
+		APIVersions: []string{"monitoring.coreos.com/v1"},
+	}))
+
+	manifest, err := os.ReadFile(filepath.Join(outputDir, "web.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(manifest), "kubeVersion: v1.30.2\n")
+	assert.Contains(t, string(manifest), "monitoring: \"true\"\n")
+}
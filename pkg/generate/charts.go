@@ -0,0 +1,367 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"helm.sh/helm/v3/pkg/chart"
+	helmChartLoader "helm.sh/helm/v3/pkg/chart/loader"
+
+	"github.com/komailo/kubeit/internal/logger"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+	"github.com/komailo/kubeit/pkg/cache"
+	"github.com/komailo/kubeit/pkg/lock"
+)
+
+// ErrOffline is returned when a chart has to be downloaded and offline mode is enabled
+var ErrOffline = errors.New("offline mode is enabled")
+
+// fetchHelmChart returns the path of the chart of a HelmApplication. Charts with an
+// exact version are taken from the cache when present, any other chart is pulled
+// into destinationDir and added to the cache. In offline mode the chart is only
+// taken from the cache.
+func (r *helmRenderer) fetchHelmChart(
+	chartRepo *chartRepository,
+	repository, name, url, version string,
+	destinationDir string,
+) (string, error) {
+	cacheRepository := repository
+	if url != "" {
+		cacheRepository = url
+		name = ""
+	}
+
+	exactVersion := isExactVersion(version)
+
+	if exactVersion {
+		key := cache.Key{
+			Repository: cacheRepository,
+			Name:       name,
+			Version:    strings.TrimPrefix(version, "v"),
+		}
+
+		chartPath, ok, err := r.charts.Get(key)
+		if err != nil {
+			return "", err
+		}
+
+		if ok {
+			logger.Infof("Using cached chart %s", key)
+			return chartPath, nil
+		}
+	}
+
+	if r.offline {
+		if exactVersion {
+			return "", fmt.Errorf(
+				"chart %s is not in the cache: %w",
+				describeChart(cacheRepository, name, version),
+				ErrOffline,
+			)
+		}
+
+		chartPath, entry, ok, err := r.charts.Find(cacheRepository, name, version)
+		if err != nil {
+			return "", err
+		}
+
+		if !ok {
+			return "", fmt.Errorf(
+				"chart %s is not in the cache: %w",
+				describeChart(cacheRepository, name, version),
+				ErrOffline,
+			)
+		}
+
+		logger.Infof("Using cached chart %s", entry.Key)
+
+		return chartPath, nil
+	}
+
+	chartPath, err := pullHelmChart(
+		r.settings,
+		chartRepo,
+		repository,
+		name,
+		url,
+		version,
+		destinationDir,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	// The version is only known once the chart is pulled when a range is requested
+	metadata, err := helmChartLoader.Load(chartPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to load Helm chart: %w", err)
+	}
+
+	key := cache.Key{
+		Repository: cacheRepository,
+		Name:       name,
+		Version:    metadata.Metadata.Version,
+	}
+
+	if _, err := r.charts.Put(key, chartPath); err != nil {
+		// The chart was pulled, so a cache failure only costs a download next time
+		logger.Warnf("Failed to add chart %s to the cache: %v", key, err)
+	}
+
+	return chartPath, nil
+}
+
+// resolveChart fetches and loads the chart of a HelmApplication. Unless updateLock is
+// set, a locked chart is fetched at its locked version and verified against its locked
+// digest. The returned application is the chart as it resolved, local charts are part
+// of the source and are not locked. Errors are returned as an AppError of the pull or
+// load stage.
+func (r *helmRenderer) resolveChart(
+	helmApplication *v1.HelmApplication,
+	loaderInt *loader.Loader,
+	updateLock bool,
+	destinationDir string,
+) (*chart.Chart, lock.Application, error) {
+	spec := helmApplication.Spec.Chart
+	name := helmApplication.Metadata.Name
+	version := spec.Version
+	lockFile := loaderInt.Lock
+
+	if spec.Path != "" {
+		helmChart, err := r.localChart(helmApplication, loaderInt, destinationDir)
+		if err != nil {
+			return nil, lock.Application{}, newAppError(name, StageLoad, err)
+		}
+
+		return helmChart, lock.Application{}, nil
+	}
+
+	locked, isLocked := lockFile.Find(name)
+
+	if lockFile != nil && !updateLock {
+		if !isLocked {
+			return nil, lock.Application{}, newAppError(name, StagePull, errors.New(
+				"not in the lock file, run kubeit lock to update it",
+			))
+		}
+
+		if locked.Chart != lock.ChartOf(spec) {
+			return nil, lock.Application{}, newAppError(name, StagePull, errors.New(
+				"the chart changed since it was locked, run kubeit lock to update it",
+			))
+		}
+
+		version = locked.Version
+	}
+
+	repository := spec.Repository
+
+	repositoryURL, chartRepo, err := r.chartRepository(spec.RepositoryRef, loaderInt)
+	if err != nil {
+		return nil, lock.Application{}, newAppError(name, StagePull, err)
+	}
+
+	if spec.RepositoryRef != "" {
+		repository = repositoryURL
+	}
+
+	chartPath, err := r.fetchHelmChart(
+		chartRepo,
+		repository,
+		spec.Name,
+		spec.URL,
+		version,
+		destinationDir,
+	)
+	if err != nil {
+		return nil, lock.Application{}, newAppError(name, StagePull, err)
+	}
+
+	digest, err := lock.Digest(chartPath)
+	if err != nil {
+		return nil, lock.Application{}, newAppError(name, StagePull, err)
+	}
+
+	if lockFile != nil && !updateLock && digest != locked.Digest {
+		return nil, lock.Application{}, newAppError(name, StagePull, fmt.Errorf(
+			"chart %s has digest %s but %s is locked",
+			describeChartSpec(spec, version),
+			digest,
+			locked.Digest,
+		))
+	}
+
+	helmChart, err := helmChartLoader.Load(chartPath)
+	if err != nil {
+		return nil, lock.Application{}, newAppError(
+			name,
+			StageLoad,
+			fmt.Errorf("failed to load Helm chart: %w", err),
+		)
+	}
+
+	return helmChart, lock.Application{
+		Name:    name,
+		Chart:   lock.ChartOf(spec),
+		Version: helmChart.Metadata.Version,
+		Digest:  digest,
+	}, nil
+}
+
+// Lock resolves the chart of every HelmApplication and writes the resolved versions
+// and digests to the lock file in the source root
+func Lock(generateSetOptions *Options) ([]error, map[string][]error) {
+	sourceConfigURI := generateSetOptions.SourceConfigURI
+	logger.Infof("Locking the charts of %s", sourceConfigURI)
+
+	loaderInt := loader.NewLoader()
+	loaderErr := loaderInt.FromSourceURI(sourceConfigURI)
+
+	if len(loaderErr) != 0 {
+		return nil, loaderErr
+	}
+
+	if loaderInt.LockPath() == "" {
+		return []error{errors.New("only file sources can be locked")}, nil
+	}
+
+	if len(loaderInt.HelmApplications) == 0 {
+		return []error{errors.New("no HelmApplication resources found")}, nil
+	}
+
+	renderer, err := newHelmRenderer(generateSetOptions)
+	if err != nil {
+		return []error{err}, nil
+	}
+
+	workDir, err := os.MkdirTemp("", "kubeit-lock-")
+	if err != nil {
+		return []error{fmt.Errorf("failed to create work directory: %w", err)}, nil
+	}
+	defer os.RemoveAll(workDir)
+
+	lockFile := lock.New()
+
+	var errs []error
+
+	for i, helmApplication := range loaderInt.HelmApplications {
+		if helmApplication.Spec.Chart.Path != "" {
+			logger.Infof("Not locking %s, its chart is part of the source", helmApplication.Metadata.Name)
+			continue
+		}
+
+		_, resolved, err := renderer.resolveChart(
+			helmApplication,
+			loaderInt,
+			true,
+			filepath.Join(workDir, fmt.Sprint(i)),
+		)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		logger.Infof(
+			"Locked %s to chart version %s (%s)",
+			resolved.Name,
+			resolved.Version,
+			resolved.Digest,
+		)
+
+		lockFile.Set(resolved)
+	}
+
+	if len(errs) != 0 {
+		return errs, nil
+	}
+
+	if err := lockFile.Write(loaderInt.LockPath()); err != nil {
+		return []error{err}, nil
+	}
+
+	return nil, nil
+}
+
+// WarmCache pulls the chart of every HelmApplication into the chart cache, at the
+// locked version when the source has a lock file
+func WarmCache(generateSetOptions *Options) ([]error, map[string][]error) {
+	sourceConfigURI := generateSetOptions.SourceConfigURI
+	logger.Infof("Warming the chart cache from %s", sourceConfigURI)
+
+	loaderInt := loader.NewLoader()
+	loaderErr := loaderInt.FromSourceURI(sourceConfigURI)
+
+	if len(loaderErr) != 0 {
+		return nil, loaderErr
+	}
+
+	if len(loaderInt.HelmApplications) == 0 {
+		return []error{errors.New("no HelmApplication resources found")}, nil
+	}
+
+	renderer, err := newHelmRenderer(generateSetOptions)
+	if err != nil {
+		return []error{err}, nil
+	}
+
+	workDir, err := os.MkdirTemp("", "kubeit-cache-")
+	if err != nil {
+		return []error{fmt.Errorf("failed to create work directory: %w", err)}, nil
+	}
+	defer os.RemoveAll(workDir)
+
+	var errs []error
+
+	for i, helmApplication := range loaderInt.HelmApplications {
+		if helmApplication.Spec.Chart.Path != "" {
+			continue
+		}
+
+		_, _, err := renderer.resolveChart(
+			helmApplication,
+			loaderInt,
+			false,
+			filepath.Join(workDir, fmt.Sprint(i)),
+		)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs, nil
+}
+
+// isExactVersion reports whether a chart version is a single version rather than a
+// range
+func isExactVersion(version string) bool {
+	_, err := semver.StrictNewVersion(strings.TrimPrefix(version, "v"))
+
+	return err == nil
+}
+
+// describeChartSpec describes the chart of a chart spec at a version
+func describeChartSpec(spec v1.ChartSpec, version string) string {
+	if spec.URL != "" {
+		return describeChart(spec.URL, "", version)
+	}
+
+	return describeChart(spec.Repository, spec.Name, version)
+}
+
+func describeChart(repository, name, version string) string {
+	chart := repository
+	if name != "" {
+		chart = fmt.Sprintf("%s from %s", name, repository)
+	}
+
+	if version != "" {
+		chart = fmt.Sprintf("%s version %s", chart, version)
+	}
+
+	return chart
+}
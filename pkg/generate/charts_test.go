@@ -0,0 +1,279 @@
+package generate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/repo"
+
+	"github.com/komailo/kubeit/pkg/api"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+	"github.com/komailo/kubeit/pkg/lock"
+)
+
+// chartRepositoryHandler serves a chart repository with the versions of a chart
+func chartRepositoryHandler(t *testing.T, name string, versions ...string) http.Handler {
+	t.Helper()
+
+	repositoryDir := t.TempDir()
+	index := repo.NewIndexFile()
+
+	for _, version := range versions {
+		metadata := &chart.Metadata{APIVersion: chart.APIVersionV2, Name: name, Version: version}
+
+		chartPath, err := chartutil.Save(&chart.Chart{Metadata: metadata}, repositoryDir)
+		require.NoError(t, err)
+
+		require.NoError(t, index.MustAdd(metadata, filepath.Base(chartPath), "", "sha256:unused"))
+	}
+
+	require.NoError(t, index.WriteFile(filepath.Join(repositoryDir, "index.yaml"), 0o644))
+
+	return http.FileServer(http.Dir(repositoryDir))
+}
+
+// serveChartRepository serves a chart repository with the versions of a chart and
+// counts the requests it receives
+func serveChartRepository(t *testing.T, name string, versions ...string) (string, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+
+	handler := chartRepositoryHandler(t, name, versions...)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL, &requests
+}
+
+func newTestRenderer(t *testing.T, cacheDir string, offline bool) *helmRenderer {
+	t.Helper()
+
+	helmHome := t.TempDir()
+	t.Setenv("HELM_CACHE_HOME", filepath.Join(helmHome, "cache"))
+	t.Setenv("HELM_CONFIG_HOME", filepath.Join(helmHome, "config"))
+	t.Setenv("HELM_DATA_HOME", filepath.Join(helmHome, "data"))
+
+	renderer, err := newHelmRenderer(&Options{CacheDir: cacheDir, Offline: offline})
+	require.NoError(t, err)
+
+	return renderer
+}
+
+func TestHelmRenderer_FetchHelmChart(t *testing.T) {
+	repositoryURL, requests := serveChartRepository(t, "app", "1.0.0", "1.1.0")
+	cacheDir := t.TempDir()
+
+	renderer := newTestRenderer(t, cacheDir, false)
+
+	chartPath, err := renderer.fetchHelmChart(
+		renderer.defaultRepository,
+		repositoryURL, "app", "", "1.0.0", filepath.Join(t.TempDir(), "charts"),
+	)
+	require.NoError(t, err)
+	assert.FileExists(t, chartPath)
+
+	pulled := requests.Load()
+	assert.Positive(t, pulled)
+
+	// The exact version is now served from the cache without touching the repository
+	chartPath, err = renderer.fetchHelmChart(
+		renderer.defaultRepository,
+		repositoryURL, "app", "", "1.0.0", filepath.Join(t.TempDir(), "charts"),
+	)
+	require.NoError(t, err)
+	assert.FileExists(t, chartPath)
+	assert.Equal(t, pulled, requests.Load())
+
+	// Version ranges are resolved against the repository and cached as the resolved
+	// version
+	_, err = renderer.fetchHelmChart(
+		renderer.defaultRepository,
+		repositoryURL, "app", "", "^1.0", filepath.Join(t.TempDir(), "charts"),
+	)
+	require.NoError(t, err)
+	assert.Greater(t, requests.Load(), pulled)
+
+	entries, err := renderer.charts.List()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "1.0.0", entries[0].Version)
+	assert.Equal(t, "1.1.0", entries[1].Version)
+}
+
+func TestHelmRenderer_FetchHelmChart_Offline(t *testing.T) {
+	repositoryURL, requests := serveChartRepository(t, "app", "1.0.0")
+	cacheDir := t.TempDir()
+
+	onlineRenderer := newTestRenderer(t, cacheDir, false)
+
+	_, err := onlineRenderer.fetchHelmChart(
+		onlineRenderer.defaultRepository,
+		repositoryURL, "app", "", "1.0.0", filepath.Join(t.TempDir(), "charts"),
+	)
+	require.NoError(t, err)
+
+	pulled := requests.Load()
+	renderer := newTestRenderer(t, cacheDir, true)
+
+	tests := []struct {
+		name    string
+		version string
+		wantErr bool
+	}{
+		{name: "cached version", version: "1.0.0"},
+		{name: "range matching a cached version", version: "~1.0"},
+		{name: "uncached version", version: "2.0.0", wantErr: true},
+		{name: "range without cached versions", version: ">=2.0.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chartPath, err := renderer.fetchHelmChart(
+				renderer.defaultRepository,
+				repositoryURL, "app", "", tt.version, filepath.Join(t.TempDir(), "charts"),
+			)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrOffline)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.FileExists(t, chartPath)
+		})
+	}
+
+	assert.Equal(t, pulled, requests.Load())
+}
+
+func TestHelmRenderer_ResolveChart(t *testing.T) {
+	repositoryURL, _ := serveChartRepository(t, "app", "1.0.0", "1.1.0")
+	renderer := newTestRenderer(t, t.TempDir(), false)
+
+	helmApplication := &v1.HelmApplication{
+		BaseObject: api.BaseObject{Metadata: api.ObjectMeta{Name: "web"}},
+		Spec: v1.HelmApplicationSpec{
+			Chart: v1.ChartSpec{Repository: repositoryURL, Name: "app", Version: "^1.0"},
+		},
+	}
+
+	// Without a lock file the range resolves to the latest version
+	helmChart, resolved, err := renderer.resolveChart(
+		helmApplication,
+		loader.NewLoader(),
+		false,
+		t.TempDir(),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.0", helmChart.Metadata.Version)
+	assert.Equal(t, "1.1.0", resolved.Version)
+	assert.Equal(t, lock.ChartOf(helmApplication.Spec.Chart), resolved.Chart)
+
+	_, pinned, err := renderer.resolveChart(
+		&v1.HelmApplication{
+			BaseObject: helmApplication.BaseObject,
+			Spec: v1.HelmApplicationSpec{
+				Chart: v1.ChartSpec{Repository: repositoryURL, Name: "app", Version: "1.0.0"},
+			},
+		},
+		loader.NewLoader(),
+		false,
+		t.TempDir(),
+	)
+	require.NoError(t, err)
+
+	lockedApplication := pinned
+	lockedApplication.Chart = resolved.Chart
+
+	lockFile := lock.New()
+	lockFile.Set(lockedApplication)
+
+	tests := []struct {
+		name        string
+		lockFile    *lock.Lock
+		chart       v1.ChartSpec
+		updateLock  bool
+		wantVersion string
+		wantErr     string
+	}{
+		{
+			name:        "locked version",
+			lockFile:    lockFile,
+			chart:       helmApplication.Spec.Chart,
+			wantVersion: "1.0.0",
+		},
+		{
+			name:        "update lock",
+			lockFile:    lockFile,
+			chart:       helmApplication.Spec.Chart,
+			updateLock:  true,
+			wantVersion: "1.1.0",
+		},
+		{
+			name:     "chart changed since locked",
+			lockFile: lockFile,
+			chart:    v1.ChartSpec{Repository: repositoryURL, Name: "app", Version: "^1.1"},
+			wantErr:  "the chart changed since it was locked",
+		},
+		{
+			name:     "not locked",
+			lockFile: lock.New(),
+			chart:    helmApplication.Spec.Chart,
+			wantErr:  "not in the lock file",
+		},
+		{
+			name: "digest mismatch",
+			lockFile: &lock.Lock{Applications: []lock.Application{{
+				Name:    "web",
+				Chart:   resolved.Chart,
+				Version: "1.0.0",
+				Digest:  "sha256:tampered",
+			}}},
+			chart:   helmApplication.Spec.Chart,
+			wantErr: "but sha256:tampered is locked",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			application := &v1.HelmApplication{
+				BaseObject: helmApplication.BaseObject,
+				Spec:       v1.HelmApplicationSpec{Chart: tt.chart},
+			}
+
+			loaderInt := loader.NewLoader()
+			loaderInt.Lock = tt.lockFile
+
+			helmChart, resolved, err := renderer.resolveChart(
+				application,
+				loaderInt,
+				tt.updateLock,
+				t.TempDir(),
+			)
+			if tt.wantErr != "" {
+				var appError *AppError
+
+				require.ErrorAs(t, err, &appError)
+				assert.Equal(t, StagePull, appError.Stage)
+				assert.Contains(t, err.Error(), tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVersion, helmChart.Metadata.Version)
+			assert.Equal(t, tt.wantVersion, resolved.Version)
+		})
+	}
+}
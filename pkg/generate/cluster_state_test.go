@@ -0,0 +1,92 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/komailo/kubeit/pkg/api/loader"
+)
+
+// lookupSecretTemplate keeps the password of an existing secret, as charts that
+// generate secrets do
+const lookupSecretTemplate = `{{- $existing := lookup "v1" "Secret" "apps" "web" }}
+apiVersion: v1
+kind: Secret
+metadata:
+  name: web
+data:
+  password: {{ if $existing }}{{ index $existing.data "password" }}{{ else }}Z2VuZXJhdGVk{{ end }}
+  services: "{{ len (default (list) (lookup "v1" "Service" "apps" "").items) }}"
+`
+
+func TestHelmRenderer_Manifest_ClusterState(t *testing.T) {
+	sourceDir := t.TempDir()
+
+	writeFiles(t, sourceDir, map[string]string{
+		"app.yaml":                         localChartApplication,
+		"charts/web/Chart.yaml":            "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+		"charts/web/templates/secret.yaml": lookupSecretTemplate,
+	})
+
+	clusterStateDir := t.TempDir()
+	writeFiles(t, clusterStateDir, map[string]string{"apps/secret.yaml": `apiVersion: v1
+kind: Secret
+metadata:
+  name: web
+  namespace: apps
+data:
+  password: ZXhpc3Rpbmc=
+`})
+
+	tests := []struct {
+		name            string
+		clusterStateDir string
+		wantPassword    string
+	}{
+		{name: "without cluster state", wantPassword: "Z2VuZXJhdGVk"},
+		{name: "with cluster state", clusterStateDir: clusterStateDir, wantPassword: "ZXhpc3Rpbmc="},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaderInt := loader.NewLoader()
+			require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+
+			// Sets the Helm directories of the test
+			newTestRenderer(t, t.TempDir(), true)
+
+			outputDir := t.TempDir()
+			generateSetOptions := &Options{
+				OutputDir:       outputDir,
+				WorkDir:         t.TempDir(),
+				CacheDir:        t.TempDir(),
+				Offline:         true,
+				ClusterStateDir: tt.clusterStateDir,
+			}
+
+			renderer, err := newHelmRenderer(generateSetOptions)
+			require.NoError(t, err)
+
+			require.NoError(t, renderer.manifest(
+				loaderInt.HelmApplications[0],
+				loaderInt,
+				generateSetOptions,
+			))
+
+			manifest, err := os.ReadFile(filepath.Join(outputDir, "web.yaml"))
+			require.NoError(t, err)
+			assert.Contains(t, string(manifest), "password: "+tt.wantPassword+"\n")
+			assert.Contains(t, string(manifest), "services: \"0\"\n")
+		})
+	}
+
+	_, err := newHelmRenderer(&Options{
+		CacheDir:        t.TempDir(),
+		ClusterStateDir: filepath.Join(t.TempDir(), "missing"),
+	})
+	assert.ErrorContains(t, err, "failed to load cluster state")
+}
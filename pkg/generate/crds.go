@@ -0,0 +1,98 @@
+package generate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+// crdKind is the kind of a CustomResourceDefinition
+const crdKind = "CustomResourceDefinition"
+
+// chartCRDs returns the CRDs in the crds directories of a chart and its dependencies
+// as a multi-document manifest, together with the names of the CRDs it defines
+func chartCRDs(helmChart *chart.Chart) (string, []string, error) {
+	var (
+		manifests strings.Builder
+		names     []string
+	)
+
+	for _, crd := range helmChart.CRDObjects() {
+		documents := releaseutil.SplitManifests(string(crd.File.Data))
+
+		keys := make([]string, 0, len(documents))
+		for key := range documents {
+			keys = append(keys, key)
+		}
+
+		sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+		for _, key := range keys {
+			var object struct {
+				Kind     string `json:"kind"`
+				Metadata struct {
+					Name string `json:"name"`
+				} `json:"metadata"`
+			}
+
+			if err := k8syaml.Unmarshal([]byte(documents[key]), &object); err != nil {
+				return "", nil, fmt.Errorf("failed to decode CRD %s: %w", crd.Filename, err)
+			}
+
+			if object.Kind == crdKind {
+				names = append(names, object.Metadata.Name)
+			}
+		}
+
+		fmt.Fprintf(&manifests, "---\n# Source: %s\n%s\n", crd.Filename, crd.File.Data)
+	}
+
+	return manifests.String(), names, nil
+}
+
+// recordCRDs records the CRDs an application writes so duplicates can be detected
+func (r *helmRenderer) recordCRDs(appName string, names []string) {
+	r.crdsMu.Lock()
+	defer r.crdsMu.Unlock()
+
+	for _, name := range names {
+		r.crds[name] = append(r.crds[name], appName)
+	}
+}
+
+// duplicateCRDErrors returns an error for every CRD written by more than one
+// application, as the copies would conflict when they are applied
+func (r *helmRenderer) duplicateCRDErrors() []error {
+	r.crdsMu.Lock()
+	defer r.crdsMu.Unlock()
+
+	names := make([]string, 0, len(r.crds))
+	for name := range r.crds {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var errs []error
+
+	for _, name := range names {
+		appNames := r.crds[name]
+		if len(appNames) < 2 {
+			continue
+		}
+
+		sort.Strings(appNames)
+
+		errs = append(errs, fmt.Errorf(
+			"CRD %s is written by the applications %s, set spec.crds.policy to Skip for all but one",
+			name,
+			strings.Join(appNames, ", "),
+		))
+	}
+
+	return errs
+}
@@ -0,0 +1,179 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/komailo/kubeit/pkg/api/loader"
+)
+
+const widgetCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+`
+
+// writeCRDChart writes a chart with a CRD and an object using it
+func writeCRDChart(t *testing.T, sourceDir string) {
+	t.Helper()
+
+	writeFiles(t, sourceDir, map[string]string{
+		"charts/web/Chart.yaml":       "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+		"charts/web/crds/widget.yaml": widgetCRD,
+		"charts/web/templates/widget.yaml": `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: web
+`,
+	})
+}
+
+func TestHelmRenderer_Manifest_CRDs(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeCRDChart(t, sourceDir)
+
+	tests := []struct {
+		name         string
+		crds         string
+		wantCRDs     bool
+		wantCRDsFile bool
+	}{
+		{name: "include", wantCRDs: true},
+		{name: "skip", crds: "  crds:\n    policy: Skip\n"},
+		{name: "separate", crds: "  crds:\n    policy: Separate\n", wantCRDsFile: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeFiles(t, sourceDir, map[string]string{"app.yaml": localChartApplication + tt.crds})
+
+			loaderInt := loader.NewLoader()
+			require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+			require.Len(t, loaderInt.HelmApplications, 1)
+
+			outputDir := t.TempDir()
+			renderer := newTestRenderer(t, t.TempDir(), true)
+
+			require.NoError(t, renderer.manifest(
+				loaderInt.HelmApplications[0],
+				loaderInt,
+				&Options{OutputDir: outputDir, WorkDir: t.TempDir()},
+			))
+
+			manifest, err := os.ReadFile(filepath.Join(outputDir, "web.yaml"))
+			require.NoError(t, err)
+
+			crdIndex := strings.Index(string(manifest), "kind: CustomResourceDefinition")
+			if tt.wantCRDs {
+				require.NotEqual(t, -1, crdIndex)
+				assert.Less(
+					t,
+					crdIndex,
+					strings.Index(string(manifest), "kind: Widget"),
+					"Expected the CRD before the objects using it",
+				)
+			} else {
+				assert.Equal(t, -1, crdIndex)
+			}
+
+			crds, err := os.ReadFile(filepath.Join(outputDir, "crds", "web.yaml"))
+			if tt.wantCRDsFile {
+				require.NoError(t, err)
+				assert.True(t, strings.HasPrefix(string(crds), "---\n# Source: web/crds/widget.yaml\n"))
+				assert.Contains(t, string(crds), "name: widgets.example.com\n")
+			} else {
+				require.ErrorIs(t, err, os.ErrNotExist)
+			}
+		})
+	}
+}
+
+func TestManifestsFromHelm_DuplicateCRDs(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeCRDChart(t, sourceDir)
+
+	writeFiles(t, sourceDir, map[string]string{
+		"app.yaml": localChartApplication + "---\n" +
+			strings.NewReplacer("name: web", "name: api", "releaseName: web", "releaseName: api").
+				Replace(localChartApplication),
+	})
+
+	// Sets the Helm directories of the test
+	newTestRenderer(t, t.TempDir(), true)
+
+	outputDir := t.TempDir()
+
+	render := func(t *testing.T) []error {
+		t.Helper()
+
+		loaderInt := loader.NewLoader()
+		require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+		require.Len(t, loaderInt.HelmApplications, 2)
+
+		return ManifestsFromHelm(loaderInt, &Options{
+			OutputDir: outputDir,
+			WorkDir:   t.TempDir(),
+			CacheDir:  t.TempDir(),
+			Offline:   true,
+		})
+	}
+
+	errs := render(t)
+	require.Len(t, errs, 1)
+	assert.EqualError(
+		t,
+		errs[0],
+		"CRD widgets.example.com is written by the applications api, web, set spec.crds.policy to Skip for all but one",
+	)
+	assert.Empty(t, readOutputDir(t, outputDir), "Expected nothing to be written")
+
+	writeFiles(t, sourceDir, map[string]string{
+		"app.yaml": localChartApplication + "  crds:\n    policy: Skip\n---\n" +
+			strings.NewReplacer("name: web", "name: api", "releaseName: web", "releaseName: api").
+				Replace(localChartApplication),
+	})
+
+	assert.Empty(t, render(t))
+	assert.FileExists(t, filepath.Join(outputDir, "web.yaml"))
+}
+
+func TestManifestsFromHelm_DuplicateCRDsOfFailedApplication(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeCRDChart(t, sourceDir)
+
+	// The api application writes the same CRD and a Namespace, but fails to render
+	application := localChartApplication + "    namespace: apps\n  namespace:\n    create: true\n"
+
+	writeFiles(t, sourceDir, map[string]string{
+		"app.yaml": application + "---\n" +
+			strings.NewReplacer("name: web", "name: api", "releaseName: web", "releaseName: api").
+				Replace(application) +
+			"  patches:\n    - target:\n        labelSelector: \"app in (web\"\n      strategicMerge: {}\n",
+	})
+
+	loaderInt := loader.NewLoader()
+	require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+	require.Len(t, loaderInt.HelmApplications, 2)
+
+	// Sets the Helm directories of the test
+	newTestRenderer(t, t.TempDir(), true)
+
+	outputDir := t.TempDir()
+
+	errs := ManifestsFromHelm(loaderInt, &Options{
+		OutputDir:       outputDir,
+		WorkDir:         t.TempDir(),
+		CacheDir:        t.TempDir(),
+		Offline:         true,
+		ContinueOnError: true,
+	})
+	require.Len(t, errs, 1, "Expected the failed application not to count as a duplicate")
+	assert.ErrorContains(t, errs[0], "invalid label selector")
+	assert.FileExists(t, filepath.Join(outputDir, "web.yaml"))
+	assert.NoFileExists(t, filepath.Join(outputDir, "api.yaml"))
+}
@@ -0,0 +1,126 @@
+package generate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	k8syaml "sigs.k8s.io/yaml"
+
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+const (
+	// AgeKeyEnv holds an age identity used to decrypt encrypted values
+	AgeKeyEnv = "KUBEIT_AGE_KEY"
+	// AgeKeyFileEnv holds the path of a file with age identities used to decrypt
+	// encrypted values
+	AgeKeyFileEnv = "KUBEIT_AGE_KEY_FILE"
+)
+
+// loadAgeIdentities loads the age identities used to decrypt encrypted values. The
+// --age-key-file flag takes precedence over the KUBEIT_AGE_KEY and KUBEIT_AGE_KEY_FILE
+// environment variables.
+func loadAgeIdentities(generateSetOptions *Options) ([]age.Identity, error) {
+	var keys io.Reader
+
+	switch {
+	case generateSetOptions.AgeKeyFile != "":
+		keyFile, err := os.Open(generateSetOptions.AgeKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open age key file: %w", err)
+		}
+		defer keyFile.Close()
+
+		keys = keyFile
+	case os.Getenv(AgeKeyEnv) != "":
+		keys = strings.NewReader(os.Getenv(AgeKeyEnv))
+	case os.Getenv(AgeKeyFileEnv) != "":
+		keyFile, err := os.Open(os.Getenv(AgeKeyFileEnv))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open age key file from %s: %w", AgeKeyFileEnv, err)
+		}
+		defer keyFile.Close()
+
+		keys = keyFile
+	default:
+		return nil, fmt.Errorf(
+			"no age key to decrypt encrypted values, use --age-key-file, %s or %s",
+			AgeKeyEnv,
+			AgeKeyFileEnv,
+		)
+	}
+
+	identities, err := age.ParseIdentities(keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse age identities: %w", err)
+	}
+
+	return identities, nil
+}
+
+// decryptValues decrypts the ASCII armored age ciphertext of an encrypted entry and
+// parses the plaintext as a YAML values document. The plaintext is only held in
+// memory. SOPS documents with ENC[...] values are not supported.
+func decryptValues(data json.RawMessage, identities []age.Identity) (map[string]any, error) {
+	if v1.IsSOPSEncrypted(data) {
+		return nil, v1.ErrSOPSNotSupported
+	}
+
+	var ciphertext string
+	if err := json.Unmarshal(data, &ciphertext); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal encrypted data: %w", err)
+	}
+
+	if !strings.HasPrefix(strings.TrimSpace(ciphertext), armor.Header) {
+		return nil, errors.New("encrypted data must be an ASCII armored age file")
+	}
+
+	plaintextReader, err := age.Decrypt(
+		armor.NewReader(strings.NewReader(strings.TrimSpace(ciphertext))),
+		identities...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt values: %w", err)
+	}
+
+	plaintext, err := io.ReadAll(plaintextReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt values: %w", err)
+	}
+
+	values := map[string]any{}
+	if err := k8syaml.Unmarshal(plaintext, &values); err != nil {
+		// The parser error can quote the plaintext so it is not wrapped
+		return nil, errors.New("failed to parse decrypted values as a YAML map")
+	}
+
+	return values, nil
+}
+
+// mergeMaps deep merges src into dst, with values from src taking precedence. This is
+// the same way Helm merges values files.
+func mergeMaps(dst, src map[string]any) map[string]any {
+	out := make(map[string]any, len(dst))
+	for key, value := range dst {
+		out[key] = value
+	}
+
+	for key, value := range src {
+		if srcMap, ok := value.(map[string]any); ok {
+			if dstMap, ok := out[key].(map[string]any); ok {
+				out[key] = mergeMaps(dstMap, srcMap)
+				continue
+			}
+		}
+
+		out[key] = value
+	}
+
+	return out
+}
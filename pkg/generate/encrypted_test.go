@@ -0,0 +1,151 @@
+package generate
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+func encryptForTest(t *testing.T, recipient age.Recipient, plaintext string) json.RawMessage {
+	t.Helper()
+
+	var ciphertext bytes.Buffer
+
+	armorWriter := armor.NewWriter(&ciphertext)
+
+	encryptWriter, err := age.Encrypt(armorWriter, recipient)
+	require.NoError(t, err)
+
+	_, err = encryptWriter.Write([]byte(plaintext))
+	require.NoError(t, err)
+	require.NoError(t, encryptWriter.Close())
+	require.NoError(t, armorWriter.Close())
+
+	data, err := json.Marshal(ciphertext.String())
+	require.NoError(t, err)
+
+	return data
+}
+
+func TestDecryptValues(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	otherIdentity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	data := encryptForTest(t, identity.Recipient(), "database:\n  password: s3cr3t\n")
+
+	values, err := decryptValues(data, []age.Identity{identity})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"database": map[string]any{"password": "s3cr3t"}}, values)
+
+	_, err = decryptValues(data, []age.Identity{otherIdentity})
+	require.Error(t, err, "Expected an error when decrypting with the wrong identity")
+
+	_, err = decryptValues(json.RawMessage(`"database: plaintext"`), []age.Identity{identity})
+	require.Error(t, err, "Expected an error for data that is not age encrypted")
+
+	invalidYaml := encryptForTest(t, identity.Recipient(), "- s3cr3t")
+
+	_, err = decryptValues(invalidYaml, []age.Identity{identity})
+	require.Error(t, err, "Expected an error for a plaintext that is not a map")
+	assert.NotContains(t, err.Error(), "s3cr3t", "Expected the plaintext not to leak in errors")
+}
+
+func TestDecryptValues_SOPS(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		data json.RawMessage
+	}{
+		{
+			name: "document",
+			data: json.RawMessage(
+				`{"password":"ENC[AES256_GCM,data:Zm9v,iv:YmFy,tag:YmF6,type:str]",` +
+					`"sops":{"age":[{"recipient":"age1abc"}]}}`,
+			),
+		},
+		{
+			name: "string",
+			data: json.RawMessage(
+				`"password: ENC[AES256_GCM,data:Zm9v,iv:YmFy,tag:YmF6,type:str]\nsops:\n  version: 3.9.0\n"`,
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var entry v1.ValueEntry
+
+			err := json.Unmarshal(
+				[]byte(`{"type":"encrypted","data":`+string(tt.data)+`}`),
+				&entry,
+			)
+			require.ErrorIs(t, err, v1.ErrSOPSNotSupported)
+
+			_, err = decryptValues(tt.data, []age.Identity{identity})
+			require.ErrorIs(t, err, v1.ErrSOPSNotSupported)
+		})
+	}
+}
+
+func TestLoadAgeIdentities(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "key.txt")
+	require.NoError(t, os.WriteFile(keyFile, []byte(identity.String()+"\n"), 0o600))
+
+	t.Setenv(AgeKeyEnv, "")
+	t.Setenv(AgeKeyFileEnv, "")
+
+	_, err = loadAgeIdentities(&Options{})
+	require.Error(t, err, "Expected an error when no key is provided")
+
+	identities, err := loadAgeIdentities(&Options{AgeKeyFile: keyFile})
+	require.NoError(t, err)
+	assert.Len(t, identities, 1)
+
+	t.Setenv(AgeKeyFileEnv, keyFile)
+
+	identities, err = loadAgeIdentities(&Options{})
+	require.NoError(t, err)
+	assert.Len(t, identities, 1)
+
+	t.Setenv(AgeKeyEnv, identity.String())
+
+	identities, err = loadAgeIdentities(&Options{})
+	require.NoError(t, err)
+	assert.Len(t, identities, 1)
+}
+
+func TestMergeMaps(t *testing.T) {
+	dst := map[string]any{
+		"image":    map[string]any{"repository": "app", "tag": "1.0"},
+		"replicas": 1,
+	}
+	src := map[string]any{
+		"image":    map[string]any{"tag": "2.0"},
+		"replicas": map[string]any{"min": 1},
+	}
+
+	merged := mergeMaps(dst, src)
+
+	assert.Equal(t, map[string]any{
+		"image":    map[string]any{"repository": "app", "tag": "2.0"},
+		"replicas": map[string]any{"min": 1},
+	}, merged)
+	assert.Equal(t, "1.0", dst["image"].(map[string]any)["tag"], "Expected dst to be unchanged")
+}
@@ -0,0 +1,41 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Stages of generating an application that an AppError can occur in
+const (
+	StagePrepare   = "prepare"
+	StagePull      = "pull"
+	StageLoad      = "load"
+	StageValues    = "values"
+	StageValidate  = "validate"
+	StageRender    = "render"
+	StageKustomize = "kustomize"
+	StagePatch     = "patch"
+	StageWrite     = "write"
+)
+
+// errSkipped marks applications that were not rendered because another one failed
+var errSkipped = errors.New("skipped after an earlier failure")
+
+// AppError is an error generating a single application
+type AppError struct {
+	App   string
+	Stage string
+	Err   error
+}
+
+func (e *AppError) Error() string {
+	return fmt.Sprintf("%s: %s failed: %v", e.App, e.Stage, e.Err)
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+func newAppError(app, stage string, err error) *AppError {
+	return &AppError{App: app, Stage: stage, Err: err}
+}
@@ -0,0 +1,298 @@
+package generate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/komailo/kubeit/internal/logger"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+// ExplainOptions are the options of the values explainer
+type ExplainOptions struct {
+	// App is the name of the HelmApplication whose values are explained
+	App string
+	// Key limits the explanation to a single key path, e.g. controller.image.tag
+	Key string
+	// ANSI renders overridden values with the ANSI strikethrough style instead of ~~
+	ANSI bool
+}
+
+// valueSource is a value set at a key path by a value layer, and where it was set
+type valueSource struct {
+	Value  any
+	Origin valueOrigin
+	File   string
+	Line   int
+}
+
+func (a valueSource) String() string {
+	if a.File == "" {
+		return a.Origin.String()
+	}
+
+	if a.Line == 0 {
+		return fmt.Sprintf("%s %s", a.File, a.Origin)
+	}
+
+	return fmt.Sprintf("%s:%d %s", a.File, a.Line, a.Origin)
+}
+
+// valuesProvenance holds the merged values of an application together with the
+// assignments made to each leaf key path, in the order they were merged
+type valuesProvenance struct {
+	Values  map[string]any
+	History map[string][]valueSource
+}
+
+// ExplainValues writes the merged values of a HelmApplication to out, annotating each
+// value with the value entry it was set by and the values it overrides.
+func ExplainValues(
+	generateSetOptions *Options,
+	explainOptions ExplainOptions,
+	out io.Writer,
+) ([]error, map[string][]error) {
+	sourceConfigURI := generateSetOptions.SourceConfigURI
+	logger.Infof("Explaining values of %s from %s", explainOptions.App, sourceConfigURI)
+
+	loaderInt := loader.NewLoader()
+	loaderErr := loaderInt.FromSourceURI(sourceConfigURI)
+
+	if len(loaderErr) != 0 {
+		return nil, loaderErr
+	}
+
+	helmApplications := loader.FindResourcesByName(
+		loaderInt.HelmApplications,
+		[]string{explainOptions.App},
+	)
+	if len(helmApplications) == 0 {
+		return []error{
+			fmt.Errorf("no HelmApplication named %s found in %s", explainOptions.App, sourceConfigURI),
+		}, nil
+	}
+
+	provenance, err := explainHelmValues(helmApplications[0], loaderInt, generateSetOptions)
+	if err != nil {
+		return []error{err}, nil
+	}
+
+	if err := provenance.Render(out, explainOptions); err != nil {
+		return []error{err}, nil
+	}
+
+	return nil, nil
+}
+
+// explainHelmValues merges the value layers of a HelmApplication the same way values
+// are generated and records which layer set each leaf key path
+func explainHelmValues(
+	helmApplication *v1.HelmApplication,
+	loaderInt *loader.Loader,
+	generateSetOptions *Options,
+) (valuesProvenance, error) {
+	layers, err := collectValueLayers(helmApplication, loaderInt, generateSetOptions)
+	if err != nil {
+		return valuesProvenance{}, err
+	}
+
+	return explainValueLayers(layers, loaderInt)
+}
+
+// explainValueLayers merges value layers and records which layer set each leaf key path
+func explainValueLayers(layers []valueLayer, loaderInt *loader.Loader) (valuesProvenance, error) {
+	provenance := valuesProvenance{
+		Values:  map[string]any{},
+		History: map[string][]valueSource{},
+	}
+
+	for _, layer := range sortValueLayers(layers) {
+		// Parse the layer on its own to find the key paths it sets
+		layerValues, err := applyValueLayer(layer, map[string]any{})
+		if err != nil {
+			return valuesProvenance{}, err
+		}
+
+		provenance.Values, err = applyValueLayer(layer, provenance.Values)
+		if err != nil {
+			return valuesProvenance{}, err
+		}
+
+		file, line := loaderInt.SourceLocation(layer.Owner, layer.Origin.Index)
+
+		walkValueLeaves("", layerValues, func(keyPath string, value any) {
+			provenance.History[keyPath] = append(provenance.History[keyPath], valueSource{
+				Value:  value,
+				Origin: layer.Origin,
+				File:   file,
+				Line:   line,
+			})
+		})
+	}
+
+	return provenance, nil
+}
+
+// walkValueLeaves calls fn for every leaf of a values tree with its key path. Dots in
+// keys are escaped so the path can be split back into keys.
+func walkValueLeaves(keyPath string, value any, fn func(keyPath string, value any)) {
+	switch typed := value.(type) {
+	case map[string]any:
+		if len(typed) == 0 {
+			break
+		}
+
+		for _, key := range sortedKeys(typed) {
+			walkValueLeaves(joinValuePath(keyPath, key), typed[key], fn)
+		}
+
+		return
+	case []any:
+		if len(typed) == 0 {
+			break
+		}
+
+		for i, item := range typed {
+			walkValueLeaves(fmt.Sprintf("%s[%d]", keyPath, i), item, fn)
+		}
+
+		return
+	}
+
+	fn(keyPath, value)
+}
+
+func joinValuePath(keyPath, key string) string {
+	key = strings.ReplaceAll(key, ".", `\.`)
+	if keyPath == "" {
+		return key
+	}
+
+	return keyPath + "." + key
+}
+
+// Render writes the values tree to out. Every value is annotated with the entry that
+// set it, followed by the values it overrides struck through.
+func (p valuesProvenance) Render(out io.Writer, explainOptions ExplainOptions) error {
+	var buf strings.Builder
+
+	var writeNode func(indent, label, keyPath string, value any)
+	writeNode = func(indent, label, keyPath string, value any) {
+		if p.isContainer(value) {
+			if label != "" {
+				fmt.Fprintf(&buf, "%s%s\n", indent, label)
+				indent += "  "
+			}
+
+			p.visitChildren(keyPath, value, func(childLabel, childPath string, child any) {
+				writeNode(indent, childLabel, childPath, child)
+			})
+
+			return
+		}
+
+		history := p.History[keyPath]
+
+		fmt.Fprintf(&buf, "%s%s %s", indent, label, encodeValue(value))
+
+		if len(history) > 0 && reflect.DeepEqual(history[len(history)-1].Value, value) {
+			fmt.Fprintf(&buf, "  # %s", history[len(history)-1])
+			history = history[:len(history)-1]
+		}
+
+		buf.WriteString("\n")
+
+		for i := len(history) - 1; i >= 0; i-- {
+			overridden := encodeValue(history[i].Value)
+			if explainOptions.ANSI {
+				overridden = "\x1b[9m" + overridden + "\x1b[0m"
+			} else {
+				overridden = "~~" + overridden + "~~"
+			}
+
+			fmt.Fprintf(&buf, "%s    %s  # %s\n", indent, overridden, history[i])
+		}
+	}
+
+	if explainOptions.Key == "" {
+		writeNode("", "", "", p.Values)
+	} else {
+		value, ok := p.lookup("", p.Values, explainOptions.Key)
+		if !ok {
+			return fmt.Errorf("key %s is not set in the values", explainOptions.Key)
+		}
+
+		writeNode("", explainOptions.Key+":", explainOptions.Key, value)
+	}
+
+	if _, err := io.WriteString(out, logger.Redact(buf.String())); err != nil {
+		return fmt.Errorf("failed to write values explanation: %w", err)
+	}
+
+	return nil
+}
+
+// lookup returns the value at the key path in a values tree
+func (p valuesProvenance) lookup(keyPath string, value any, key string) (any, bool) {
+	if keyPath == key {
+		return value, true
+	}
+
+	var (
+		found any
+		ok    bool
+	)
+
+	p.visitChildren(keyPath, value, func(_, childPath string, child any) {
+		if ok || !strings.HasPrefix(key, childPath) {
+			return
+		}
+
+		found, ok = p.lookup(childPath, child, key)
+	})
+
+	return found, ok
+}
+
+// isContainer reports whether value is a non empty map or list
+func (p valuesProvenance) isContainer(value any) bool {
+	switch typed := value.(type) {
+	case map[string]any:
+		return len(typed) > 0
+	case []any:
+		return len(typed) > 0
+	}
+
+	return false
+}
+
+// visitChildren calls fn for every child of a map or list value in key order
+func (p valuesProvenance) visitChildren(
+	keyPath string,
+	value any,
+	fn func(label, childPath string, child any),
+) {
+	switch typed := value.(type) {
+	case map[string]any:
+		for _, key := range sortedKeys(typed) {
+			fn(key+":", joinValuePath(keyPath, key), typed[key])
+		}
+	case []any:
+		for i, item := range typed {
+			fn("-", fmt.Sprintf("%s[%d]", keyPath, i), item)
+		}
+	}
+}
+
+func encodeValue(value any) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(encoded)
+}
@@ -0,0 +1,79 @@
+package generate
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/komailo/kubeit/pkg/api"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+func TestExplainHelmValues(t *testing.T) {
+	loaderInt := loader.NewLoader()
+	loaderInt.NamedValues = []*v1.NamedValues{{
+		BaseObject: api.BaseObject{Metadata: api.ObjectMeta{Name: "production"}},
+		Spec: v1.NamedValuesSpec{Values: []v1.ValueEntry{
+			{Type: "string", Data: json.RawMessage(`{"image.tag": "3.0"}`)},
+		}},
+	}}
+
+	helmApplication := &v1.HelmApplication{
+		BaseObject: api.BaseObject{Metadata: api.ObjectMeta{Name: "app"}},
+		Spec: v1.HelmApplicationSpec{
+			Values: []v1.ValueEntry{
+				{Type: "named"},
+				{Type: "mapping", Data: json.RawMessage(`{"image.tag": "2.0"}`)},
+				{
+					Type: "raw",
+					Data: json.RawMessage(
+						`{"image": {"repository": "app", "tag": "1.0"}, "ingress.class": "nginx"}`,
+					),
+				},
+			},
+		},
+	}
+
+	generateSetOptions := &Options{
+		WorkDir:     t.TempDir(),
+		NamedValues: []string{"production"},
+	}
+
+	provenance, err := explainHelmValues(helmApplication, loaderInt, generateSetOptions)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{
+		"image":         map[string]any{"repository": "app", "tag": "3.0"},
+		"ingress.class": "nginx",
+	}, provenance.Values)
+
+	history := provenance.History["image.tag"]
+	require.Len(t, history, 3)
+	assert.Equal(t, "HelmApplication/app values[2] (raw)", history[0].String())
+	assert.Equal(t, "HelmApplication/app values[1] (mapping)", history[1].String())
+	assert.Equal(t, "NamedValues/production values[0] (string)", history[2].String())
+	assert.Contains(t, provenance.History, `ingress\.class`)
+
+	var out bytes.Buffer
+	require.NoError(t, provenance.Render(&out, ExplainOptions{}))
+	assert.Equal(t, `image:
+  repository: "app"  # HelmApplication/app values[2] (raw)
+  tag: "3.0"  # NamedValues/production values[0] (string)
+      ~~"2.0"~~  # HelmApplication/app values[1] (mapping)
+      ~~"1.0"~~  # HelmApplication/app values[2] (raw)
+ingress.class: "nginx"  # HelmApplication/app values[2] (raw)
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, provenance.Render(&out, ExplainOptions{Key: "image.tag", ANSI: true}))
+	assert.Equal(t, `image.tag: "3.0"  # NamedValues/production values[0] (string)
+    `+"\x1b[9m\"2.0\"\x1b[0m"+`  # HelmApplication/app values[1] (mapping)
+    `+"\x1b[9m\"1.0\"\x1b[0m"+`  # HelmApplication/app values[2] (raw)
+`, out.String())
+
+	require.Error(t, provenance.Render(&out, ExplainOptions{Key: "image.digest"}))
+}
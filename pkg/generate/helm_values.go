@@ -3,41 +3,69 @@ package generate
 import (
 	"encoding/json"
 	"fmt"
-	"os"
 	"regexp"
-	"strings"
+	"slices"
+	"sort"
 
+	"filippo.io/age"
 	"gopkg.in/yaml.v3"
-	helmCliValues "helm.sh/helm/v3/pkg/cli/values"
 
 	"github.com/komailo/kubeit/internal/logger"
-	"github.com/komailo/kubeit/internal/version"
+	"github.com/komailo/kubeit/pkg/api"
 	"github.com/komailo/kubeit/pkg/api/loader"
 	v1 "github.com/komailo/kubeit/pkg/api/v1"
-
-	"github.com/komailo/kubeit/pkg/utils"
+	"github.com/komailo/kubeit/pkg/condition"
 )
 
-func generateHelmValues(
-	values []v1.ValueEntry,
+// valueStages is the order value layers are merged in. Raw values are deep merged
+// first, followed by the key path assignments in the order Helm applies its --set-json,
+// --set, --set-string, --set-file and --set-literal options. Encrypted values decrypt
+// to a values document and are merged with the raw values.
+var valueStages = []string{"raw", "json", "mapping", "string", "file", "literal"}
+
+// valueStage returns the index of the stage a value entry type is merged in
+func valueStage(valueType string) int {
+	if valueType == "encrypted" {
+		valueType = "raw"
+	}
+
+	return slices.Index(valueStages, valueType)
+}
+
+// valueOrigin identifies the value entry a value layer was generated from
+type valueOrigin struct {
+	Kind  string
+	Name  string
+	Index int
+	Type  string
+}
+
+func (o valueOrigin) String() string {
+	return fmt.Sprintf("%s/%s values[%d] (%s)", o.Kind, o.Name, o.Index, o.Type)
+}
+
+// valueLayer holds the values generated from a single value entry
+type valueLayer struct {
+	Origin valueOrigin
+	Owner  api.Object
+	// Values holds the values of raw and encrypted entries, which are deep merged
+	Values map[string]any
+	// Assignments holds the key path assignments of the other entries
+	Assignments []valueAssignment
+	// Sensitive is set for entries marked sensitive and for encrypted entries
+	Sensitive bool
+}
+
+// collectValueLayers walks the value entries of a HelmApplication, including the
+// selected named values, and generates a value layer for each entry that applies.
+func collectValueLayers(
+	helmApplication *v1.HelmApplication,
 	loaderInt *loader.Loader,
 	generateSetOptions *Options,
-) (helmCliValues.Options, error) {
-	valuesFile, err := os.CreateTemp(generateSetOptions.WorkDir, "helm-values-*.yaml")
+) ([]valueLayer, error) {
+	renderContext, err := newRenderContext(helmApplication, loaderInt, generateSetOptions)
 	if err != nil {
-		return helmCliValues.Options{}, fmt.Errorf(
-			"failed to create temporary file for helm values: %w",
-			err,
-		)
-	}
-
-	helmCliValuesOptions := helmCliValues.Options{
-		ValueFiles:    []string{},
-		StringValues:  []string{},
-		Values:        []string{},
-		FileValues:    []string{},
-		JSONValues:    []string{},
-		LiteralValues: []string{},
+		return nil, err
 	}
 
 	var filteredNamedValues []*v1.NamedValues
@@ -49,24 +77,71 @@ func generateHelmValues(
 		)
 	}
 
-	var jsonValues []json.RawMessage
+	var layers []valueLayer
+
+	var identities []age.Identity
+
+	var processValues func(owner api.Object, kind string, values []v1.ValueEntry) error
+	processValues = func(owner api.Object, kind string, values []v1.ValueEntry) error {
+		for i, value := range values {
+			if value.When != "" {
+				matched, err := condition.Evaluate(value.When, renderContext)
+				if err != nil {
+					return fmt.Errorf(
+						"failed to evaluate condition of values[%d] in %s: %w",
+						i,
+						owner.GetObjectMeta().Name,
+						err,
+					)
+				}
+
+				if !matched {
+					logger.Debugf(
+						"Skipping values[%d] of type %s in %s as condition %q is false",
+						i,
+						value.Type,
+						owner.GetObjectMeta().Name,
+						value.When,
+					)
+
+					continue
+				}
+			}
+
+			layer := valueLayer{
+				Origin: valueOrigin{
+					Kind:  kind,
+					Name:  owner.GetObjectMeta().Name,
+					Index: i,
+					Type:  value.Type,
+				},
+				Owner: owner,
+			}
 
-	var processValues func(values []v1.ValueEntry) error
-	processValues = func(values []v1.ValueEntry) error {
-		for _, value := range values {
 			switch value.Type {
 			case "named":
 				for _, namedValue := range filteredNamedValues {
 					logger.Infof("Processing named values: %s", namedValue.Metadata.Name)
 
-					if err := processValues(namedValue.Spec.Values); err != nil {
+					if err := processValues(namedValue, "NamedValues", namedValue.Spec.Values); err != nil {
 						return err
 					}
 				}
+
+				continue
 			case "raw":
-				jsonValues = append(jsonValues, value.Data)
+				rawValues := map[string]any{}
+				if err := json.Unmarshal(value.Data, &rawValues); err != nil {
+					return fmt.Errorf("failed to unmarshal raw values: %w", err)
+				}
+
+				layer.Values = rawValues
 			case "mapping":
-				mappingValues, err := generateValueMappings(value.Data, loaderInt)
+				mappingValues, err := generateValueMappings(
+					value.Data,
+					renderContext.Variables,
+					typedSetValue,
+				)
 				if err != nil {
 					return fmt.Errorf(
 						"failed to generate value mappings: %w",
@@ -74,117 +149,306 @@ func generateHelmValues(
 					)
 				}
 
-				helmCliValuesOptions.Values = append(helmCliValuesOptions.Values, mappingValues...)
+				layer.Assignments = mappingValues
+			case "string":
+				stringValues, err := generateValueMappings(
+					value.Data,
+					renderContext.Variables,
+					stringValue,
+				)
+				if err != nil {
+					return fmt.Errorf("failed to generate string values: %w", err)
+				}
+
+				layer.Assignments = stringValues
+			case "literal":
+				literalValues, err := generateValueMappings(
+					value.Data,
+					renderContext.Variables,
+					stringValue,
+				)
+				if err != nil {
+					return fmt.Errorf("failed to generate literal values: %w", err)
+				}
+
+				layer.Assignments = literalValues
+			case "json":
+				jsonSetValues, err := generateJSONValues(value.Data, renderContext.Variables)
+				if err != nil {
+					return fmt.Errorf("failed to generate json values: %w", err)
+				}
+
+				layer.Assignments = jsonSetValues
+			case "file":
+				fileValues, err := generateFileValues(owner, value.Data, loaderInt)
+				if err != nil {
+					return fmt.Errorf("failed to generate file values: %w", err)
+				}
+
+				layer.Assignments = fileValues
+			case "encrypted":
+				if identities == nil {
+					identities, err = loadAgeIdentities(generateSetOptions)
+					if err != nil {
+						return err
+					}
+				}
+
+				decrypted, err := decryptValues(value.Data, identities)
+				if err != nil {
+					return fmt.Errorf(
+						"failed to decrypt values of %s: %w",
+						owner.GetObjectMeta().Name,
+						err,
+					)
+				}
+
+				// Decrypted values are always sensitive
+				value.Sensitive = true
+
+				layer.Values = decrypted
 			default:
 				return fmt.Errorf("unsupported value type: %s", value.Type)
 			}
+
+			layer.Sensitive = value.Sensitive
+			registerSensitiveLayer(layer, value.Sensitive)
+
+			layers = append(layers, layer)
 		}
 
 		return nil
 	}
 
-	if err := processValues(values); err != nil {
-		return helmCliValuesOptions, err
+	err = processValues(helmApplication, "HelmApplication", helmApplication.Spec.Values)
+	if err != nil {
+		return nil, err
 	}
 
-	// Create a YAML encoder
-	yamlEncoder := yaml.NewEncoder(valuesFile)
-	defer yamlEncoder.Close()
-
-	// Convert and write each JSON object as a separate YAML document
-	for _, jsonValue := range jsonValues {
-		var yamlValue any
+	return layers, nil
+}
 
-		// Unmarshal JSON into a generic interface
-		if err := json.Unmarshal(jsonValue, &yamlValue); err != nil {
-			return helmCliValuesOptions, fmt.Errorf("failed to unmarshal JSON value: %w", err)
-		}
+// generateHelmValues computes the values of a HelmApplication by merging its value
+// layers in memory. The layers are returned too so values can be traced back to the
+// entries that set them.
+func generateHelmValues(
+	helmApplication *v1.HelmApplication,
+	loaderInt *loader.Loader,
+	generateSetOptions *Options,
+) (map[string]any, []valueLayer, error) {
+	layers, err := collectValueLayers(helmApplication, loaderInt, generateSetOptions)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		// Encode YAML as a separate document
-		if err := yamlEncoder.Encode(yamlValue); err != nil {
-			return helmCliValuesOptions, fmt.Errorf("error encoding yaml: %w", err)
-		}
+	chartValues, err := mergeValueLayers(layers)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if len(helmCliValuesOptions.Values) > 0 {
-		logger.Infof(
-			"Generated Helm set values from %d entries\n%s",
-			len(helmCliValuesOptions.Values),
-			strings.Join(helmCliValuesOptions.Values, "\n"),
-		)
+	logger.AddSensitiveValues("", chartValues, false)
+
+	encodedValues, err := yaml.Marshal(chartValues)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode Helm values: %w", err)
 	}
 
-	if len(jsonValues) > 0 {
-		helmCliValuesOptions.ValueFiles = append(helmCliValuesOptions.ValueFiles, valuesFile.Name())
+	logger.Infof("Generated Helm values from %d entries\n%s", len(layers), encodedValues)
 
-		_, err := valuesFile.Seek(0, 0)
+	return chartValues, layers, nil
+}
+
+// sortValueLayers returns the value layers ordered by the stage they are merged in,
+// keeping the declared order within a stage
+func sortValueLayers(layers []valueLayer) []valueLayer {
+	sorted := slices.Clone(layers)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return valueStage(sorted[i].Origin.Type) < valueStage(sorted[j].Origin.Type)
+	})
+
+	return sorted
+}
+
+// mergeValueLayers merges value layers into a single values map
+func mergeValueLayers(layers []valueLayer) (map[string]any, error) {
+	values := map[string]any{}
+
+	for _, layer := range sortValueLayers(layers) {
+		var err error
+
+		values, err = applyValueLayer(layer, values)
 		if err != nil {
-			return helmCliValuesOptions, fmt.Errorf(
-				"failed to seek to the beginning of the generated Helm values file: %w",
-				err,
-			)
+			return nil, err
 		}
+	}
+
+	return values, nil
+}
+
+// applyValueLayer returns a copy of values with the values of a layer merged in
+func applyValueLayer(layer valueLayer, values map[string]any) (map[string]any, error) {
+	if layer.Values != nil {
+		values = mergeMaps(values, layer.Values)
+	}
 
-		valuesFileRead, err := os.ReadFile(valuesFile.Name())
+	for _, assignment := range layer.Assignments {
+		var err error
+
+		values, err = assignValuePath(values, assignment.Path, assignment.Value)
 		if err != nil {
-			return helmCliValuesOptions, fmt.Errorf(
-				"failed to read generated Helm values file: %w",
-				err,
-			)
+			return nil, fmt.Errorf("failed to apply %s: %w", layer.Origin, err)
 		}
+	}
 
-		logger.Infof("Generated Helm values from %d entries\n%s", len(jsonValues), valuesFileRead)
+	return values, nil
+}
+
+// newRenderContext returns the context that value entry conditions are evaluated
+// against. When no kube version is provided the default Helm renders with is used.
+func newRenderContext(
+	helmApplication *v1.HelmApplication,
+	loaderInt *loader.Loader,
+	generateSetOptions *Options,
+) (condition.Context, error) {
+	variables, err := generateVariables(loaderInt)
+	if err != nil {
+		return condition.Context{}, err
 	}
 
-	return helmCliValuesOptions, nil
+	userVariables, err := parseVariables(generateSetOptions.Variables)
+	if err != nil {
+		return condition.Context{}, err
+	}
+
+	// Variables provided by the user take precedence over the generated ones
+	for name, value := range userVariables {
+		variables[name] = value
+	}
+
+	capabilities, err := resolveCapabilities(loaderInt, generateSetOptions)
+	if err != nil {
+		return condition.Context{}, err
+	}
+
+	return condition.Context{
+		NamedValues:  generateSetOptions.NamedValues,
+		KubeVersion:  capabilities.kubeVersion(),
+		Namespace:    helmApplication.Spec.Chart.Namespace,
+		SourceScheme: loaderInt.SourceMeta.Scheme,
+		Variables:    variables,
+	}, nil
 }
 
-// The function will substitute $VAR or ${VAR} with the actual value
+var varPattern = regexp.MustCompile(`\$\{([^}]+)\}|\$([a-zA-Z_][a-zA-Z0-9_]*)`)
+
+// generateValueMappings substitutes $VAR or ${VAR} in the values of mapping, string
+// and literal entries with the actual value and converts them into key path
+// assignments
 func generateValueMappings(
 	data json.RawMessage,
-	loaderInt *loader.Loader,
-) ([]string, error) {
+	variables map[string]string,
+	convert func(string) any,
+) ([]valueAssignment, error) {
 	var mappings stringMap
 	if err := json.Unmarshal(data, &mappings); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal mappings data: %w", err)
 	}
 
-	var setValues []string
+	var assignments []valueAssignment
 
-	varPattern := regexp.MustCompile(`\$\{([^}]+)\}|\$([a-zA-Z_][a-zA-Z0-9_]*)`)
+	for _, key := range sortedKeys(mappings) {
+		newValue := substituteVariables(mappings[key], variables)
 
-	var dockerRepo, dockerTag string
+		assignments = append(assignments, valueAssignment{Path: key, Value: convert(newValue)})
+	}
+
+	return assignments, nil
+}
+
+// stringValue keeps the value of string and literal entries as a string
+func stringValue(value string) any {
+	return value
+}
+
+// generateJSONValues converts json entries into key path assignments. String values
+// are treated as JSON documents after variable substitution, so variables that hold
+// JSON lists can be used directly. Any other value is used as is.
+func generateJSONValues(
+	data json.RawMessage,
+	variables map[string]string,
+) ([]valueAssignment, error) {
+	var jsonMappings map[string]any
+	if err := json.Unmarshal(data, &jsonMappings); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal json data: %w", err)
+	}
+
+	var assignments []valueAssignment
 
-	var err error
+	for _, key := range sortedKeys(jsonMappings) {
+		value := jsonMappings[key]
 
-	if loaderInt.SourceMeta.Scheme == "docker" {
-		dockerRepo, dockerTag, err = utils.ParseDockerImage(loaderInt.SourceMeta.Source)
+		if document, ok := value.(string); ok {
+			jsonValue := substituteVariables(document, variables)
+
+			var parsed any
+			if err := json.Unmarshal([]byte(jsonValue), &parsed); err != nil {
+				return nil, fmt.Errorf("value of %s is not valid JSON: %s", key, jsonValue)
+			}
+
+			value = parsed
+		}
+
+		assignments = append(assignments, valueAssignment{Path: key, Value: value})
+	}
+
+	return assignments, nil
+}
+
+// generateFileValues converts file entries into key path assignments of the file
+// contents. The files are resolved relative to the resource that references them and
+// read through the loader, since files embedded in a Docker image do not exist on disk.
+func generateFileValues(
+	owner api.Object,
+	data json.RawMessage,
+	loaderInt *loader.Loader,
+) ([]valueAssignment, error) {
+	var files stringMap
+	if err := json.Unmarshal(data, &files); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal file data: %w", err)
+	}
+
+	var assignments []valueAssignment
+
+	for _, key := range sortedKeys(files) {
+		content, err := loaderInt.ReadFile(owner, files[key])
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse Docker image: %w", err)
+			return nil, fmt.Errorf("failed to read file for %s: %w", key, err)
 		}
-	} else {
-		dockerRepo = "$dockerImageRepository!!NOT_GENERATED_FROM_DOCKER_IMAGE!!"
-		dockerTag = "$dockerImageTag!!NOT_GENERATED_FROM_DOCKER_IMAGE!!"
-	}
-
-	for key, value := range mappings {
-		newValue := varPattern.ReplaceAllStringFunc(value, func(match string) string {
-			// Extract variable name
-			varName := strings.TrimPrefix(strings.Trim(match, "${}"), "$")
-			switch varName {
-			case "dockerImageRepository":
-				return dockerRepo
-			case "dockerImageTag":
-				return dockerTag
-			case "kubeitVersion":
-				return version.GetBuildInfo().Version
-			}
-			// If not found, return the original match unchanged
-			return match
-		})
 
-		setValues = append(setValues, fmt.Sprintf("%s=%s", key, newValue))
+		assignments = append(assignments, valueAssignment{Path: key, Value: string(content)})
+	}
+
+	return assignments, nil
+}
+
+// registerSensitiveLayer registers the values of a layer whose key is sensitive, or
+// all of them when the entry is marked sensitive
+func registerSensitiveLayer(layer valueLayer, sensitive bool) {
+	logger.AddSensitiveValues("", layer.Values, sensitive)
+
+	for _, assignment := range layer.Assignments {
+		logger.AddSensitiveValues(assignment.Path, assignment.Value, sensitive)
 	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
 
-	return setValues, nil
+	return keys
 }
@@ -0,0 +1,182 @@
+package generate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/komailo/kubeit/pkg/api"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+func TestGenerateJSONValues(t *testing.T) {
+	variables, err := generateVariables(loader.NewLoader())
+	require.NoError(t, err)
+
+	assignments, err := generateJSONValues(
+		json.RawMessage(`{
+			"ports": "[8080, 9090]",
+			"version": "\"$kubeitVersion\"",
+			"resources": {"limits": {"cpu": "100m"}},
+			"replicas": 2
+		}`),
+		variables,
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, []valueAssignment{
+		{Path: "ports", Value: []any{float64(8080), float64(9090)}},
+		{Path: "replicas", Value: float64(2)},
+		{Path: "resources", Value: map[string]any{"limits": map[string]any{"cpu": "100m"}}},
+		{Path: "version", Value: variables["kubeitVersion"]},
+	}, assignments)
+
+	_, err = generateJSONValues(json.RawMessage(`{"invalid": "not json"}`), variables)
+	require.Error(t, err, "Expected an error for a string that is not valid JSON")
+}
+
+func TestGenerateHelmValues_When(t *testing.T) {
+	loaderInt := loader.NewLoader()
+	loaderInt.NamedValues = []*v1.NamedValues{{
+		BaseObject: api.BaseObject{Metadata: api.ObjectMeta{Name: "production"}},
+		Spec: v1.NamedValuesSpec{Values: []v1.ValueEntry{
+			{Type: "mapping", Data: json.RawMessage(`{"env": "production"}`)},
+			{
+				Type: "mapping",
+				Data: json.RawMessage(`{"psp.enabled": "true"}`),
+				When: `kubeVersion < "1.25"`,
+			},
+		}},
+	}}
+
+	helmApplication := &v1.HelmApplication{
+		BaseObject: api.BaseObject{Metadata: api.ObjectMeta{Name: "app"}},
+		Spec: v1.HelmApplicationSpec{
+			Chart: v1.ChartSpec{Namespace: "apps"},
+			Values: []v1.ValueEntry{
+				{
+					Type: "mapping",
+					Data: json.RawMessage(`{"replicas": "3"}`),
+					When: `namedValues contains "production" && namespace == "apps"`,
+				},
+				{
+					Type: "mapping",
+					Data: json.RawMessage(`{"replicas": "1"}`),
+					When: `!(namedValues contains "production")`,
+				},
+				{Type: "named"},
+			},
+		},
+	}
+
+	generateSetOptions := &Options{
+		WorkDir:     t.TempDir(),
+		KubeVersion: "1.29.0",
+		NamedValues: []string{"production"},
+	}
+
+	chartValues, _, err := generateHelmValues(helmApplication, loaderInt, generateSetOptions)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"replicas": int64(3), "env": "production"}, chartValues)
+
+	helmApplication.Spec.Values[0].When = `kubeVersion >=`
+
+	_, _, err = generateHelmValues(helmApplication, loaderInt, generateSetOptions)
+	require.Error(t, err, "Expected an error for an invalid condition")
+}
+
+func TestMergeValueLayers(t *testing.T) {
+	layers := []valueLayer{
+		{
+			Origin: valueOrigin{Type: "mapping"},
+			Assignments: []valueAssignment{
+				{Path: "image.tag", Value: "2.0"},
+				{Path: `podAnnotations.example\.com/team`, Value: "platform"},
+				{Path: "args[1]", Value: "--verbose"},
+			},
+		},
+		{
+			Origin: valueOrigin{Type: "raw"},
+			Values: map[string]any{
+				"image": map[string]any{"repository": "app", "tag": "1.0"},
+				"args":  []any{"--port=8080"},
+			},
+		},
+		{
+			Origin:      valueOrigin{Type: "string"},
+			Assignments: []valueAssignment{{Path: "image.tag", Value: "3.0"}},
+		},
+		{
+			Origin: valueOrigin{Type: "raw"},
+			Values: map[string]any{"image": map[string]any{"pullPolicy": "Always"}},
+		},
+	}
+
+	values, err := mergeValueLayers(layers)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"image": map[string]any{
+			"repository": "app",
+			"tag":        "3.0",
+			"pullPolicy": "Always",
+		},
+		"args":           []any{"--port=8080", "--verbose"},
+		"podAnnotations": map[string]any{"example.com/team": "platform"},
+	}, values)
+	assert.Equal(
+		t,
+		[]any{"--port=8080"},
+		layers[1].Values["args"],
+		"Expected layers to be left unchanged",
+	)
+}
+
+func TestGenerateHelmValues_EncryptedOrder(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	t.Setenv(AgeKeyEnv, identity.String())
+	t.Setenv(AgeKeyFileEnv, "")
+
+	loaderInt := loader.NewLoader()
+	loaderInt.NamedValues = []*v1.NamedValues{{
+		BaseObject: api.BaseObject{Metadata: api.ObjectMeta{Name: "production"}},
+		Spec: v1.NamedValuesSpec{Values: []v1.ValueEntry{
+			{Type: "raw", Data: json.RawMessage(`{"database": {"host": "prod-db"}}`)},
+		}},
+	}}
+
+	helmApplication := &v1.HelmApplication{
+		BaseObject: api.BaseObject{Metadata: api.ObjectMeta{Name: "app"}},
+		Spec: v1.HelmApplicationSpec{
+			Values: []v1.ValueEntry{
+				{Type: "raw", Data: json.RawMessage(`{"database": {"port": 5432}}`)},
+				{
+					Type: "encrypted",
+					Data: encryptForTest(
+						t,
+						identity.Recipient(),
+						"database:\n  host: db\n  port: 5433\n  password: s3cr3t\n",
+					),
+				},
+				{Type: "named"},
+				{Type: "mapping", Data: json.RawMessage(`{"database.password": "override"}`)},
+			},
+		},
+	}
+
+	chartValues, _, err := generateHelmValues(helmApplication, loaderInt, &Options{
+		WorkDir:     t.TempDir(),
+		NamedValues: []string{"production"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"database": map[string]any{
+		"host":     "prod-db",
+		"port":     float64(5433),
+		"password": "override",
+	}}, chartValues, "Expected encrypted values to merge in declared order with raw values")
+}
@@ -0,0 +1,130 @@
+package generate
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/release"
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/komailo/kubeit/internal/logger"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+// Argo CD sync hook annotations
+const (
+	argoCDHookAnnotation             = "argocd.argoproj.io/hook"
+	argoCDHookDeletePolicyAnnotation = "argocd.argoproj.io/hook-delete-policy"
+	argoCDSyncWaveAnnotation         = "argocd.argoproj.io/sync-wave"
+)
+
+// argoCDHookEvents maps the Helm hook events to Argo CD hooks. Events without an
+// equivalent, such as rollbacks and tests, are not mapped.
+var argoCDHookEvents = map[release.HookEvent]string{
+	release.HookPreInstall:  "PreSync",
+	release.HookPreUpgrade:  "PreSync",
+	release.HookPostInstall: "PostSync",
+	release.HookPostUpgrade: "PostSync",
+	release.HookPostDelete:  "PostDelete",
+}
+
+// argoCDHookDeletePolicies maps the Helm hook delete policies to Argo CD
+var argoCDHookDeletePolicies = map[release.HookDeletePolicy]string{
+	release.HookSucceeded:          "HookSucceeded",
+	release.HookFailed:             "HookFailed",
+	release.HookBeforeHookCreation: "BeforeHookCreation",
+}
+
+// hookManifests returns the hooks of a release as a multi-document manifest in the
+// format helm template writes them in, leaving out tests when they are excluded
+func hookManifests(hooks []*release.Hook, spec v1.HooksSpec) (string, error) {
+	var manifests strings.Builder
+
+	for _, hook := range hooks {
+		if spec.ExcludeTests && slices.Contains(hook.Events, release.HookTest) {
+			logger.Debugf("Excluding chart test %s", hook.Path)
+			continue
+		}
+
+		manifest := hook.Manifest
+
+		if spec.ArgoCD {
+			translated, err := argoCDHookManifest(hook)
+			if err != nil {
+				return "", fmt.Errorf("failed to translate hook %s: %w", hook.Path, err)
+			}
+
+			manifest = translated
+		}
+
+		fmt.Fprintf(&manifests, "---\n# Source: %s\n%s\n", hook.Path, manifest)
+	}
+
+	return manifests.String(), nil
+}
+
+// argoCDHookManifest replaces the Helm hook annotations of a hook with their Argo CD
+// sync hook equivalents. Hooks without an equivalent event are returned unchanged.
+func argoCDHookManifest(hook *release.Hook) (string, error) {
+	var argoCDHooks []string
+
+	for _, event := range hook.Events {
+		argoCDHook, ok := argoCDHookEvents[event]
+		if ok && !slices.Contains(argoCDHooks, argoCDHook) {
+			argoCDHooks = append(argoCDHooks, argoCDHook)
+		}
+	}
+
+	if len(argoCDHooks) == 0 {
+		logger.Debugf("Hook %s has no Argo CD equivalent and is left unchanged", hook.Path)
+		return hook.Manifest, nil
+	}
+
+	var object map[string]any
+	if err := k8syaml.Unmarshal([]byte(hook.Manifest), &object); err != nil {
+		return "", err
+	}
+
+	metadata, _ := object["metadata"].(map[string]any)
+	if metadata == nil {
+		metadata = map[string]any{}
+		object["metadata"] = metadata
+	}
+
+	annotations, _ := metadata["annotations"].(map[string]any)
+	if annotations == nil {
+		annotations = map[string]any{}
+		metadata["annotations"] = annotations
+	}
+
+	delete(annotations, release.HookAnnotation)
+	delete(annotations, release.HookWeightAnnotation)
+	delete(annotations, release.HookDeleteAnnotation)
+
+	annotations[argoCDHookAnnotation] = strings.Join(argoCDHooks, ",")
+
+	if hook.Weight != 0 {
+		annotations[argoCDSyncWaveAnnotation] = strconv.Itoa(hook.Weight)
+	}
+
+	var deletePolicies []string
+
+	for _, policy := range hook.DeletePolicies {
+		if argoCDPolicy, ok := argoCDHookDeletePolicies[policy]; ok {
+			deletePolicies = append(deletePolicies, argoCDPolicy)
+		}
+	}
+
+	if len(deletePolicies) != 0 {
+		annotations[argoCDHookDeletePolicyAnnotation] = strings.Join(deletePolicies, ",")
+	}
+
+	data, err := k8syaml.Marshal(object)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
@@ -0,0 +1,205 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/release"
+
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+func TestHookManifests(t *testing.T) {
+	hooks := []*release.Hook{
+		{
+			Path: "app/templates/migrate.yaml",
+			Manifest: `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-install,pre-upgrade
+    helm.sh/hook-weight: "-5"
+    helm.sh/hook-delete-policy: before-hook-creation,hook-succeeded
+    team: data`,
+			Events: []release.HookEvent{release.HookPreInstall, release.HookPreUpgrade},
+			Weight: -5,
+			DeletePolicies: []release.HookDeletePolicy{
+				release.HookBeforeHookCreation,
+				release.HookSucceeded,
+			},
+		},
+		{
+			Path: "app/templates/rollback.yaml",
+			Manifest: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: rollback
+  annotations:
+    helm.sh/hook: pre-rollback`,
+			Events: []release.HookEvent{release.HookPreRollback},
+		},
+		{
+			Path: "app/templates/tests/connection.yaml",
+			Manifest: `apiVersion: v1
+kind: Pod
+metadata:
+  name: test-connection
+  annotations:
+    helm.sh/hook: test`,
+			Events: []release.HookEvent{release.HookTest},
+		},
+	}
+
+	tests := []struct {
+		name string
+		spec v1.HooksSpec
+		want string
+	}{
+		{
+			name: "helm annotations",
+			want: "---\n# Source: app/templates/migrate.yaml\n" + hooks[0].Manifest + "\n" +
+				"---\n# Source: app/templates/rollback.yaml\n" + hooks[1].Manifest + "\n" +
+				"---\n# Source: app/templates/tests/connection.yaml\n" + hooks[2].Manifest + "\n",
+		},
+		{
+			name: "exclude tests",
+			spec: v1.HooksSpec{ExcludeTests: true},
+			want: "---\n# Source: app/templates/migrate.yaml\n" + hooks[0].Manifest + "\n" +
+				"---\n# Source: app/templates/rollback.yaml\n" + hooks[1].Manifest + "\n",
+		},
+		{
+			name: "argo cd annotations",
+			spec: v1.HooksSpec{ArgoCD: true, ExcludeTests: true},
+			want: `---
+# Source: app/templates/migrate.yaml
+apiVersion: batch/v1
+kind: Job
+metadata:
+  annotations:
+    argocd.argoproj.io/hook: PreSync
+    argocd.argoproj.io/hook-delete-policy: BeforeHookCreation,HookSucceeded
+    argocd.argoproj.io/sync-wave: "-5"
+    team: data
+  name: migrate
+---
+# Source: app/templates/rollback.yaml
+` + hooks[1].Manifest + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifests, err := hookManifests(hooks, tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, manifests)
+		})
+	}
+}
+
+func TestHelmRenderer_Manifest_Hooks(t *testing.T) {
+	sourceDir := t.TempDir()
+
+	writeFiles(t, sourceDir, map[string]string{
+		"charts/web/Chart.yaml":               "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+		"charts/web/templates/NOTES.txt":      "Release {{ .Release.Name }} is ready\n",
+		"charts/web/templates/job.yaml":       migrateHookTemplate,
+		"charts/web/templates/tests/pod.yaml": testHookTemplate,
+		"charts/web/templates/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+`,
+	})
+
+	tests := []struct {
+		name           string
+		hooks          string
+		wantManifest   []string
+		wantNoManifest []string
+		wantHooks      string
+	}{
+		{
+			name:         "inline",
+			wantManifest: []string{"kind: ConfigMap", "name: migrate", "name: web-test"},
+		},
+		{
+			name:           "separate without tests",
+			hooks:          "  hooks:\n    policy: Separate\n    excludeTests: true\n",
+			wantManifest:   []string{"kind: ConfigMap"},
+			wantNoManifest: []string{"name: migrate", "name: web-test"},
+			wantHooks:      "---\n# Source: web/templates/job.yaml\n",
+		},
+		{
+			name:           "drop",
+			hooks:          "  hooks:\n    policy: Drop\n",
+			wantManifest:   []string{"kind: ConfigMap"},
+			wantNoManifest: []string{"name: migrate", "name: web-test"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeFiles(t, sourceDir, map[string]string{"app.yaml": localChartApplication + tt.hooks})
+
+			loaderInt := loader.NewLoader()
+			require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+			require.Len(t, loaderInt.HelmApplications, 1)
+
+			outputDir := t.TempDir()
+			renderer := newTestRenderer(t, t.TempDir(), true)
+
+			require.NoError(t, renderer.manifest(
+				loaderInt.HelmApplications[0],
+				loaderInt,
+				&Options{OutputDir: outputDir, WorkDir: t.TempDir()},
+			))
+
+			manifest, err := os.ReadFile(filepath.Join(outputDir, "web.yaml"))
+			require.NoError(t, err)
+
+			for _, want := range tt.wantManifest {
+				assert.Contains(t, string(manifest), want)
+			}
+
+			for _, unwanted := range tt.wantNoManifest {
+				assert.NotContains(t, string(manifest), unwanted)
+			}
+
+			hooks, err := os.ReadFile(filepath.Join(outputDir, "web.hooks.yaml"))
+			if tt.wantHooks == "" {
+				require.ErrorIs(t, err, os.ErrNotExist)
+			} else {
+				require.NoError(t, err)
+				assert.True(t, strings.HasPrefix(string(hooks), tt.wantHooks))
+				assert.Contains(t, string(hooks), "name: migrate\n")
+				assert.NotContains(t, string(hooks), "name: web-test")
+			}
+
+			notes, err := os.ReadFile(filepath.Join(outputDir, "web.NOTES.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, "Release web is ready\n", string(notes))
+		})
+	}
+}
+
+const migrateHookTemplate = `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-install
+`
+
+const testHookTemplate = `apiVersion: v1
+kind: Pod
+metadata:
+  name: {{ .Release.Name }}-test
+  annotations:
+    helm.sh/hook: test
+`
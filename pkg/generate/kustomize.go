@@ -0,0 +1,114 @@
+package generate
+
+import (
+	"fmt"
+	"path"
+
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+// kustomizeRenderedFile is the file the rendered objects are added to the resources of
+// a kustomization as
+const kustomizeRenderedFile = "kubeit-rendered.yaml"
+
+// kustomizeManifest builds the kustomization of a HelmApplication with the rendered
+// manifest added to its resources, and returns the build output. The kustomization is
+// built in memory so the source directory is left unchanged.
+func kustomizeManifest(
+	helmApplication *v1.HelmApplication,
+	loaderInt *loader.Loader,
+	manifest string,
+) (string, error) {
+	kustomizePath := helmApplication.Spec.Kustomize.Path
+
+	dirKey, err := loaderInt.FileKey(helmApplication, kustomizePath)
+	if err != nil {
+		return "", err
+	}
+
+	files, err := loaderInt.KustomizationFiles(helmApplication, kustomizePath)
+	if err != nil {
+		return "", err
+	}
+
+	fSys := filesys.MakeFsInMemory()
+
+	for key, data := range files {
+		if err := fSys.WriteFile("/"+key, data); err != nil {
+			return "", fmt.Errorf("failed to copy kustomization file %s: %w", key, err)
+		}
+	}
+
+	dir := "/" + dirKey
+
+	renderedPath := path.Join(dir, kustomizeRenderedFile)
+	if fSys.Exists(renderedPath) {
+		return "", fmt.Errorf(
+			"the kustomization %s must not have a %s file, the rendered objects are added as it",
+			dirKey,
+			kustomizeRenderedFile,
+		)
+	}
+
+	if err := addKustomizationResource(fSys, dir, kustomizeRenderedFile); err != nil {
+		return "", err
+	}
+
+	if err := fSys.WriteFile(renderedPath, []byte(manifest)); err != nil {
+		return "", fmt.Errorf("failed to add the rendered objects to the kustomization: %w", err)
+	}
+
+	resources, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fSys, dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to build the kustomization %s: %w", dirKey, err)
+	}
+
+	data, err := resources.AsYaml()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the kustomization output: %w", err)
+	}
+
+	return "---\n" + string(data), nil
+}
+
+// addKustomizationResource appends a resource to the kustomization file of a directory.
+// The file is decoded generically so fields are kept as they are.
+func addKustomizationResource(fSys filesys.FileSystem, dir, resource string) error {
+	for _, name := range loader.KustomizationFileNames {
+		kustomizationPath := path.Join(dir, name)
+		if !fSys.Exists(kustomizationPath) {
+			continue
+		}
+
+		data, err := fSys.ReadFile(kustomizationPath)
+		if err != nil {
+			return fmt.Errorf("failed to read kustomization %s: %w", kustomizationPath, err)
+		}
+
+		var kustomization map[string]any
+		if err := k8syaml.Unmarshal(data, &kustomization); err != nil {
+			return fmt.Errorf("failed to decode kustomization %s: %w", kustomizationPath, err)
+		}
+
+		if kustomization == nil {
+			kustomization = map[string]any{}
+		}
+
+		resources, _ := kustomization["resources"].([]any)
+		kustomization["resources"] = append(resources, resource)
+
+		data, err = k8syaml.Marshal(kustomization)
+		if err != nil {
+			return fmt.Errorf("failed to encode kustomization %s: %w", kustomizationPath, err)
+		}
+
+		return fSys.WriteFile(kustomizationPath, data)
+	}
+
+	return fmt.Errorf("directory %s has no kustomization file", dir)
+}
@@ -0,0 +1,190 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/komailo/kubeit/pkg/api/loader"
+)
+
+// kustomizeSourceFiles is a source with a local chart built with an overlay that
+// patches the rendered objects and includes a base
+var kustomizeSourceFiles = map[string]string{
+	"app.yaml": localChartApplication + `  kustomize:
+    path: kustomize/overlay
+`,
+	"charts/web/Chart.yaml": "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+	"charts/web/templates/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+data:
+  mode: default
+`,
+	"kustomize/base/kustomization.yaml": "resources:\n  - policy.yaml\n",
+	"kustomize/base/policy.yaml": `apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: deny-all
+spec:
+  podSelector: {}
+`,
+	"kustomize/overlay/kustomization.yaml": `resources:
+  - ../base
+namespace: platform
+patches:
+  - path: patches/configmap.yaml
+`,
+	"kustomize/overlay/patches/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+data:
+  mode: overlay
+`,
+}
+
+const kustomizedManifest = `---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: deny-all
+  namespace: platform
+spec:
+  podSelector: {}
+---
+apiVersion: v1
+data:
+  mode: overlay
+kind: ConfigMap
+metadata:
+  name: web
+  namespace: platform
+`
+
+func TestHelmRenderer_Manifest_Kustomize(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeFiles(t, sourceDir, kustomizeSourceFiles)
+
+	loaderInt := loader.NewLoader()
+	require.Empty(
+		t,
+		loaderInt.FromSourceURI(sourceDir),
+		"Expected kustomizations not to be loaded as resources",
+	)
+
+	outputDir := t.TempDir()
+	renderer := newTestRenderer(t, t.TempDir(), true)
+
+	require.NoError(t, renderer.manifest(
+		loaderInt.HelmApplications[0],
+		loaderInt,
+		&Options{OutputDir: outputDir, WorkDir: t.TempDir()},
+	))
+
+	manifest, err := os.ReadFile(filepath.Join(outputDir, "web.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(manifest), "  mode: overlay\n")
+	assert.Contains(t, string(manifest), "  name: deny-all\n")
+	assert.Contains(t, string(manifest), "    kubeit.komail.io/app-name: web\n",
+		"Expected the common labels to be added to the kustomization output")
+
+	assert.NoFileExists(
+		t,
+		filepath.Join(sourceDir, "kustomize", "overlay", kustomizeRenderedFile),
+		"Expected the kustomization to be built outside of the source directory",
+	)
+}
+
+func TestKustomizeManifest_Embedded(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeFiles(t, sourceDir, kustomizeSourceFiles)
+
+	loaderInt := loader.NewLoader()
+	require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+
+	helmApplication := loaderInt.HelmApplications[0]
+
+	require.Empty(t, loaderInt.EmbedFiles())
+	assert.Equal(t, "kustomize/overlay", helmApplication.Spec.Kustomize.Path)
+	assert.Contains(t, loaderInt.Files, "kustomize/overlay/patches/configmap.yaml")
+	assert.Contains(t, loaderInt.Files, "kustomize/base/policy.yaml",
+		"Expected the bases of the kustomization to be embedded")
+
+	// An image source builds the kustomization from the embedded files
+	imageLoader := loader.NewLoader()
+	imageLoader.SourceMeta.Scheme = "docker"
+	imageLoader.Files = loaderInt.Files
+
+	manifest, err := kustomizeManifest(
+		helmApplication,
+		imageLoader,
+		kustomizeSourceFiles["charts/web/templates/configmap.yaml"],
+	)
+	require.NoError(t, err)
+	assert.Equal(t, kustomizedManifest, manifest)
+}
+
+func TestKustomizeManifest_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:    "missing directory",
+			wantErr: "failed to read directory kustomize/overlay",
+		},
+		{
+			name: "remote resource",
+			files: map[string]string{
+				"kustomize/overlay/kustomization.yaml": "resources:\n  - https://github.com/example/base\n",
+			},
+			wantErr: "remote resource https://github.com/example/base of the kustomization kustomize/overlay is not supported",
+		},
+		{
+			name: "rendered file name",
+			files: map[string]string{
+				"kustomize/overlay/kustomization.yaml":   "resources: []\n",
+				"kustomize/overlay/kubeit-rendered.yaml": "{}\n",
+			},
+			wantErr: "must not have a kubeit-rendered.yaml file",
+		},
+		{
+			name: "invalid kustomization",
+			files: map[string]string{
+				"kustomize/overlay/kustomization.yaml": "patches:\n  - path: missing.yaml\n",
+			},
+			wantErr: "failed to build the kustomization kustomize/overlay",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceDir := t.TempDir()
+
+			files := map[string]string{
+				"app.yaml": localChartApplication + "  kustomize:\n    path: kustomize/overlay\n",
+			}
+			for name, content := range tt.files {
+				files[name] = content
+			}
+
+			writeFiles(t, sourceDir, files)
+
+			loaderInt := loader.NewLoader()
+			require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+
+			_, err := kustomizeManifest(
+				loaderInt.HelmApplications[0],
+				loaderInt,
+				"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n",
+			)
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
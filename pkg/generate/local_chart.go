@@ -0,0 +1,362 @@
+package generate
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	helmChartLoader "helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/getter"
+
+	"github.com/komailo/kubeit/internal/logger"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+// localChartFileScheme is the repository scheme of chart dependencies on disk
+const localChartFileScheme = "file://"
+
+// localChart loads the chart of a HelmApplication referenced by spec.chart.path. Chart
+// archives are loaded as they are, they are also how charts are embedded in Docker
+// images. Chart directories are copied to destinationDir and their dependencies are
+// built when they are missing, so the source directory is left unchanged.
+func (r *helmRenderer) localChart(
+	helmApplication *v1.HelmApplication,
+	loaderInt *loader.Loader,
+	destinationDir string,
+) (*chart.Chart, error) {
+	chartPath := helmApplication.Spec.Chart.Path
+
+	if filepath.Ext(chartPath) == ".tgz" {
+		data, err := loaderInt.ReadFile(helmApplication, chartPath)
+		if err != nil {
+			return nil, err
+		}
+
+		helmChart, err := helmChartLoader.LoadArchive(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Helm chart %s: %w", chartPath, err)
+		}
+
+		return helmChart, nil
+	}
+
+	localPath, err := loaderInt.LocalPath(helmApplication, chartPath)
+	if err != nil {
+		return nil, err
+	}
+
+	chartDir, err := copyChartDir(localPath, destinationDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.buildChartDependencies(chartDir, map[string]bool{})
+}
+
+// buildChartDependencies loads a chart directory and builds its dependencies, the same
+// way helm dependency build does, when they are not already in its charts directory.
+// The dependencies referenced with a relative file:// repository are built first, as
+// Helm packages them as they are. building holds the charts being built so a cycle of
+// dependencies is reported.
+func (r *helmRenderer) buildChartDependencies(
+	chartDir string,
+	building map[string]bool,
+) (*chart.Chart, error) {
+	chartDir = filepath.Clean(chartDir)
+	if building[chartDir] {
+		return nil, fmt.Errorf("Helm chart %s depends on itself", chartDir)
+	}
+
+	building[chartDir] = true
+	defer delete(building, chartDir)
+
+	helmChart, err := helmChartLoader.Load(chartDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Helm chart: %w", err)
+	}
+
+	dependencies := helmChart.Metadata.Dependencies
+	if len(dependencies) == 0 || action.CheckDependencies(helmChart, dependencies) == nil {
+		return helmChart, nil
+	}
+
+	for _, dependency := range dependencies {
+		dependencyDir := strings.TrimPrefix(dependency.Repository, localChartFileScheme)
+		if !strings.HasPrefix(dependency.Repository, localChartFileScheme) ||
+			filepath.IsAbs(dependencyDir) {
+			continue
+		}
+
+		if _, err := r.buildChartDependencies(
+			filepath.Join(chartDir, dependencyDir),
+			building,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	// Dependencies on disk are built in offline mode, others have to be downloaded
+	if r.offline {
+		for _, dependency := range dependencies {
+			if !strings.HasPrefix(dependency.Repository, localChartFileScheme) {
+				return nil, fmt.Errorf(
+					"dependency %s of chart %s must be downloaded: %w",
+					dependency.Name,
+					helmChart.Name(),
+					ErrOffline,
+				)
+			}
+		}
+	}
+
+	logger.Infof("Building the dependencies of chart %s", helmChart.Name())
+
+	var out strings.Builder
+
+	manager := &downloader.Manager{
+		Out:              &out,
+		ChartPath:        chartDir,
+		Getters:          getter.All(r.settings),
+		RegistryClient:   r.actionConfig.RegistryClient,
+		RepositoryConfig: r.settings.RepositoryConfig,
+		RepositoryCache:  r.settings.RepositoryCache,
+		SkipUpdate:       r.offline,
+	}
+
+	err = manager.Build()
+	if out.Len() != 0 {
+		logger.Debugf("helm dependency build output %s", out.String())
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to build the dependencies of chart %s: %w",
+			helmChart.Name(),
+			err,
+		)
+	}
+
+	helmChart, err = helmChartLoader.Load(chartDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Helm chart: %w", err)
+	}
+
+	return helmChart, nil
+}
+
+// copyChartDir copies a chart directory, and the dependencies it and its dependencies
+// reference with a file:// repository, to destinationDir. The directories keep their
+// relative layout so the dependencies resolve the same way. It returns the path of the
+// copied chart.
+func copyChartDir(chartDir, destinationDir string) (string, error) {
+	chartDir = filepath.Clean(chartDir)
+	rootDir := chartDir
+	dirs := []string{chartDir}
+
+	for i := 0; i < len(dirs); i++ {
+		metadata, err := chartutil.LoadChartfile(filepath.Join(dirs[i], chartutil.ChartfileName))
+		if err != nil {
+			return "", fmt.Errorf("failed to load Helm chart %s: %w", dirs[i], err)
+		}
+
+		for _, dependency := range metadata.Dependencies {
+			if !strings.HasPrefix(dependency.Repository, localChartFileScheme) {
+				continue
+			}
+
+			dependencyDir := strings.TrimPrefix(dependency.Repository, localChartFileScheme)
+			if !filepath.IsAbs(dependencyDir) {
+				dependencyDir = filepath.Join(dirs[i], dependencyDir)
+			}
+
+			if slices.Contains(dirs, dependencyDir) {
+				continue
+			}
+
+			dirs = append(dirs, dependencyDir)
+
+			for !isWithinDir(dependencyDir, rootDir) {
+				rootDir = filepath.Dir(rootDir)
+			}
+		}
+	}
+
+	for _, dir := range dirs {
+		relativeDir, err := filepath.Rel(rootDir, dir)
+		if err != nil {
+			return "", fmt.Errorf("failed to copy Helm chart %s: %w", dir, err)
+		}
+
+		if err := copyDir(dir, filepath.Join(destinationDir, relativeDir)); err != nil {
+			return "", fmt.Errorf("failed to copy Helm chart %s: %w", dir, err)
+		}
+	}
+
+	relativeChartDir, err := filepath.Rel(rootDir, chartDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy Helm chart %s: %w", chartDir, err)
+	}
+
+	return filepath.Join(destinationDir, relativeChartDir), nil
+}
+
+func isWithinDir(path, dir string) bool {
+	relativePath, err := filepath.Rel(dir, path)
+
+	return err == nil && relativePath != ".." &&
+		!strings.HasPrefix(relativePath, ".."+string(filepath.Separator))
+}
+
+// copyDir copies the files of a directory. Symbolic links are resolved, the files and
+// directories they point to are copied in their place.
+func copyDir(sourceDir, destinationDir string) error {
+	return copyResolvedDir(sourceDir, destinationDir, map[string]bool{})
+}
+
+// copyResolvedDir copies a directory with its symbolic links resolved. copying holds
+// the directories being copied so a symbolic link to one of them is reported instead
+// of being copied endlessly.
+func copyResolvedDir(sourceDir, destinationDir string, copying map[string]bool) error {
+	resolvedDir, err := filepath.EvalSymlinks(sourceDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", sourceDir, err)
+	}
+
+	if copying[resolvedDir] {
+		return fmt.Errorf("symbolic link %s points to a directory that contains it", sourceDir)
+	}
+
+	copying[resolvedDir] = true
+	defer delete(copying, resolvedDir)
+
+	return filepath.WalkDir(resolvedDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(resolvedDir, path)
+		if err != nil {
+			return err
+		}
+
+		destinationPath := filepath.Join(destinationDir, relativePath)
+
+		if entry.IsDir() {
+			return os.MkdirAll(destinationPath, 0o755)
+		}
+
+		if entry.Type()&fs.ModeSymlink != 0 {
+			info, err := os.Stat(path)
+			if err != nil {
+				return fmt.Errorf("failed to resolve %s: %w", path, err)
+			}
+
+			if info.IsDir() {
+				return copyResolvedDir(path, destinationPath, copying)
+			}
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(destinationPath, data, 0o644)
+	})
+}
+
+// packageLocalCharts packages the charts referenced by spec.chart.path, with their
+// dependencies, and embeds the archives in the files of the loader. The paths are
+// rewritten to the embedded archives so a Docker image source can render the charts
+// without a chart repository.
+func packageLocalCharts(loaderInt *loader.Loader, generateSetOptions *Options) []error {
+	var (
+		renderer *helmRenderer
+		errs     []error
+	)
+
+	for _, helmApplication := range loaderInt.HelmApplications {
+		chartPath := helmApplication.Spec.Chart.Path
+		if chartPath == "" {
+			continue
+		}
+
+		key, err := loaderInt.FileKey(helmApplication, chartPath)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if filepath.Ext(chartPath) == ".tgz" {
+			data, err := loaderInt.ReadFile(helmApplication, chartPath)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			loaderInt.Files[key] = data
+			helmApplication.Spec.Chart.Path = key
+
+			continue
+		}
+
+		if renderer == nil {
+			renderer, err = newHelmRenderer(generateSetOptions)
+			if err != nil {
+				return append(errs, err)
+			}
+		}
+
+		data, err := renderer.packageLocalChart(helmApplication, loaderInt)
+		if err != nil {
+			errs = append(errs, newAppError(helmApplication.Metadata.Name, StagePrepare, err))
+			continue
+		}
+
+		archiveKey := key + ".tgz"
+
+		loaderInt.Files[archiveKey] = data
+		helmApplication.Spec.Chart.Path = archiveKey
+	}
+
+	return errs
+}
+
+// packageLocalChart builds the chart directory of a HelmApplication and returns it as
+// a chart archive
+func (r *helmRenderer) packageLocalChart(
+	helmApplication *v1.HelmApplication,
+	loaderInt *loader.Loader,
+) ([]byte, error) {
+	workDir, err := os.MkdirTemp("", "kubeit-chart-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	helmChart, err := r.localChart(helmApplication, loaderInt, filepath.Join(workDir, "chart"))
+	if err != nil {
+		return nil, err
+	}
+
+	archivePath, err := chartutil.Save(helmChart, workDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to package Helm chart %s: %w", helmChart.Name(), err)
+	}
+
+	data, err := os.ReadFile(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Helm chart archive: %w", err)
+	}
+
+	return data, nil
+}
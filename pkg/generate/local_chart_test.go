@@ -0,0 +1,190 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/komailo/kubeit/pkg/api/loader"
+)
+
+// writeFiles writes files relative to a directory
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+const localChartApplication = `apiVersion: kubeit.komailo.github.io/v1alpha1
+kind: HelmApplication
+metadata:
+  name: web
+spec:
+  chart:
+    path: charts/web
+    releaseName: web
+`
+
+func TestHelmRenderer_LocalChart(t *testing.T) {
+	sourceDir := t.TempDir()
+
+	writeFiles(t, sourceDir, map[string]string{
+		"app.yaml": localChartApplication,
+		"charts/web/Chart.yaml": `apiVersion: v2
+name: web
+version: 0.1.0
+dependencies:
+  - name: common
+    version: 1.0.0
+    repository: file://../common
+`,
+		"charts/web/templates/configmap.yaml": "apiVersion: v1\nkind: ConfigMap\n",
+		"charts/common/Chart.yaml":            "apiVersion: v2\nname: common\nversion: 1.0.0\n",
+		"charts/common/templates/secret.yaml": "apiVersion: v1\nkind: Secret\n",
+	})
+
+	loaderInt := loader.NewLoader()
+	require.Empty(t, loaderInt.FromSourceURI(sourceDir), "Expected charts not to be loaded as resources")
+	require.Len(t, loaderInt.HelmApplications, 1)
+
+	renderer := newTestRenderer(t, t.TempDir(), true)
+	helmApplication := loaderInt.HelmApplications[0]
+
+	helmChart, resolved, err := renderer.resolveChart(helmApplication, loaderInt, false, t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, "web", helmChart.Name())
+	assert.Empty(t, resolved, "Expected local charts not to be locked")
+	require.Len(t, helmChart.Dependencies(), 1)
+	assert.Equal(t, "common", helmChart.Dependencies()[0].Name())
+	assert.NoDirExists(
+		t,
+		filepath.Join(sourceDir, "charts", "web", "charts"),
+		"Expected the dependencies to be built outside of the source directory",
+	)
+
+	// Packaged charts are embedded so an image source renders them from the archive
+	require.Empty(t, packageLocalCharts(loaderInt, &Options{CacheDir: t.TempDir()}))
+	assert.Equal(t, "charts/web.tgz", helmApplication.Spec.Chart.Path)
+	assert.Contains(t, loaderInt.Files, "charts/web.tgz")
+
+	imageLoader := loader.NewLoader()
+	imageLoader.SourceMeta.Scheme = "docker"
+	imageLoader.Files = loaderInt.Files
+
+	helmChart, _, err = renderer.resolveChart(helmApplication, imageLoader, false, t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, "web", helmChart.Name())
+	require.Len(t, helmChart.Dependencies(), 1)
+}
+
+func TestHelmRenderer_LocalChart_NestedDependencies(t *testing.T) {
+	sourceDir := t.TempDir()
+
+	writeFiles(t, sourceDir, map[string]string{
+		"app.yaml": localChartApplication,
+		"charts/web/Chart.yaml": `apiVersion: v2
+name: web
+version: 0.1.0
+dependencies:
+  - name: common
+    version: 1.0.0
+    repository: file://../common
+`,
+		"charts/common/Chart.yaml": `apiVersion: v2
+name: common
+version: 1.0.0
+dependencies:
+  - name: base
+    version: 1.0.0
+    repository: file://../../shared/base
+`,
+		"shared/base/Chart.yaml":               "apiVersion: v2\nname: base\nversion: 1.0.0\n",
+		"shared/base/templates/configmap.yaml": "apiVersion: v1\nkind: ConfigMap\n",
+		"shared/base/extra/secret.yaml":        "apiVersion: v1\nkind: Secret\n",
+	})
+
+	// The templates of the chart are a symbolic link to a directory outside of it
+	require.NoError(t, os.Symlink(
+		filepath.Join("..", "..", "shared", "base", "extra"),
+		filepath.Join(sourceDir, "charts", "web", "templates"),
+	))
+
+	loaderInt := loader.NewLoader()
+	require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+	require.Len(t, loaderInt.HelmApplications, 1)
+
+	renderer := newTestRenderer(t, t.TempDir(), true)
+
+	helmChart, _, err := renderer.resolveChart(
+		loaderInt.HelmApplications[0],
+		loaderInt,
+		false,
+		t.TempDir(),
+	)
+	require.NoError(t, err)
+	require.Len(t, helmChart.Templates, 1)
+	assert.Equal(t, "templates/secret.yaml", helmChart.Templates[0].Name)
+
+	require.Len(t, helmChart.Dependencies(), 1)
+	common := helmChart.Dependencies()[0]
+	assert.Equal(t, "common", common.Name())
+	require.Len(t, common.Dependencies(), 1, "Expected the nested dependency to be built")
+	assert.Equal(t, "base", common.Dependencies()[0].Name())
+
+	assert.NoDirExists(t, filepath.Join(sourceDir, "charts", "common", "charts"))
+}
+
+func TestCopyDir_SymlinkLoop(t *testing.T) {
+	sourceDir := t.TempDir()
+
+	writeFiles(t, sourceDir, map[string]string{"templates/configmap.yaml": "kind: ConfigMap\n"})
+	require.NoError(t, os.Symlink("..", filepath.Join(sourceDir, "templates", "parent")))
+
+	err := copyDir(sourceDir, t.TempDir())
+	assert.ErrorContains(t, err, "points to a directory that contains it")
+}
+
+func TestHelmRenderer_LocalChart_Errors(t *testing.T) {
+	sourceDir := t.TempDir()
+
+	writeFiles(t, sourceDir, map[string]string{
+		"app.yaml": localChartApplication,
+		"charts/web/Chart.yaml": `apiVersion: v2
+name: web
+version: 0.1.0
+dependencies:
+  - name: remote
+    version: 1.0.0
+    repository: https://charts.example.com
+`,
+	})
+
+	loaderInt := loader.NewLoader()
+	require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+
+	renderer := newTestRenderer(t, t.TempDir(), true)
+	helmApplication := loaderInt.HelmApplications[0]
+
+	_, _, err := renderer.resolveChart(helmApplication, loaderInt, false, t.TempDir())
+	require.ErrorIs(t, err, ErrOffline)
+
+	var appError *AppError
+
+	require.ErrorAs(t, err, &appError)
+	assert.Equal(t, StageLoad, appError.Stage)
+
+	// Chart directories are not embedded in images, only archives are
+	imageLoader := loader.NewLoader()
+	imageLoader.SourceMeta.Scheme = "docker"
+
+	_, _, err = renderer.resolveChart(helmApplication, imageLoader, false, t.TempDir())
+	assert.ErrorContains(t, err, "embedded in the image")
+}
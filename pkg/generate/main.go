@@ -64,6 +64,19 @@ func DockerLabels(
 
 	loaderInt.LogResources()
 
+	// Embed the files referenced by the resources before marshalling so the
+	// references point at the embedded copies
+	embedErrs := loaderInt.EmbedFiles()
+	if embedErrs != nil {
+		return "", embedErrs, nil
+	}
+
+	// Local charts are embedded as archives so the image renders without a chart
+	// repository
+	if packageErrs := packageLocalCharts(loaderInt, generateSetOptions); packageErrs != nil {
+		return "", packageErrs, nil
+	}
+
 	marshalString, marshalErr := loaderInt.Marshal()
 	if marshalErr != nil {
 		return "", marshalErr, nil
@@ -78,6 +91,28 @@ func DockerLabels(
 		fmt.Sprintf("%s/resources=%s", common.KubeitDomain, encodedResources),
 	}
 
+	if len(loaderInt.Files) > 0 {
+		encodedFiles, err := loaderInt.MarshalFiles()
+		if err != nil {
+			return "", []error{err}, nil
+		}
+
+		logger.Infof("Embedding %d files referenced by Kubeit resources", len(loaderInt.Files))
+
+		labels = append(labels, fmt.Sprintf("%s/files=%s", common.KubeitDomain, encodedFiles))
+	}
+
+	if loaderInt.Lock != nil {
+		encodedLock, err := loaderInt.MarshalLock()
+		if err != nil {
+			return "", []error{err}, nil
+		}
+
+		logger.Infof("Embedding the chart lock file")
+
+		labels = append(labels, fmt.Sprintf("%s/lock=%s", common.KubeitDomain, encodedLock))
+	}
+
 	var labelArgs strings.Builder
 	for _, label := range labels {
 		labelArgs.WriteString(fmt.Sprintf("--label %s ", label))
@@ -1,42 +1,123 @@
 package generate
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
+	"golang.org/x/sync/errgroup"
 	"helm.sh/helm/v3/pkg/action"
-	helmChartLoader "helm.sh/helm/v3/pkg/chart/loader"
-	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/repo"
 
 	"github.com/komailo/kubeit/pkg/api/loader"
+	"github.com/komailo/kubeit/pkg/cache"
+	"github.com/komailo/kubeit/pkg/clusterstate"
+	"github.com/komailo/kubeit/pkg/lock"
 
 	"github.com/komailo/kubeit/internal/logger"
 	v1 "github.com/komailo/kubeit/pkg/api/v1"
 )
 
+// DefaultParallelism is the number of applications rendered at the same time when
+// no parallelism is set
+const DefaultParallelism = 4
+
 func ManifestsFromHelm(
 	loaderInt *loader.Loader,
 	generateSetOptions *Options,
 ) []error {
-	var errs []error
-
 	helmApplicationResources := loaderInt.HelmApplications
 
 	if len(helmApplicationResources) == 0 {
 		return []error{errors.New("no HelmApplication resources found")}
 	}
 
-	for _, helmApplications := range helmApplicationResources {
-		err := ManifestFromHelm(
-			*helmApplications,
-			loaderInt,
-			generateSetOptions,
-		)
-		if err != nil {
+	if err := validateOutputOptions(generateSetOptions); err != nil {
+		return []error{err}
+	}
+
+	renderer, err := newHelmRenderer(generateSetOptions)
+	if err != nil {
+		return []error{err}
+	}
+
+	rendered := make([]*renderedApplication, len(helmApplicationResources))
+
+	results := renderConcurrently(
+		len(helmApplicationResources),
+		generateSetOptions.Parallelism,
+		generateSetOptions.ContinueOnError,
+		func(i int) error {
+			var err error
+
+			rendered[i], err = renderer.render(
+				helmApplicationResources[i],
+				loaderInt,
+				generateSetOptions,
+			)
+
+			return err
+		},
+	)
+
+	if generateSetOptions.ContinueOnError {
+		logRenderSummary(helmApplicationResources, results)
+	}
+
+	var errs []error
+
+	for _, err := range results {
+		if err != nil && !errors.Is(err, errSkipped) {
+			errs = append(errs, err)
+		}
+	}
+
+	// The applications are written in their order once they are rendered, so the output
+	// does not depend on the order they finish in
+	var applications []*renderedApplication
+
+	for _, application := range rendered {
+		if application == nil {
+			continue
+		}
+
+		applications = append(applications, application)
+
+		// Only the applications that rendered count, a failed one is not written
+		renderer.recordCRDs(application.Name, application.CRDNames)
+
+		if application.Namespace != "" {
+			renderer.recordNamespace(application.Name, application.Namespace)
+		}
+	}
+
+	// CRDs and Namespaces written by more than one application would conflict when they
+	// are applied, so nothing is written when there are any
+	conflicts := append(renderer.duplicateCRDErrors(), renderer.duplicateNamespaceErrors()...)
+
+	// Without --continue-on-error nothing is written when an application fails, so the
+	// output of the others is not mistaken for a complete one
+	writable := len(conflicts) == 0 && (len(errs) == 0 || generateSetOptions.ContinueOnError)
+
+	if writable && len(applications) != 0 {
+		if err := writeOutput(generateSetOptions, applications); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	errs = append(errs, conflicts...)
+
+	// The lock file is only updated when every application resolved its chart
+	if generateSetOptions.UpdateLock && len(errs) == 0 {
+		if err := writeResolvedLock(loaderInt, renderer.resolved); err != nil {
 			errs = append(errs, err)
 		}
 	}
@@ -44,134 +125,457 @@ func ManifestsFromHelm(
 	return errs
 }
 
+// writeResolvedLock writes the charts the applications resolved to as the lock file
+// of the source
+func writeResolvedLock(loaderInt *loader.Loader, resolved *lock.Lock) error {
+	lockPath := loaderInt.LockPath()
+	if lockPath == "" {
+		return errors.New("the lock file can only be updated for file sources")
+	}
+
+	if err := resolved.Write(lockPath); err != nil {
+		return err
+	}
+
+	logger.Infof("Updated the lock file %s", lockPath)
+
+	return nil
+}
+
+// renderConcurrently calls render for count applications with at most parallelism
+// running at the same time and returns the error of each application in their order,
+// regardless of the order they finish in. Unless continueOnError is set, applications
+// that have not started when one fails are skipped.
+func renderConcurrently(
+	count, parallelism int,
+	continueOnError bool,
+	render func(i int) error,
+) []error {
+	if parallelism < 1 {
+		parallelism = DefaultParallelism
+	}
+
+	results := make([]error, count)
+
+	group, ctx := errgroup.WithContext(context.Background())
+
+	group.SetLimit(parallelism)
+
+	for i := range count {
+		group.Go(func() error {
+			if ctx.Err() != nil {
+				results[i] = errSkipped
+				return nil
+			}
+
+			results[i] = render(i)
+			if continueOnError {
+				return nil
+			}
+
+			return results[i]
+		})
+	}
+
+	_ = group.Wait()
+
+	return results
+}
+
+// logRenderSummary logs which applications were rendered and which failed
+func logRenderSummary(helmApplications []*v1.HelmApplication, results []error) {
+	var (
+		summary  strings.Builder
+		rendered int
+	)
+
+	for i, err := range results {
+		name := helmApplications[i].Metadata.Name
+
+		var appError *AppError
+
+		switch {
+		case err == nil:
+			rendered++
+
+			fmt.Fprintf(&summary, "\n  OK      %s", name)
+		case errors.As(err, &appError):
+			fmt.Fprintf(&summary, "\n  FAILED  %s (%s)", name, appError.Stage)
+		default:
+			fmt.Fprintf(&summary, "\n  FAILED  %s", name)
+		}
+	}
+
+	logger.Infof(
+		"Rendered %d of %d applications:%s",
+		rendered,
+		len(helmApplications),
+		summary.String(),
+	)
+}
+
+// helmRenderer holds the Helm state shared by the applications rendered in a run
+type helmRenderer struct {
+	settings     *cli.EnvSettings
+	actionConfig *action.Configuration
+	charts       *cache.Cache
+	offline      bool
+
+	// defaultRepository pulls charts that do not reference a HelmRepository
+	defaultRepository *chartRepository
+	repositoriesMu    sync.Mutex
+	repositories      map[string]*chartRepository
+
+	// resolved collects the charts the applications resolved to
+	resolvedMu sync.Mutex
+	resolved   *lock.Lock
+
+	// crds maps the names of the CRDs written to the applications writing them
+	crdsMu sync.Mutex
+	crds   map[string][]string
+
+	// namespaces maps the names of the Namespace objects written to the applications
+	// writing them
+	namespacesMu sync.Mutex
+	namespaces   map[string][]string
+
+	// clusterState serves lookup calls, they return nothing when it is nil
+	clusterState *clusterstate.State
+}
+
+func newHelmRenderer(generateSetOptions *Options) (*helmRenderer, error) {
+	settings := cli.New()
+	actionConfig := new(action.Configuration)
+
+	if err := actionConfig.Init(settings.RESTClientGetter(), "", os.Getenv("HELM_DRIVER"), logger.Infof); err != nil {
+		return nil, fmt.Errorf("failed to initialize Helm action configuration: %w", err)
+	}
+
+	registryClient, err := registry.NewClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create registry client: %w", err)
+	}
+
+	actionConfig.RegistryClient = registryClient
+
+	charts, err := cache.Open(generateSetOptions.CacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var clusterState *clusterstate.State
+
+	if generateSetOptions.ClusterStateDir != "" {
+		clusterState, err = clusterstate.Load(generateSetOptions.ClusterStateDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &helmRenderer{
+		settings:     settings,
+		actionConfig: actionConfig,
+		charts:       charts,
+		offline:      generateSetOptions.Offline,
+		resolved:     lock.New(),
+		defaultRepository: &chartRepository{
+			getters:        getter.All(settings),
+			registryClient: registryClient,
+		},
+		repositories: make(map[string]*chartRepository),
+		crds:         make(map[string][]string),
+		namespaces:   make(map[string][]string),
+		clusterState: clusterState,
+	}, nil
+}
+
+// lockResolved records the chart an application resolved to
+func (r *helmRenderer) lockResolved(application lock.Application) {
+	r.resolvedMu.Lock()
+	defer r.resolvedMu.Unlock()
+
+	r.resolved.Set(application)
+}
+
+// ManifestFromHelm renders the manifests of a single HelmApplication
 func ManifestFromHelm(
-	helmApplication v1.HelmApplication,
+	helmApplication *v1.HelmApplication,
+	loaderInt *loader.Loader,
+	generateSetOptions *Options,
+) error {
+	renderer, err := newHelmRenderer(generateSetOptions)
+	if err != nil {
+		return err
+	}
+
+	return renderer.manifest(helmApplication, loaderInt, generateSetOptions)
+}
+
+// manifest renders a HelmApplication and writes its manifests in the output layout
+func (r *helmRenderer) manifest(
+	helmApplication *v1.HelmApplication,
 	loaderInt *loader.Loader,
 	generateSetOptions *Options,
 ) error {
-	name := helmApplication.Spec.Chart.Name
+	rendered, err := r.render(helmApplication, loaderInt, generateSetOptions)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(generateSetOptions, []*renderedApplication{rendered})
+}
+
+// render renders the manifests of a HelmApplication without writing them
+func (r *helmRenderer) render(
+	helmApplication *v1.HelmApplication,
+	loaderInt *loader.Loader,
+	generateSetOptions *Options,
+) (*renderedApplication, error) {
 	releaseName := helmApplication.Spec.Chart.ReleaseName
 	namespace := helmApplication.Spec.Chart.Namespace
-	chartVersion := helmApplication.Spec.Chart.Version
-	repository := helmApplication.Spec.Chart.Repository
-	url := helmApplication.Spec.Chart.URL
-	kubeVersion := generateSetOptions.KubeVersion
 
-	// Initialize Helm environment
-	settings := cli.New()
-	actionConfig := new(action.Configuration)
+	if err := helmApplication.Validate(); err != nil {
+		return nil, newAppError(helmApplication.Metadata.Name, StagePrepare, err)
+	}
 
-	if err := actionConfig.Init(settings.RESTClientGetter(), "", os.Getenv("HELM_DRIVER"), logger.Infof); err != nil {
-		logger.Fatalf("Failed to initialize Helm action configuration: %v", err)
+	// Helm actions store state in their configuration, so every application gets its
+	// own copy sharing the clients
+	actionConfig := *r.actionConfig
+
+	// Every application gets its own work directory so they can be rendered concurrently
+	appName := helmApplication.Metadata.Name
+
+	appWorkDir := filepath.Join(generateSetOptions.WorkDir, "apps", appName)
+	if err := os.MkdirAll(filepath.Dir(appWorkDir), 0o755); err != nil {
+		return nil, newAppError(
+			appName,
+			StagePrepare,
+			fmt.Errorf("failed to create work directory: %w", err),
+		)
 	}
 
-	chartPath, err := pullHelmChart(
-		settings,
-		actionConfig,
-		repository,
-		name,
-		url,
-		chartVersion,
-		generateSetOptions,
+	if err := os.Mkdir(appWorkDir, 0o755); err != nil {
+		return nil, newAppError(appName, StagePrepare, fmt.Errorf(
+			"failed to create work directory, is the name used twice: %w",
+			err,
+		))
+	}
+
+	chart, resolved, err := r.resolveChart(
+		helmApplication,
+		loaderInt,
+		generateSetOptions.UpdateLock,
+		filepath.Join(appWorkDir, "charts"),
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	chart, err := helmChartLoader.Load(chartPath)
-	if err != nil {
-		logger.Fatalf("Failed to load Helm chart: %v", err)
+	if helmApplication.Spec.Chart.Path == "" {
+		r.lockResolved(resolved)
 	}
 
-	helmCliValuesOptions, err := generateHelmValues(
-		helmApplication.Spec.Values,
+	chartValues, valueLayers, err := generateHelmValues(
+		helmApplication,
 		loaderInt,
 		generateSetOptions,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to generate Helm values: %w", err)
+		return nil, newAppError(
+			appName,
+			StageValues,
+			fmt.Errorf("failed to generate Helm values: %w", err),
+		)
 	}
 
-	chartValues, err := helmCliValuesOptions.MergeValues(nil)
-	if err != nil {
-		return fmt.Errorf("unable to merge Helm values: %w", err)
+	// Validate the values before rendering so every violation can be reported together
+	// with the value entries that caused it
+	if err := validateValuesSchema(chart, chartValues, valueLayers, loaderInt); err != nil {
+		return nil, newAppError(appName, StageValidate, err)
 	}
 
-	installClient := action.NewInstall(actionConfig)
+	installClient := action.NewInstall(&actionConfig)
 	installClient.DryRun = true
 	installClient.ReleaseName = releaseName
 	installClient.Namespace = namespace
 	installClient.ClientOnly = true
 
-	if kubeVersion != "" {
-		parsedKubeVersion, err := chartutil.ParseKubeVersion(kubeVersion)
-		if err != nil {
-			return fmt.Errorf("invalid kube version '%s': %w", kubeVersion, err)
-		}
+	// Lookups are served by the simulated cluster state, Helm only renders with a REST
+	// config when the dry run runs against a server
+	if r.clusterState != nil {
+		actionConfig.RESTClientGetter = r.clusterState.RESTClientGetter()
+		installClient.DryRunOption = "server"
+	}
 
-		installClient.KubeVersion = parsedKubeVersion
+	capabilities, err := resolveCapabilities(loaderInt, generateSetOptions)
+	if err != nil {
+		return nil, newAppError(appName, StageRender, err)
 	}
 
+	installClient.KubeVersion = capabilities.KubeVersion
+	installClient.APIVersions = capabilities.APIVersions
+
 	release, err := installClient.Run(chart, chartValues)
 	if err != nil {
-		logger.Fatalf("Failed to render templates: %v", err)
+		return nil, newAppError(appName, StageRender, fmt.Errorf("failed to render templates: %w", err))
 	}
 
-	// fail if manifest file is empty
-	if release.Manifest == "" {
-		return errors.New("No manifest file generated")
-	}
-	// TODO: Add common labels and annotations to the manifest
-	// processedManifest, err := addCommonLabelsAndAnnotationsToK8sObject(release.Manifest)
 	processedManifest := release.Manifest
 
-	if err != nil {
-		logger.Fatalf("Failed to process manifest: %v", err)
+	var hooksSpec v1.HooksSpec
+	if helmApplication.Spec.Hooks != nil {
+		hooksSpec = *helmApplication.Spec.Hooks
 	}
 
-	// Define the file path where you want to write the manifest
-	manifestFilePath := filepath.Join(
-		generateSetOptions.OutputDir,
-		helmApplication.Metadata.Name+".yaml",
-	)
+	var hooksManifest string
 
-	// Write the manifest content to the file
-	err = os.WriteFile(manifestFilePath, []byte(processedManifest), os.ModePerm)
-	if err != nil {
-		logger.Fatalf("Failed to write manifest to file: %v", err)
+	if hooksSpec.Policy != v1.HookPolicyDrop {
+		hooksManifest, err = hookManifests(release.Hooks, hooksSpec)
+		if err != nil {
+			return nil, newAppError(appName, StageRender, err)
+		}
 	}
 
-	return nil
-}
+	if hooksSpec.Policy == "" || hooksSpec.Policy == v1.HookPolicyInline {
+		processedManifest += hooksManifest
+		hooksManifest = ""
+	}
 
-// pull helm charts and place them in the work directory
-func pullHelmChart(
-	settings *cli.EnvSettings,
-	actionConfig *action.Configuration,
-	repository, name, url, version string,
-	generateSetOptions *Options,
-) (string, error) {
-	// Pull OCI chart
-	pullClient := action.NewPullWithOpts(action.WithConfig(actionConfig))
+	var crdsPolicy v1.CRDPolicy
+	if helmApplication.Spec.CRDs != nil {
+		crdsPolicy = helmApplication.Spec.CRDs.Policy
+	}
 
-	registryClient, err := registry.NewClient()
+	var crdsManifest string
+
+	var crdNames []string
+
+	if crdsPolicy != v1.CRDPolicySkip {
+		crdsManifest, crdNames, err = chartCRDs(chart)
+		if err != nil {
+			return nil, newAppError(appName, StageRender, err)
+		}
+	}
+
+	// CRDs are written before the objects that use them
+	if crdsPolicy == "" || crdsPolicy == v1.CRDPolicyInclude {
+		processedManifest = crdsManifest + processedManifest
+		crdsManifest = ""
+	}
+
+	// fail if manifest file is empty
+	if processedManifest == "" {
+		return nil, newAppError(appName, StageRender, errors.New("No manifest file generated"))
+	}
+
+	// Charts often leave the namespace to kubectl, the objects are put in the namespace
+	// of the release so they apply the same way everywhere
+	if namespace != "" {
+		defaulter, err := newNamespaceDefaulter(
+			appName,
+			namespace,
+			capabilities,
+			processedManifest,
+			crdsManifest,
+		)
+		if err != nil {
+			return nil, newAppError(appName, StageRender, err)
+		}
+
+		for _, manifest := range []*string{&processedManifest, &hooksManifest} {
+			*manifest, err = transformObjects(*manifest, defaulter.apply)
+			if err != nil {
+				return nil, newAppError(appName, StageRender, err)
+			}
+		}
+	}
+
+	// The kustomization is built with the objects as Helm rendered them, the patches
+	// and common metadata of Kubeit apply to its output
+	if kustomize := helmApplication.Spec.Kustomize; kustomize != nil && kustomize.Path != "" {
+		processedManifest, err = kustomizeManifest(helmApplication, loaderInt, processedManifest)
+		if err != nil {
+			return nil, newAppError(appName, StageKustomize, err)
+		}
+	}
+
+	var createdNamespace string
+
+	// The Namespace is written before the objects in it
+	if spec := helmApplication.Spec.Namespace; spec != nil && spec.Create {
+		namespaceObject, err := namespaceManifest(*spec, namespace)
+		if err != nil {
+			return nil, newAppError(appName, StageRender, err)
+		}
+
+		processedManifest = namespaceObject + processedManifest
+		createdNamespace = namespace
+	}
+
+	patcher, err := newObjectPatcher(helmApplication, loaderInt, generateSetOptions)
 	if err != nil {
-		logger.Fatalf("Failed to create registry client: %v", err)
+		return nil, newAppError(appName, StagePatch, err)
 	}
 
-	pullClient.SetRegistryClient(registryClient)
-	pullClient.Settings = settings
+	commonLabels, commonAnnotations, err := generateCommonK8sLabelsAndAnnotationsToK8sObject(
+		helmApplication,
+		loaderInt,
+		chart,
+		chartValues,
+	)
+	if err != nil {
+		return nil, newAppError(appName, StageRender, err)
+	}
 
-	destinationDir := filepath.Join(generateSetOptions.WorkDir, "charts")
+	// Patches are applied before the common labels and annotations so they cannot
+	// remove them
+	for _, manifest := range []*string{&processedManifest, &hooksManifest, &crdsManifest} {
+		*manifest, err = transformObjects(*manifest, patcher.apply)
+		if err != nil {
+			return nil, newAppError(appName, StagePatch, err)
+		}
 
-	// Delete the destinations directory if it already exists
-	if _, err := os.Stat(destinationDir); err == nil {
-		if err := os.RemoveAll(destinationDir); err != nil {
-			return "", fmt.Errorf("Failed to remove destination directory: %w", err)
+		*manifest, err = addCommonLabelsAndAnnotationsToK8sObject(
+			*manifest,
+			commonLabels.GenerateLabels(),
+			commonAnnotations.GenerateAnnotations(),
+			helmApplication.Spec.PropagateToPodTemplates,
+		)
+		if err != nil {
+			return nil, newAppError(appName, StageRender, err)
 		}
 	}
+
+	patcher.warnUnmatched(appName)
+
+	// The objects are written in a canonical order so the output is the same on every run
+	for _, manifest := range []*string{&processedManifest, &hooksManifest, &crdsManifest} {
+		*manifest, err = sortObjects(*manifest)
+		if err != nil {
+			return nil, newAppError(appName, StageRender, err)
+		}
+	}
+
+	return &renderedApplication{
+		Name:      appName,
+		Manifest:  processedManifest,
+		Hooks:     hooksManifest,
+		CRDs:      crdsManifest,
+		Notes:     strings.TrimSpace(release.Info.Notes),
+		CRDNames:  crdNames,
+		Namespace: createdNamespace,
+	}, nil
+}
+
+// pull helm charts and place them in the destination directory
+func pullHelmChart(
+	settings *cli.EnvSettings,
+	chartRepo *chartRepository,
+	repository, name, url, version string,
+	destinationDir string,
+) (string, error) {
 	// Create the destination directory
-	if err := os.MkdirAll(destinationDir, os.ModePerm); err != nil {
+	if err := os.MkdirAll(destinationDir, 0o755); err != nil {
 		return "", fmt.Errorf("Failed to create destination directory: %w", err)
 	}
 
@@ -187,124 +591,65 @@ func pullHelmChart(
 	case url != "":
 		logger.Infof("Pulling chart from %s", url)
 		chartRef = url
+	case registry.IsOCI(repository):
+		logger.Infof("Pulling chart %s from %s", name, repository)
+		chartRef = strings.TrimSuffix(repository, "/") + "/" + name
 	default:
 		logger.Infof("Pulling chart %s from %s", name, repository)
-		pullClient.RepoURL = repository
-		chartRef = name
-	}
 
-	pullClient.Version = version
-	pullClient.DestDir = destinationDir
+		chartURL, err := repo.FindChartInAuthAndTLSAndPassRepoURL(
+			repository,
+			chartRepo.Username,
+			chartRepo.Password,
+			name,
+			version,
+			"",
+			"",
+			"",
+			false,
+			chartRepo.PassCredentials,
+			chartRepo.getters,
+		)
+		if err != nil {
+			return "", fmt.Errorf("Failed to pull chart: %w", err)
+		}
 
-	out, err := pullClient.Run(chartRef)
-	if err != nil {
-		return "", fmt.Errorf("Failed to pull chart: %w", err)
+		chartRef = chartURL
 	}
 
-	if out != "" {
-		logger.Infof("helm pull run output %s", out)
+	var out strings.Builder
+
+	chartDownloader := downloader.ChartDownloader{
+		Out:     &out,
+		Verify:  downloader.VerifyNever,
+		Getters: chartRepo.getters,
+		Options: []getter.Option{
+			getter.WithBasicAuth(chartRepo.Username, chartRepo.Password),
+			getter.WithPassCredentialsAll(chartRepo.PassCredentials),
+			getter.WithPlainHTTP(chartRepo.PlainHTTP),
+		},
+		RegistryClient:   chartRepo.registryClient,
+		RepositoryConfig: settings.RepositoryConfig,
+		RepositoryCache:  settings.RepositoryCache,
 	}
 
-	// Check if the chart was downloaded to the destination directory
-	files, err := os.ReadDir(destinationDir)
-	if err != nil {
-		return "", fmt.Errorf("Failed to read destination directory: %w", err)
+	if registry.IsOCI(chartRef) {
+		chartDownloader.Options = append(
+			chartDownloader.Options,
+			getter.WithRegistryClient(chartRepo.registryClient),
+		)
 	}
 
-	var chartFileName string
-	// we also want to fail if there are multiple chart files in the destination directory
-	if len(files) > 1 {
-		return "", errors.New("Multiple chart files found in destination directory")
+	chartPath, _, err := chartDownloader.DownloadTo(chartRef, version, destinationDir)
+	if out.Len() != 0 {
+		logger.Infof("helm pull run output %s", out.String())
 	}
 
-	chartFileName = files[0].Name()
-
-	if chartFileName == "" {
-		return "", errors.New("No chart file found in destination directory")
+	if err != nil {
+		return "", fmt.Errorf("Failed to pull chart: %w", err)
 	}
 
-	logger.Infof("Pulled Helm chart file: %s", chartFileName)
+	logger.Infof("Pulled Helm chart file: %s", filepath.Base(chartPath))
 
-	return filepath.Join(destinationDir, chartFileName), nil
+	return chartPath, nil
 }
-
-// func addCommonLabelsAndAnnotationsToK8sObject(manifest string) (string, error) {
-// 	var processedDocuments []string
-
-// 	// Create a YAML decoder
-// 	:= yaml.NewYAMLToJSONDecoder(bytes.NewReader([]byte(manifest)))
-
-// 	for {
-// 		var rawObj runtime.RawExtension
-// 		if err := decoder.Decode(&rawObj); err != nil {
-// 			if err.Error() == "EOF" {
-// 				break
-// 			}
-// 			logger.Printf("Skipping invalid Kubernetes object: %v", err)
-// 			continue
-// 		}
-// 		if rawObj.Raw == nil {
-// 			continue
-// 		}
-
-// 		// Decode the raw object into a Kubernetes object
-// 		obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(rawObj.Raw, nil, nil)
-// 		if err != nil {
-// 			logger.Printf("Skipping invalid Kubernetes object: %v\n%s", err, rawObj)
-// 			processedDocuments = append(processedDocuments, string(rawObj.Raw))
-// 			continue
-// 		}
-
-// 		// Add labels to valid Kubernetes objects
-// 		accessor, err := meta.Accessor(obj)
-// 		if err != nil {
-// 			logger.Printf("Skipping non-Kubernetes object: %v", err)
-// 			continue
-// 		}
-// 		commonLabels, commonAnnotations := generateCommonK8sLabelsAndAnnotationsToK8sObject()
-
-// 		// Add the labels
-// 		labelsMap := accessor.GetLabels()
-// 		if labelsMap == nil {
-// 			labelsMap = make(map[string]string)
-// 		}
-// 		for key, value := range commonLabels.GenerateLabels() {
-// 			labelsMap[key] = value
-// 		}
-// 		accessor.SetLabels(labelsMap)
-
-// 		// Add the annotations
-// 		annotationsMap := accessor.GetAnnotations()
-// 		if annotationsMap == nil {
-// 			annotationsMap = make(map[string]string)
-// 		}
-// 		for key, value := range commonAnnotations.GenerateAnnotations() {
-// 			annotationsMap[key] = value
-// 		}
-// 		accessor.SetAnnotations(annotationsMap)
-
-// 		// Serialize the modified object back to YAML
-// 		serializer := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
-// 		var buf bytes.Buffer
-// 		if err := serializer.Encode(obj, &buf); err != nil {
-// 			return "", fmt.Errorf("failed to encode object: %v", err)
-// 		}
-
-// 		processedDocuments = append(processedDocuments, buf.String())
-// 	}
-
-// 	// Join the processed documents back into a single manifest
-// 	return strings.Join(processedDocuments, "---\n"), nil
-// }
-
-// func generateCommonK8sLabelsAndAnnotationsToK8sObject() (CommonK8sLabels, CommonK8sAnnotations) {
-// 	var labels CommonK8sLabels
-
-// 	annotations := CommonK8sAnnotations{
-// 		AppName:     "kubeit",
-// 		AppType:     "v0.1.0",
-// 		GeneratedBy: "kubeit",
-// 	}
-
-// 	return labels, annotations
-// }
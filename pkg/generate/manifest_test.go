@@ -0,0 +1,133 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/komailo/kubeit/pkg/api/loader"
+)
+
+func TestRenderConcurrently(t *testing.T) {
+	var running, maxRunning atomic.Int32
+
+	results := renderConcurrently(6, 2, true, func(i int) error {
+		current := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			observed := maxRunning.Load()
+			if current <= observed || maxRunning.CompareAndSwap(observed, current) {
+				break
+			}
+		}
+
+		// Finish in the reverse order of the applications
+		time.Sleep(time.Duration(6-i) * 5 * time.Millisecond)
+
+		if i%2 == 0 {
+			return fmt.Errorf("app %d failed", i)
+		}
+
+		return nil
+	})
+
+	assert.Equal(t, []error{
+		fmt.Errorf("app 0 failed"),
+		nil,
+		fmt.Errorf("app 2 failed"),
+		nil,
+		fmt.Errorf("app 4 failed"),
+		nil,
+	}, results, "Expected errors in the order of the applications")
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2), "Expected at most 2 applications at once")
+	assert.Equal(
+		t,
+		[]error{nil, nil, nil},
+		renderConcurrently(3, 0, false, func(int) error { return nil }),
+	)
+}
+
+func TestRenderConcurrently_FailFast(t *testing.T) {
+	var rendered atomic.Int32
+
+	results := renderConcurrently(5, 1, false, func(i int) error {
+		rendered.Add(1)
+
+		if i == 1 {
+			return newAppError("app-1", StageRender, errors.New("failed to render templates"))
+		}
+
+		return nil
+	})
+
+	assert.Equal(t, int32(2), rendered.Load(), "Expected the applications after the failure to be skipped")
+	require.Len(t, results, 5)
+	assert.NoError(t, results[0])
+
+	var appError *AppError
+	require.ErrorAs(t, results[1], &appError)
+	assert.Equal(t, "app-1", appError.App)
+	assert.Equal(t, StageRender, appError.Stage)
+	assert.Equal(t, "app-1: render failed: failed to render templates", appError.Error())
+
+	for _, result := range results[2:] {
+		require.ErrorIs(t, result, errSkipped)
+	}
+}
+
+func TestManifestsFromHelm_Failure(t *testing.T) {
+	sourceDir := t.TempDir()
+
+	writeFiles(t, sourceDir, map[string]string{
+		"app.yaml": localChartApplication + "---\n" +
+			strings.NewReplacer(
+				"name: web", "name: broken",
+				"path: charts/web", "path: charts/broken",
+				"releaseName: web", "releaseName: broken",
+			).Replace(localChartApplication),
+		"charts/web/Chart.yaml":                  "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+		"charts/web/templates/configmap.yaml":    "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n",
+		"charts/broken/Chart.yaml":               "apiVersion: v2\nname: broken\nversion: 0.1.0\n",
+		"charts/broken/templates/configmap.yaml": `{{ fail "broken chart" }}`,
+	})
+
+	loaderInt := loader.NewLoader()
+	require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+	require.Len(t, loaderInt.HelmApplications, 2)
+
+	// The renderer of the run uses the Helm directories of the test renderer
+	newTestRenderer(t, t.TempDir(), true)
+
+	for _, continueOnError := range []bool{false, true} {
+		t.Run(fmt.Sprintf("continue on error %t", continueOnError), func(t *testing.T) {
+			outputDir := t.TempDir()
+
+			errs := ManifestsFromHelm(loaderInt, &Options{
+				OutputDir:       outputDir,
+				OutputLayout:    OutputLayoutSingle,
+				WorkDir:         t.TempDir(),
+				CacheDir:        t.TempDir(),
+				Offline:         true,
+				ContinueOnError: continueOnError,
+			})
+			require.Len(t, errs, 1)
+			assert.Contains(t, errs[0].Error(), "broken chart")
+
+			_, err := os.Stat(filepath.Join(outputDir, "manifests.yaml"))
+			if continueOnError {
+				assert.NoError(t, err, "Expected the applications that succeeded to be written")
+			} else {
+				require.ErrorIs(t, err, os.ErrNotExist, "Expected nothing to be written")
+			}
+		})
+	}
+}
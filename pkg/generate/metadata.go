@@ -0,0 +1,264 @@
+package generate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/komailo/kubeit/common"
+	"github.com/komailo/kubeit/internal/version"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+// generateCommonK8sLabelsAndAnnotationsToK8sObject returns the labels and annotations
+// added to every object rendered for a HelmApplication
+func generateCommonK8sLabelsAndAnnotationsToK8sObject(
+	helmApplication *v1.HelmApplication,
+	loaderInt *loader.Loader,
+	helmChart *chart.Chart,
+	chartValues map[string]any,
+) (CommonK8sLabels, CommonK8sAnnotations, error) {
+	appName := helmApplication.Metadata.Name
+
+	configHash, err := configHash(helmChart, chartValues, helmApplication.Spec.Chart.Path != "")
+	if err != nil {
+		return CommonK8sLabels{}, CommonK8sAnnotations{}, err
+	}
+
+	labels := CommonK8sLabels{
+		AppName:     appName,
+		GeneratedBy: common.KubeitCLIName,
+		Labels:      helmApplication.Spec.CommonLabels,
+	}
+
+	annotations := CommonK8sAnnotations{
+		AppName:     appName,
+		AppType:     "HelmApplication",
+		GeneratedBy: common.KubeitCLIName,
+		Version:     version.GetBuildInfo().Version,
+		ConfigHash:  configHash,
+		Annotations: helmApplication.Spec.CommonAnnotations,
+	}
+
+	if loaderInt.SourceMeta.Scheme == "docker" {
+		annotations.SourceImage = loaderInt.SourceMeta.Source
+		annotations.SourceDigest = loaderInt.ImageMetadata.Digest
+	}
+
+	return labels, annotations, nil
+}
+
+// configHash returns the digest of the chart and the values an application is
+// rendered with. Local charts change without a new version, so the digest of their
+// content is included.
+func configHash(
+	helmChart *chart.Chart,
+	chartValues map[string]any,
+	localChart bool,
+) (string, error) {
+	config := map[string]any{
+		"chart":   helmChart.Metadata.Name,
+		"version": helmChart.Metadata.Version,
+		"values":  chartValues,
+	}
+
+	if localChart {
+		config["digest"] = chartContentDigest(helmChart)
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash the config: %w", err)
+	}
+
+	hash := sha256.Sum256(data)
+
+	return "sha256:" + hex.EncodeToString(hash[:]), nil
+}
+
+// chartContentDigest returns the digest of the files of a chart and its subcharts. The
+// files are hashed rather than an archive, as archives hold their creation time, so a
+// chart directory and its archive embedded in a Docker image have the same digest.
+func chartContentDigest(helmChart *chart.Chart) string {
+	hash := sha256.New()
+	writeChartContent(hash, helmChart)
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// writeChartContent writes the files of a chart, sorted by name, followed by those of
+// its subcharts. The files of the charts directory are written with their subchart.
+func writeChartContent(w io.Writer, helmChart *chart.Chart) {
+	files := slices.Clone(helmChart.Raw)
+	slices.SortFunc(files, func(a, b *chart.File) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	for _, file := range files {
+		if strings.HasPrefix(file.Name, "charts/") {
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\x00%d\x00", file.Name, len(file.Data))
+		w.Write(file.Data)
+	}
+
+	dependencies := slices.Clone(helmChart.Dependencies())
+	slices.SortFunc(dependencies, func(a, b *chart.Chart) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	for _, dependency := range dependencies {
+		fmt.Fprintf(w, "charts/%s\x00", dependency.Name())
+		writeChartContent(w, dependency)
+	}
+}
+
+// addCommonLabelsAndAnnotationsToK8sObject adds labels and annotations to every object
+// of a multi-document manifest, and to the pod templates of workloads when
+// podTemplates is set
+func addCommonLabelsAndAnnotationsToK8sObject(
+	manifest string,
+	labels, annotations map[string]string,
+	podTemplates bool,
+) (string, error) {
+	return transformObjects(manifest, func(object map[string]any) (map[string]any, error) {
+		setMetadata(object, labels, annotations)
+
+		if podTemplates {
+			for _, template := range podTemplatesOf(object) {
+				setMetadata(template, labels, annotations)
+			}
+		}
+
+		return object, nil
+	})
+}
+
+// transformObjects decodes every object of a multi-document manifest, replaces it with
+// the result of transform and encodes the manifest again. The comments before each
+// object, such as the source of the template, are kept and empty documents are
+// dropped.
+func transformObjects(
+	manifest string,
+	transform func(object map[string]any) (map[string]any, error),
+) (string, error) {
+	var processedDocuments strings.Builder
+
+	for _, document := range splitDocuments(manifest) {
+		lines := strings.SplitAfter(document, "\n")
+
+		var header strings.Builder
+
+		for len(lines) != 0 &&
+			(strings.HasPrefix(lines[0], "#") || strings.TrimSpace(lines[0]) == "") {
+			header.WriteString(lines[0])
+			lines = lines[1:]
+		}
+
+		var object map[string]any
+		if err := k8syaml.Unmarshal([]byte(strings.Join(lines, "")), &object); err != nil {
+			return "", fmt.Errorf("failed to decode rendered object: %w\n%s", err, document)
+		}
+
+		if len(object) == 0 {
+			continue
+		}
+
+		object, err := transform(object)
+		if err != nil {
+			return "", err
+		}
+
+		data, err := k8syaml.Marshal(object)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode rendered object: %w", err)
+		}
+
+		processedDocuments.WriteString("---\n")
+		processedDocuments.WriteString(header.String())
+		processedDocuments.Write(data)
+	}
+
+	return processedDocuments.String(), nil
+}
+
+// splitDocuments splits a multi-document manifest at its --- separators
+func splitDocuments(manifest string) []string {
+	var (
+		documents []string
+		current   strings.Builder
+	)
+
+	for _, line := range strings.SplitAfter(manifest, "\n") {
+		if strings.TrimRight(line, " \t\r\n") == "---" {
+			documents = append(documents, current.String())
+			current.Reset()
+
+			continue
+		}
+
+		current.WriteString(line)
+	}
+
+	return append(documents, current.String())
+}
+
+// setMetadata sets labels and annotations in the metadata of an object or template
+func setMetadata(object map[string]any, labels, annotations map[string]string) {
+	metadata, _ := object["metadata"].(map[string]any)
+	if metadata == nil {
+		metadata = map[string]any{}
+		object["metadata"] = metadata
+	}
+
+	for field, values := range map[string]map[string]string{
+		"labels":      labels,
+		"annotations": annotations,
+	} {
+		if len(values) == 0 {
+			continue
+		}
+
+		existing, _ := metadata[field].(map[string]any)
+		if existing == nil {
+			existing = map[string]any{}
+			metadata[field] = existing
+		}
+
+		for key, value := range values {
+			existing[key] = value
+		}
+	}
+}
+
+// podTemplatesOf returns the pod templates of a workload, spec.template of
+// Deployments, StatefulSets, DaemonSets, ReplicaSets and Jobs and
+// spec.jobTemplate.spec.template of CronJobs
+func podTemplatesOf(object map[string]any) []map[string]any {
+	spec, _ := object["spec"].(map[string]any)
+	if spec == nil {
+		return nil
+	}
+
+	if template, ok := spec["template"].(map[string]any); ok {
+		return []map[string]any{template}
+	}
+
+	jobTemplate, _ := spec["jobTemplate"].(map[string]any)
+	jobSpec, _ := jobTemplate["spec"].(map[string]any)
+
+	if template, ok := jobSpec["template"].(map[string]any); ok {
+		return []map[string]any{template}
+	}
+
+	return nil
+}
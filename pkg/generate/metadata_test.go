@@ -0,0 +1,212 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+
+	"github.com/komailo/kubeit/pkg/api"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+const workloadsManifest = `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    team: web
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+---
+# Source: web/templates/empty.yaml
+---
+# Source: web/templates/cronjob.yaml
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec: {}
+`
+
+func TestAddCommonLabelsAndAnnotationsToK8sObject(t *testing.T) {
+	labels := map[string]string{"kubeit.komail.io/app-name": "web"}
+	annotations := map[string]string{"kubeit.komail.io/config-hash": "sha256:abc"}
+
+	manifest, err := addCommonLabelsAndAnnotationsToK8sObject(
+		workloadsManifest,
+		labels,
+		annotations,
+		false,
+	)
+	require.NoError(t, err)
+	assert.Equal(t, `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    kubeit.komail.io/config-hash: sha256:abc
+  labels:
+    kubeit.komail.io/app-name: web
+    team: web
+  name: web
+spec:
+  template:
+    metadata:
+      labels:
+        app: web
+---
+# Source: web/templates/cronjob.yaml
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  annotations:
+    kubeit.komail.io/config-hash: sha256:abc
+  labels:
+    kubeit.komail.io/app-name: web
+  name: cleanup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec: {}
+`, manifest)
+
+	manifest, err = addCommonLabelsAndAnnotationsToK8sObject(
+		workloadsManifest,
+		labels,
+		annotations,
+		true,
+	)
+	require.NoError(t, err)
+	assert.Contains(t, manifest, `  template:
+    metadata:
+      annotations:
+        kubeit.komail.io/config-hash: sha256:abc
+      labels:
+        app: web
+        kubeit.komail.io/app-name: web
+`)
+	assert.Contains(t, manifest, `      template:
+        metadata:
+          annotations:
+            kubeit.komail.io/config-hash: sha256:abc
+          labels:
+            kubeit.komail.io/app-name: web
+        spec: {}
+`)
+
+	_, err = addCommonLabelsAndAnnotationsToK8sObject("kind: [", labels, annotations, false)
+	assert.ErrorContains(t, err, "failed to decode rendered object")
+}
+
+func TestGenerateCommonK8sLabelsAndAnnotationsToK8sObject(t *testing.T) {
+	helmApplication := &v1.HelmApplication{
+		BaseObject: api.BaseObject{Metadata: api.ObjectMeta{Name: "web"}},
+		Spec: v1.HelmApplicationSpec{
+			CommonLabels: map[string]string{
+				"team":                      "web",
+				"kubeit.komail.io/app-name": "overridden",
+			},
+			CommonAnnotations: map[string]string{"owner": "platform"},
+		},
+	}
+	helmChart := &chart.Chart{Metadata: &chart.Metadata{Name: "web", Version: "1.0.0"}}
+
+	loaderInt := loader.NewLoader()
+	loaderInt.SourceMeta = api.SourceMeta{Scheme: "docker", Source: "registry.example.com/web:1.0"}
+	loaderInt.ImageMetadata.Digest = "sha256:image"
+
+	labels, annotations, err := generateCommonK8sLabelsAndAnnotationsToK8sObject(
+		helmApplication,
+		loaderInt,
+		helmChart,
+		map[string]any{"replicas": 2},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"team":                          "web",
+		"kubeit.komail.io/app-name":     "web",
+		"kubeit.komail.io/generated-by": "kubeit",
+	}, labels.GenerateLabels())
+
+	generated := annotations.GenerateAnnotations()
+	assert.Equal(t, "platform", generated["owner"])
+	assert.Equal(t, "HelmApplication", generated["kubeit.komail.io/app-type"])
+	assert.Equal(t, "registry.example.com/web:1.0", generated["kubeit.komail.io/source-image"])
+	assert.Equal(t, "sha256:image", generated["kubeit.komail.io/source-digest"])
+	assert.NotEmpty(t, generated["kubeit.komail.io/version"])
+
+	// The config hash only changes with the chart and the values
+	_, sameAnnotations, err := generateCommonK8sLabelsAndAnnotationsToK8sObject(
+		helmApplication,
+		loader.NewLoader(),
+		helmChart,
+		map[string]any{"replicas": 2},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, annotations.ConfigHash, sameAnnotations.ConfigHash)
+	assert.NotContains(t, sameAnnotations.GenerateAnnotations(), "kubeit.komail.io/source-image")
+
+	_, changedAnnotations, err := generateCommonK8sLabelsAndAnnotationsToK8sObject(
+		helmApplication,
+		loaderInt,
+		helmChart,
+		map[string]any{"replicas": 3},
+	)
+	require.NoError(t, err)
+	assert.NotEqual(t, annotations.ConfigHash, changedAnnotations.ConfigHash)
+}
+
+func TestConfigHash_LocalChart(t *testing.T) {
+	newChart := func(template string) *chart.Chart {
+		helmChart := &chart.Chart{
+			Metadata: &chart.Metadata{Name: "web", Version: "1.0.0"},
+			Raw: []*chart.File{
+				{Name: "Chart.yaml", Data: []byte("name: web\nversion: 1.0.0\n")},
+				{Name: "templates/configmap.yaml", Data: []byte(template)},
+			},
+		}
+		helmChart.AddDependency(&chart.Chart{
+			Metadata: &chart.Metadata{Name: "common", Version: "1.0.0"},
+			Raw:      []*chart.File{{Name: "Chart.yaml", Data: []byte("name: common\n")}},
+		})
+
+		return helmChart
+	}
+
+	values := map[string]any{"replicas": 2}
+
+	localHash, err := configHash(newChart("kind: ConfigMap\n"), values, true)
+	require.NoError(t, err)
+
+	sameHash, err := configHash(newChart("kind: ConfigMap\n"), values, true)
+	require.NoError(t, err)
+	assert.Equal(t, localHash, sameHash)
+
+	editedHash, err := configHash(newChart("kind: Secret\n"), values, true)
+	require.NoError(t, err)
+	assert.NotEqual(t, localHash, editedHash,
+		"Expected a template change of a local chart to change the hash without a new version")
+
+	// Charts from a repository are identified by their version
+	remoteHash, err := configHash(newChart("kind: ConfigMap\n"), values, false)
+	require.NoError(t, err)
+
+	editedRemoteHash, err := configHash(newChart("kind: Secret\n"), values, false)
+	require.NoError(t, err)
+	assert.Equal(t, remoteHash, editedRemoteHash)
+}
@@ -0,0 +1,231 @@
+package generate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/komailo/kubeit/internal/logger"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+// builtinClusterScopedKinds are the built-in Kubernetes kinds that are not namespaced,
+// as kind and group
+var builtinClusterScopedKinds = []string{
+	"ComponentStatus",
+	"Namespace",
+	"Node",
+	"PersistentVolume",
+	"MutatingAdmissionPolicy.admissionregistration.k8s.io",
+	"MutatingAdmissionPolicyBinding.admissionregistration.k8s.io",
+	"MutatingWebhookConfiguration.admissionregistration.k8s.io",
+	"ValidatingAdmissionPolicy.admissionregistration.k8s.io",
+	"ValidatingAdmissionPolicyBinding.admissionregistration.k8s.io",
+	"ValidatingWebhookConfiguration.admissionregistration.k8s.io",
+	"CustomResourceDefinition.apiextensions.k8s.io",
+	"APIService.apiregistration.k8s.io",
+	"SelfSubjectReview.authentication.k8s.io",
+	"TokenReview.authentication.k8s.io",
+	"SelfSubjectAccessReview.authorization.k8s.io",
+	"SelfSubjectRulesReview.authorization.k8s.io",
+	"SubjectAccessReview.authorization.k8s.io",
+	"CertificateSigningRequest.certificates.k8s.io",
+	"ClusterTrustBundle.certificates.k8s.io",
+	"FlowSchema.flowcontrol.apiserver.k8s.io",
+	"PriorityLevelConfiguration.flowcontrol.apiserver.k8s.io",
+	"IngressClass.networking.k8s.io",
+	"IPAddress.networking.k8s.io",
+	"ServiceCIDR.networking.k8s.io",
+	"RuntimeClass.node.k8s.io",
+	"PodSecurityPolicy.policy",
+	"ClusterRole.rbac.authorization.k8s.io",
+	"ClusterRoleBinding.rbac.authorization.k8s.io",
+	"DeviceClass.resource.k8s.io",
+	"ResourceSlice.resource.k8s.io",
+	"PriorityClass.scheduling.k8s.io",
+	"CSIDriver.storage.k8s.io",
+	"CSINode.storage.k8s.io",
+	"StorageClass.storage.k8s.io",
+	"VolumeAttachment.storage.k8s.io",
+	"VolumeAttributesClass.storage.k8s.io",
+}
+
+// namespaceDefaulter puts the namespaced objects rendered without a namespace in the
+// namespace of the application
+type namespaceDefaulter struct {
+	appName   string
+	namespace string
+	// clusterScoped holds the kinds that are not namespaced
+	clusterScoped map[schema.GroupKind]bool
+}
+
+// newNamespaceDefaulter returns a namespaceDefaulter for the namespace of an
+// application. The kinds that are not namespaced are the built-in ones, the ones of the
+// capabilities profile and the ones of the cluster-scoped CRDs in the manifests.
+func newNamespaceDefaulter(
+	appName, namespace string,
+	capabilities renderCapabilities,
+	manifests ...string,
+) (*namespaceDefaulter, error) {
+	defaulter := &namespaceDefaulter{
+		appName:       appName,
+		namespace:     namespace,
+		clusterScoped: make(map[schema.GroupKind]bool),
+	}
+
+	for _, kind := range builtinClusterScopedKinds {
+		defaulter.clusterScoped[schema.ParseGroupKind(kind)] = true
+	}
+
+	for _, kind := range capabilities.ClusterScopedKinds {
+		separator := strings.LastIndex(kind, "/")
+		if separator < 0 {
+			return nil, fmt.Errorf("invalid cluster-scoped kind %s", kind)
+		}
+
+		groupVersion, err := schema.ParseGroupVersion(kind[:separator])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster-scoped kind %s: %w", kind, err)
+		}
+
+		defaulter.clusterScoped[groupVersion.WithKind(kind[separator+1:]).GroupKind()] = true
+	}
+
+	for _, manifest := range manifests {
+		for _, document := range splitDocuments(manifest) {
+			var crd struct {
+				Kind string `json:"kind"`
+				Spec struct {
+					Group string `json:"group"`
+					Scope string `json:"scope"`
+					Names struct {
+						Kind string `json:"kind"`
+					} `json:"names"`
+				} `json:"spec"`
+			}
+
+			if err := k8syaml.Unmarshal([]byte(document), &crd); err != nil {
+				return nil, fmt.Errorf("failed to decode rendered object: %w\n%s", err, document)
+			}
+
+			if crd.Kind == crdKind && crd.Spec.Scope == "Cluster" {
+				defaulter.clusterScoped[schema.GroupKind{
+					Group: crd.Spec.Group,
+					Kind:  crd.Spec.Names.Kind,
+				}] = true
+			}
+		}
+	}
+
+	return defaulter, nil
+}
+
+// apply sets the namespace of a namespaced object rendered without one, and warns when
+// the chart renders it in another namespace
+func (d *namespaceDefaulter) apply(object map[string]any) (map[string]any, error) {
+	apiVersion, _ := object["apiVersion"].(string)
+	kind, _ := object["kind"].(string)
+
+	groupVersion, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, fmt.Errorf("invalid apiVersion of %s %s: %w", kind, objectName(object), err)
+	}
+
+	if d.clusterScoped[groupVersion.WithKind(kind).GroupKind()] {
+		return object, nil
+	}
+
+	metadata, _ := object["metadata"].(map[string]any)
+	if metadata == nil {
+		metadata = map[string]any{}
+		object["metadata"] = metadata
+	}
+
+	namespace, _ := metadata["namespace"].(string)
+
+	switch namespace {
+	case "":
+		metadata["namespace"] = d.namespace
+	case d.namespace:
+	default:
+		logger.Warnf(
+			"%s: %s %s is rendered in namespace %s instead of %s, the chart hardcodes it",
+			d.appName,
+			kind,
+			objectName(object),
+			namespace,
+			d.namespace,
+		)
+	}
+
+	return object, nil
+}
+
+// namespaceManifest returns the Namespace object of an application
+func namespaceManifest(spec v1.NamespaceSpec, namespace string) (string, error) {
+	metadata := map[string]any{"name": namespace}
+
+	if len(spec.Labels) != 0 {
+		metadata["labels"] = spec.Labels
+	}
+
+	if len(spec.Annotations) != 0 {
+		metadata["annotations"] = spec.Annotations
+	}
+
+	data, err := k8syaml.Marshal(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata":   metadata,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode Namespace %s: %w", namespace, err)
+	}
+
+	return "---\n" + string(data), nil
+}
+
+// recordNamespace records the Namespace object an application writes so duplicates can
+// be detected
+func (r *helmRenderer) recordNamespace(appName, namespace string) {
+	r.namespacesMu.Lock()
+	defer r.namespacesMu.Unlock()
+
+	r.namespaces[namespace] = append(r.namespaces[namespace], appName)
+}
+
+// duplicateNamespaceErrors returns an error for every Namespace object written by more
+// than one application, as the copies would conflict when they are applied
+func (r *helmRenderer) duplicateNamespaceErrors() []error {
+	r.namespacesMu.Lock()
+	defer r.namespacesMu.Unlock()
+
+	namespaces := make([]string, 0, len(r.namespaces))
+	for namespace := range r.namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+
+	sort.Strings(namespaces)
+
+	var errs []error
+
+	for _, namespace := range namespaces {
+		appNames := r.namespaces[namespace]
+		if len(appNames) < 2 {
+			continue
+		}
+
+		sort.Strings(appNames)
+
+		errs = append(errs, fmt.Errorf(
+			"Namespace %s is written by the applications %s, set spec.namespace.create for only one",
+			namespace,
+			strings.Join(appNames, ", "),
+		))
+	}
+
+	return errs
+}
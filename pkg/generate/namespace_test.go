@@ -0,0 +1,233 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/komailo/kubeit/pkg/api/loader"
+)
+
+const unnamespacedManifest = `---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  scope: Cluster
+  names:
+    kind: Widget
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: other
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: web
+---
+apiVersion: example.com/v1
+kind: Gadget
+metadata:
+  name: web
+---
+apiVersion: example.com/v1
+kind: Thing
+metadata:
+  name: web
+`
+
+func TestNamespaceDefaulter(t *testing.T) {
+	defaulter, err := newNamespaceDefaulter(
+		"web",
+		"apps",
+		renderCapabilities{ClusterScopedKinds: []string{"example.com/v1/Gadget"}},
+		unnamespacedManifest,
+	)
+	require.NoError(t, err)
+
+	manifest, err := transformObjects(unnamespacedManifest, defaulter.apply)
+	require.NoError(t, err)
+
+	assert.Equal(t, `---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+  scope: Cluster
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: apps
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: other
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: web
+---
+apiVersion: example.com/v1
+kind: Gadget
+metadata:
+  name: web
+---
+apiVersion: example.com/v1
+kind: Thing
+metadata:
+  name: web
+  namespace: apps
+`, manifest)
+
+	_, err = newNamespaceDefaulter(
+		"web",
+		"apps",
+		renderCapabilities{ClusterScopedKinds: []string{"Gadget"}},
+	)
+	require.ErrorContains(t, err, "invalid cluster-scoped kind Gadget")
+}
+
+func TestHelmRenderer_Manifest_Namespace(t *testing.T) {
+	sourceDir := t.TempDir()
+
+	writeFiles(t, sourceDir, map[string]string{
+		"app.yaml": localChartApplication + `    namespace: apps
+  namespace:
+    create: true
+    labels:
+      pod-security.kubernetes.io/enforce: restricted
+`,
+		"charts/web/Chart.yaml": "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+		"charts/web/templates/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+`,
+	})
+
+	loaderInt := loader.NewLoader()
+	require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+
+	outputDir := t.TempDir()
+	renderer := newTestRenderer(t, t.TempDir(), true)
+
+	require.NoError(t, renderer.manifest(
+		loaderInt.HelmApplications[0],
+		loaderInt,
+		&Options{OutputDir: outputDir, WorkDir: t.TempDir()},
+	))
+
+	data, err := os.ReadFile(filepath.Join(outputDir, "web.yaml"))
+	require.NoError(t, err)
+
+	documents := splitDocuments(string(data))
+	require.Len(t, documents, 3)
+	assert.Contains(t, documents[1], "kind: Namespace\n",
+		"Expected the Namespace to be written before the objects in it")
+	assert.Contains(t, documents[1], "    pod-security.kubernetes.io/enforce: restricted\n")
+	assert.Contains(t, documents[1], "  name: apps\n")
+	assert.Contains(t, documents[2], "kind: ConfigMap\n")
+	assert.Contains(t, documents[2], "  namespace: apps\n")
+
+	// The Namespace is recorded once the application rendered, so a second application
+	// creating it conflicts with the first
+	rendered, err := renderer.render(
+		loaderInt.HelmApplications[0],
+		loaderInt,
+		&Options{WorkDir: t.TempDir()},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "apps", rendered.Namespace)
+}
+
+func TestHelmRenderer_Manifest_Namespace_Invalid(t *testing.T) {
+	sourceDir := t.TempDir()
+
+	writeFiles(t, sourceDir, map[string]string{
+		"app.yaml": localChartApplication + "  namespace:\n    create: true\n",
+	})
+
+	loaderInt := loader.NewLoader()
+	require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+
+	err := newTestRenderer(t, t.TempDir(), true).manifest(
+		loaderInt.HelmApplications[0],
+		loaderInt,
+		&Options{OutputDir: t.TempDir(), WorkDir: t.TempDir()},
+	)
+	require.ErrorContains(t, err, "spec.namespace.create requires spec.chart.namespace")
+}
+
+func TestManifestsFromHelm_DuplicateNamespaces(t *testing.T) {
+	sourceDir := t.TempDir()
+
+	application := localChartApplication + "    namespace: apps\n  namespace:\n    create: true\n"
+
+	writeFiles(t, sourceDir, map[string]string{
+		"app.yaml": application + "---\n" +
+			strings.NewReplacer("name: web", "name: api", "releaseName: web", "releaseName: api").
+				Replace(application),
+		"charts/web/Chart.yaml": "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+		"charts/web/templates/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}
+`,
+	})
+
+	loaderInt := loader.NewLoader()
+	require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+	require.Len(t, loaderInt.HelmApplications, 2)
+
+	// The renderer of the run uses the Helm directories of the test renderer
+	newTestRenderer(t, t.TempDir(), true)
+
+	outputDir := t.TempDir()
+
+	errs := ManifestsFromHelm(loaderInt, &Options{
+		OutputDir: outputDir,
+		WorkDir:   t.TempDir(),
+		CacheDir:  t.TempDir(),
+		Offline:   true,
+	})
+	require.Len(t, errs, 1)
+	assert.EqualError(
+		t,
+		errs[0],
+		"Namespace apps is written by the applications api, web, set spec.namespace.create for only one",
+	)
+	assert.Empty(t, readOutputDir(t, outputDir), "Expected nothing to be written")
+}
@@ -0,0 +1,122 @@
+package generate
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+// installOrder ranks the kinds in the order they are written in. Namespaces and CRDs
+// come first as other objects can depend on them, the other kinds follow the install
+// order of Helm and unknown kinds come last.
+var installOrder = func() map[string]int {
+	kinds := []string{"Namespace", crdKind}
+
+	for _, kind := range releaseutil.InstallOrder {
+		if !slices.Contains(kinds, kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+
+	order := make(map[string]int, len(kinds))
+	for i, kind := range kinds {
+		order[kind] = i
+	}
+
+	return order
+}()
+
+// sortedObject is a document of a manifest with the fields it is sorted by
+type sortedObject struct {
+	document   string
+	hook       bool
+	rank       int
+	kind       string
+	name       string
+	namespace  string
+	apiVersion string
+}
+
+// sortObjects sorts the objects of a multi-document manifest in install order and then
+// by name, so the output does not depend on the template file names of a chart. Hooks
+// are kept after the other objects and the comments before each object are kept.
+func sortObjects(manifest string) (string, error) {
+	var objects []sortedObject
+
+	for _, document := range splitDocuments(manifest) {
+		var object struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+			Metadata   struct {
+				Name        string            `json:"name"`
+				Namespace   string            `json:"namespace"`
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+		}
+
+		if err := k8syaml.Unmarshal([]byte(document), &object); err != nil {
+			return "", fmt.Errorf("failed to decode rendered object: %w\n%s", err, document)
+		}
+
+		if object.Kind == "" && object.APIVersion == "" {
+			continue
+		}
+
+		rank, ok := installOrder[object.Kind]
+		if !ok {
+			rank = len(installOrder)
+		}
+
+		_, helmHook := object.Metadata.Annotations[release.HookAnnotation]
+		_, argoCDHook := object.Metadata.Annotations[argoCDHookAnnotation]
+
+		objects = append(objects, sortedObject{
+			document:   document,
+			hook:       helmHook || argoCDHook,
+			rank:       rank,
+			kind:       object.Kind,
+			name:       object.Metadata.Name,
+			namespace:  object.Metadata.Namespace,
+			apiVersion: object.APIVersion,
+		})
+	}
+
+	slices.SortStableFunc(objects, func(a, b sortedObject) int {
+		switch {
+		case a.hook != b.hook:
+			if a.hook {
+				return 1
+			}
+
+			return -1
+		case a.rank != b.rank:
+			return a.rank - b.rank
+		}
+
+		for _, fields := range [][2]string{
+			{a.kind, b.kind},
+			{a.name, b.name},
+			{a.namespace, b.namespace},
+			{a.apiVersion, b.apiVersion},
+		} {
+			if compared := strings.Compare(fields[0], fields[1]); compared != 0 {
+				return compared
+			}
+		}
+
+		return 0
+	})
+
+	var sorted strings.Builder
+
+	for _, object := range objects {
+		sorted.WriteString("---\n")
+		sorted.WriteString(object.document)
+	}
+
+	return sorted.String(), nil
+}
@@ -0,0 +1,87 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortObjects(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     string
+	}{
+		{
+			name: "install order then name",
+			manifest: "---\n# Source: web/templates/deployment.yaml\n" +
+				"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n" +
+				"---\n# Source: web/templates/widget.yaml\n" +
+				"apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: web\n" +
+				"---\n# Source: web/templates/service.yaml\n" +
+				"apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n" +
+				"---\n# Source: web/templates/configmap.yaml\n" +
+				"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web-b\n" +
+				"---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web-a\n" +
+				"---\napiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\n" +
+				"metadata:\n  name: widgets.example.com\n" +
+				"---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: apps\n",
+			want: "---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: apps\n" +
+				"---\napiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\n" +
+				"metadata:\n  name: widgets.example.com\n" +
+				"---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web-a\n" +
+				"---\n# Source: web/templates/configmap.yaml\n" +
+				"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web-b\n" +
+				"---\n# Source: web/templates/service.yaml\n" +
+				"apiVersion: v1\nkind: Service\nmetadata:\n  name: web\n" +
+				"---\n# Source: web/templates/deployment.yaml\n" +
+				"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n" +
+				"---\n# Source: web/templates/widget.yaml\n" +
+				"apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: web\n",
+		},
+		{
+			name: "hooks last",
+			manifest: "---\napiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\n" +
+				"  annotations:\n    helm.sh/hook: pre-install\n" +
+				"---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n" +
+				"---\napiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: sync\n" +
+				"  annotations:\n    argocd.argoproj.io/hook: PreSync\n",
+			want: "---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n" +
+				"---\napiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: sync\n" +
+				"  annotations:\n    argocd.argoproj.io/hook: PreSync\n" +
+				"---\napiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\n" +
+				"  annotations:\n    helm.sh/hook: pre-install\n",
+		},
+		{
+			name: "same kind and name by namespace",
+			manifest: "---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: tls\n  namespace: b\n" +
+				"---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: tls\n  namespace: a\n",
+			want: "---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: tls\n  namespace: a\n" +
+				"---\napiVersion: v1\nkind: Secret\nmetadata:\n  name: tls\n  namespace: b\n",
+		},
+		{
+			name:     "empty",
+			manifest: "---\n# Source: web/templates/empty.yaml\n",
+			want:     "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted, err := sortObjects(tt.manifest)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, sorted)
+
+			resorted, err := sortObjects(sorted)
+			require.NoError(t, err)
+			assert.Equal(t, sorted, resorted, "Expected sorting to be idempotent")
+		})
+	}
+}
+
+func TestSortObjects_Error(t *testing.T) {
+	_, err := sortObjects("---\nkind: [ConfigMap\n")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to decode rendered object")
+}
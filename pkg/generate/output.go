@@ -0,0 +1,613 @@
+package generate
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/komailo/kubeit/internal/logger"
+)
+
+// Output layouts of the rendered manifests
+const (
+	// OutputLayoutSingle writes the manifests of every application to manifests.yaml
+	OutputLayoutSingle = "single"
+	// OutputLayoutPerApplication writes the manifests of every application to
+	// <app>.yaml
+	OutputLayoutPerApplication = "per-application"
+	// OutputLayoutPerObject writes every object to <app>/<kind>/<name>.yaml
+	OutputLayoutPerObject = "per-object"
+	// OutputLayoutJSON writes every object to manifests.json as a List
+	OutputLayoutJSON = "json"
+	// OutputLayoutStdout writes every object to the standard output as a YAML stream
+	OutputLayoutStdout = "stdout"
+	// OutputLayoutTar writes the per-application layout to manifests.tar
+	OutputLayoutTar = "tar"
+)
+
+// OutputLayouts are the supported output layouts
+var OutputLayouts = []string{
+	OutputLayoutSingle,
+	OutputLayoutPerApplication,
+	OutputLayoutPerObject,
+	OutputLayoutJSON,
+	OutputLayoutStdout,
+	OutputLayoutTar,
+}
+
+const (
+	// kustomizationFileName is the name of the kustomization written with
+	// --kustomization
+	kustomizationFileName = "kustomization.yaml"
+	// checksumsFileName is the name of the file with the checksums of the written files
+	checksumsFileName = "manifests.sha256"
+)
+
+// renderedApplication holds the rendered manifests of an application until they are
+// written
+type renderedApplication struct {
+	Name     string
+	Manifest string
+	// Hooks and CRDs are only set when they are written separately
+	Hooks string
+	CRDs  string
+	Notes string
+	// CRDNames and Namespace are the CRDs and the Namespace object the application
+	// writes, which must not be written by another application
+	CRDNames  []string
+	Namespace string
+}
+
+// outputFile is a file of an output layout, with a path relative to the output
+// directory
+type outputFile struct {
+	Path string
+	Data []byte
+}
+
+// validateOutputOptions checks the output layout and that a kustomization can be
+// written for it
+func validateOutputOptions(generateSetOptions *Options) error {
+	layout := outputLayout(generateSetOptions)
+
+	if !slices.Contains(OutputLayouts, layout) {
+		return fmt.Errorf(
+			"unknown output layout %s, must be one of %s",
+			layout,
+			strings.Join(OutputLayouts, ", "),
+		)
+	}
+
+	if generateSetOptions.Kustomization &&
+		(layout == OutputLayoutJSON || layout == OutputLayoutStdout) {
+		return fmt.Errorf("a kustomization cannot be written for the %s output layout", layout)
+	}
+
+	return nil
+}
+
+// outputLayout returns the output layout of the options, per-application when empty
+func outputLayout(generateSetOptions *Options) string {
+	if generateSetOptions.OutputLayout == "" {
+		return OutputLayoutPerApplication
+	}
+
+	return generateSetOptions.OutputLayout
+}
+
+// writeOutput writes the manifests of the rendered applications, in their order, in
+// the output layout of the options
+func writeOutput(generateSetOptions *Options, applications []*renderedApplication) error {
+	if err := validateOutputOptions(generateSetOptions); err != nil {
+		return err
+	}
+
+	layout := outputLayout(generateSetOptions)
+
+	var (
+		files []outputFile
+		err   error
+	)
+
+	switch layout {
+	case OutputLayoutStdout:
+		stdout := generateSetOptions.Stdout
+		if stdout == nil {
+			stdout = os.Stdout
+		}
+
+		manifest := streamManifest(applications)
+
+		if _, err := io.WriteString(stdout, manifest); err != nil {
+			return fmt.Errorf("failed to write manifests to the standard output: %w", err)
+		}
+
+		return printDigest(generateSetOptions, "Wrote manifests", digest([]byte(manifest)))
+	case OutputLayoutJSON:
+		files, err = jsonListFiles(applications)
+	case OutputLayoutSingle:
+		files, err = singleFiles(applications)
+	case OutputLayoutPerObject:
+		files, err = perObjectFiles(applications)
+	default:
+		files, err = perApplicationFiles(applications)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Paths are built from rendered names, they must stay in the output directory
+	for _, file := range files {
+		if !filepath.IsLocal(filepath.FromSlash(file.Path)) {
+			return fmt.Errorf("%s is outside of the output directory", file.Path)
+		}
+	}
+
+	if generateSetOptions.Kustomization {
+		kustomization, err := kustomizationFile(files)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, kustomization)
+	}
+
+	if layout == OutputLayoutTar {
+		data, err := tarFiles(files)
+		if err != nil {
+			return err
+		}
+
+		files = []outputFile{{Path: "manifests.tar", Data: data}}
+	}
+
+	checksums := checksumsFile(files)
+	files = append(files, checksums)
+
+	if err := prepareOutputDir(generateSetOptions, files); err != nil {
+		return err
+	}
+
+	if err := writeOutputFiles(generateSetOptions.OutputDir, files); err != nil {
+		return err
+	}
+
+	return printDigest(
+		generateSetOptions,
+		"Wrote manifests to "+generateSetOptions.OutputDir,
+		digest(checksums.Data),
+	)
+}
+
+// printDigest prints the digest of the written manifests to the standard error, whatever
+// the verbosity, so CI can tell whether the output changed. The standard output is left
+// to the stdout output layout.
+func printDigest(generateSetOptions *Options, message, outputDigest string) error {
+	stderr := generateSetOptions.Stderr
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	if _, err := fmt.Fprintf(stderr, "%s with digest %s\n", message, outputDigest); err != nil {
+		return fmt.Errorf("failed to print the digest of the manifests: %w", err)
+	}
+
+	return nil
+}
+
+// checksumsFile returns the SHA-256 checksums of the files of a layout in the format of
+// sha256sum, sorted by path, so the output can be verified with sha256sum --check
+func checksumsFile(files []outputFile) outputFile {
+	sorted := slices.Clone(files)
+	slices.SortFunc(sorted, func(a, b outputFile) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	var checksums strings.Builder
+
+	for _, file := range sorted {
+		hash := sha256.Sum256(file.Data)
+		fmt.Fprintf(&checksums, "%s  %s\n", hex.EncodeToString(hash[:]), file.Path)
+	}
+
+	return outputFile{Path: checksumsFileName, Data: []byte(checksums.String())}
+}
+
+// digest returns the SHA-256 digest of the output, the digest of the checksums file for
+// layouts written to files, so it only changes when a written file changes
+func digest(data []byte) string {
+	hash := sha256.Sum256(data)
+
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+// perApplicationFiles returns <app>.yaml for every application, with the separate hooks
+// in <app>.hooks.yaml, the separate CRDs in crds/<app>.yaml and the notes in
+// <app>.NOTES.txt
+func perApplicationFiles(applications []*renderedApplication) ([]outputFile, error) {
+	var files []outputFile
+
+	for _, application := range applications {
+		if err := validateFileName("HelmApplication", application.Name); err != nil {
+			return nil, err
+		}
+
+		files = append(files, outputFile{
+			Path: application.Name + ".yaml",
+			Data: []byte(application.Manifest),
+		})
+
+		if application.Hooks != "" {
+			files = append(files, outputFile{
+				Path: application.Name + ".hooks.yaml",
+				Data: []byte(application.Hooks),
+			})
+		}
+
+		if application.CRDs != "" {
+			files = append(files, outputFile{
+				Path: path.Join("crds", application.Name+".yaml"),
+				Data: []byte(application.CRDs),
+			})
+		}
+
+		files = append(files, notesFiles(application, application.Name+".NOTES.txt")...)
+	}
+
+	return files, nil
+}
+
+// singleFiles returns manifests.yaml with the manifests of every application, with the
+// separate hooks in hooks.yaml, the separate CRDs in crds.yaml and the notes in
+// <app>.NOTES.txt
+func singleFiles(applications []*renderedApplication) ([]outputFile, error) {
+	var manifests, hooks, crds strings.Builder
+
+	var files []outputFile
+
+	for _, application := range applications {
+		if err := validateFileName("HelmApplication", application.Name); err != nil {
+			return nil, err
+		}
+
+		manifests.WriteString(application.Manifest)
+		hooks.WriteString(application.Hooks)
+		crds.WriteString(application.CRDs)
+
+		files = append(files, notesFiles(application, application.Name+".NOTES.txt")...)
+	}
+
+	files = append(files, outputFile{Path: "manifests.yaml", Data: []byte(manifests.String())})
+
+	if hooks.Len() != 0 {
+		files = append(files, outputFile{Path: "hooks.yaml", Data: []byte(hooks.String())})
+	}
+
+	if crds.Len() != 0 {
+		files = append(files, outputFile{Path: "crds.yaml", Data: []byte(crds.String())})
+	}
+
+	return files, nil
+}
+
+// perObjectFiles returns <app>/<kind>/<name>.yaml for every object, with the separate
+// hooks in <app>/hooks/<kind>/<name>.yaml, the separate CRDs in crds/<name>.yaml and
+// the notes in <app>/NOTES.txt
+func perObjectFiles(applications []*renderedApplication) ([]outputFile, error) {
+	var files []outputFile
+
+	paths := make(map[string]bool)
+
+	for _, application := range applications {
+		if err := validateFileName("HelmApplication", application.Name); err != nil {
+			return nil, err
+		}
+
+		for _, part := range []struct {
+			dir      string
+			manifest string
+			withKind bool
+		}{
+			{dir: "crds", manifest: application.CRDs},
+			{dir: application.Name, manifest: application.Manifest, withKind: true},
+			{
+				dir:      path.Join(application.Name, "hooks"),
+				manifest: application.Hooks,
+				withKind: true,
+			},
+		} {
+			err := forEachObject(part.manifest, func(object map[string]any) error {
+				data, err := k8syaml.Marshal(object)
+				if err != nil {
+					return fmt.Errorf("failed to encode rendered object: %w", err)
+				}
+
+				kind, _ := object["kind"].(string)
+
+				name := objectName(object)
+				if name == "" {
+					return fmt.Errorf(
+						"%s of %s has no metadata.name, objects named with generateName "+
+							"cannot be written with the per-object output layout",
+						kind,
+						application.Name,
+					)
+				}
+
+				if err := validateFileName(kind+" of "+application.Name, name); err != nil {
+					return err
+				}
+
+				filePath := path.Join(part.dir, name+".yaml")
+				if part.withKind {
+					if errs := validation.IsDNS1123Label(strings.ToLower(kind)); len(errs) != 0 {
+						return fmt.Errorf(
+							"kind %q of %s cannot be used as a directory name: %s",
+							kind,
+							application.Name,
+							strings.Join(errs, ", "),
+						)
+					}
+
+					filePath = path.Join(part.dir, strings.ToLower(kind), name+".yaml")
+				}
+
+				if paths[filePath] {
+					return fmt.Errorf(
+						"%s %s of %s is written to %s by another object, use another output layout",
+						kind,
+						objectName(object),
+						application.Name,
+						filePath,
+					)
+				}
+
+				paths[filePath] = true
+				files = append(files, outputFile{
+					Path: filePath,
+					Data: append([]byte("---\n"), data...),
+				})
+
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		files = append(files, notesFiles(application, path.Join(application.Name, "NOTES.txt"))...)
+	}
+
+	return files, nil
+}
+
+// forEachObject calls fn with every object of a multi-document manifest
+func forEachObject(manifest string, fn func(object map[string]any) error) error {
+	_, err := transformObjects(manifest, func(object map[string]any) (map[string]any, error) {
+		return object, fn(object)
+	})
+
+	return err
+}
+
+// notesFiles returns the notes of an application, when it has any, as filePath
+func notesFiles(application *renderedApplication, filePath string) []outputFile {
+	if application.Notes == "" {
+		return nil
+	}
+
+	return []outputFile{{Path: filePath, Data: []byte(application.Notes + "\n")}}
+}
+
+// streamManifest returns the objects of every application as a YAML stream, the
+// separate CRDs of every application first and the separate hooks last
+func streamManifest(applications []*renderedApplication) string {
+	var crds, manifests, hooks strings.Builder
+
+	for _, application := range applications {
+		crds.WriteString(application.CRDs)
+		manifests.WriteString(application.Manifest)
+		hooks.WriteString(application.Hooks)
+
+		if application.Notes != "" {
+			logger.Infof("Notes of %s:\n%s", application.Name, application.Notes)
+		}
+	}
+
+	return crds.String() + manifests.String() + hooks.String()
+}
+
+// jsonListFiles returns manifests.json with the objects of every application, in the
+// order of streamManifest, as a List
+func jsonListFiles(applications []*renderedApplication) ([]outputFile, error) {
+	items := []any{}
+
+	err := forEachObject(streamManifest(applications), func(object map[string]any) error {
+		items = append(items, object)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(map[string]any{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode manifests: %w", err)
+	}
+
+	return []outputFile{{Path: "manifests.json", Data: append(data, '\n')}}, nil
+}
+
+// kustomizationFile returns a kustomization with the YAML files of a layout as its
+// resources, the CRDs first so the objects using them are applied after them
+func kustomizationFile(files []outputFile) (outputFile, error) {
+	var crds, resources []string
+
+	for _, file := range files {
+		if path.Ext(file.Path) != ".yaml" {
+			continue
+		}
+
+		if file.Path == "crds.yaml" || strings.HasPrefix(file.Path, "crds/") {
+			crds = append(crds, file.Path)
+			continue
+		}
+
+		resources = append(resources, file.Path)
+	}
+
+	data, err := k8syaml.Marshal(map[string]any{
+		"apiVersion": "kustomize.config.k8s.io/v1beta1",
+		"kind":       "Kustomization",
+		"resources":  append(crds, resources...),
+	})
+	if err != nil {
+		return outputFile{}, fmt.Errorf("failed to encode kustomization: %w", err)
+	}
+
+	return outputFile{Path: kustomizationFileName, Data: data}, nil
+}
+
+// tarFiles returns the files of a layout as a tar archive
+func tarFiles(files []outputFile) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	writer := tar.NewWriter(&buffer)
+
+	for _, file := range files {
+		header := &tar.Header{
+			Name:     file.Path,
+			Mode:     0o644,
+			Size:     int64(len(file.Data)),
+			Typeflag: tar.TypeReg,
+		}
+
+		if err := writer.WriteHeader(header); err != nil {
+			return nil, fmt.Errorf("failed to write %s to the archive: %w", file.Path, err)
+		}
+
+		if _, err := writer.Write(file.Data); err != nil {
+			return nil, fmt.Errorf("failed to write %s to the archive: %w", file.Path, err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write the archive: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// writeOutputFiles writes the files of a layout to the output directory
+// prepareOutputDir removes the contents of the output directory with --clean, or
+// returns an error when it has files that would not be overwritten. Such files are not
+// in the checksums or the kustomization, but would still be applied with the directory.
+func prepareOutputDir(generateSetOptions *Options, files []outputFile) error {
+	outputDir := generateSetOptions.OutputDir
+
+	entries, err := os.ReadDir(outputDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to read output directory: %w", err)
+	}
+
+	if generateSetOptions.Clean {
+		for _, entry := range entries {
+			if err := os.RemoveAll(filepath.Join(outputDir, entry.Name())); err != nil {
+				return fmt.Errorf("failed to clean output directory: %w", err)
+			}
+		}
+
+		return nil
+	}
+
+	written := make(map[string]bool, len(files))
+	for _, file := range files {
+		written[file.Path] = true
+	}
+
+	var stale []string
+
+	err = filepath.WalkDir(outputDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		relPath, err := filepath.Rel(outputDir, filePath)
+		if err != nil {
+			return err
+		}
+
+		if !written[filepath.ToSlash(relPath)] {
+			stale = append(stale, filepath.ToSlash(relPath))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read output directory: %w", err)
+	}
+
+	if len(stale) != 0 {
+		return fmt.Errorf(
+			"output directory %s has files that would not be overwritten, remove them or use --clean: %s",
+			outputDir,
+			strings.Join(stale, ", "),
+		)
+	}
+
+	return nil
+}
+
+func writeOutputFiles(outputDir string, files []outputFile) error {
+	for _, file := range files {
+		filePath := filepath.Join(outputDir, filepath.FromSlash(file.Path))
+
+		if err := os.MkdirAll(filepath.Dir(filePath), 0o755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+
+		if err := os.WriteFile(filePath, file.Data, 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// validateFileName returns an error when the name of an application or object is not a
+// DNS subdomain, as most Kubernetes names are, as it is used as a file name
+func validateFileName(owner, name string) error {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) != 0 {
+		return fmt.Errorf(
+			"name %q of %s cannot be used as a file name, use another output layout: %s",
+			name,
+			owner,
+			strings.Join(errs, ", "),
+		)
+	}
+
+	return nil
+}
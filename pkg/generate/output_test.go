@@ -0,0 +1,491 @@
+package generate
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/komailo/kubeit/pkg/api/loader"
+)
+
+// testRenderedApplications are two applications, the second with separate hooks and
+// CRDs and notes
+func testRenderedApplications() []*renderedApplication {
+	return []*renderedApplication{
+		{
+			Name:     "web",
+			Manifest: "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n",
+		},
+		{
+			Name: "api",
+			Manifest: "---\napiVersion: v1\nkind: Service\nmetadata:\n  name: api\n" +
+				"---\napiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: api\n",
+			Hooks: "---\napiVersion: batch/v1\nkind: Job\nmetadata:\n  name: migrate\n",
+			CRDs: "---\napiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\n" +
+				"metadata:\n  name: widgets.example.com\n",
+			Notes: "Thanks for installing api",
+		},
+	}
+}
+
+// readOutputDir returns the files of an output directory with their content
+func readOutputDir(t *testing.T, outputDir string) map[string]string {
+	t.Helper()
+
+	files := make(map[string]string)
+
+	err := filepath.WalkDir(outputDir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		assert.Equal(t, os.FileMode(0o644), info.Mode().Perm(), "Unexpected mode of %s", path)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(outputDir, path)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(relativePath)] = string(data)
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	return files
+}
+
+func TestWriteOutput(t *testing.T) {
+	applications := testRenderedApplications()
+
+	tests := []struct {
+		name          string
+		layout        string
+		kustomization bool
+		wantFiles     map[string]string
+	}{
+		{
+			name: "per-application by default",
+			wantFiles: map[string]string{
+				"web.yaml":       applications[0].Manifest,
+				"api.yaml":       applications[1].Manifest,
+				"api.hooks.yaml": applications[1].Hooks,
+				"crds/api.yaml":  applications[1].CRDs,
+				"api.NOTES.txt":  "Thanks for installing api\n",
+			},
+		},
+		{
+			name:          "single",
+			layout:        OutputLayoutSingle,
+			kustomization: true,
+			wantFiles: map[string]string{
+				"manifests.yaml": applications[0].Manifest + applications[1].Manifest,
+				"hooks.yaml":     applications[1].Hooks,
+				"crds.yaml":      applications[1].CRDs,
+				"api.NOTES.txt":  "Thanks for installing api\n",
+				"kustomization.yaml": `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- crds.yaml
+- manifests.yaml
+- hooks.yaml
+`,
+			},
+		},
+		{
+			name:   "per-object",
+			layout: OutputLayoutPerObject,
+			wantFiles: map[string]string{
+				"web/configmap/web.yaml": applications[0].Manifest,
+				"api/service/api.yaml":   "---\napiVersion: v1\nkind: Service\nmetadata:\n  name: api\n",
+				"api/deployment/api.yaml": "---\napiVersion: apps/v1\nkind: Deployment\n" +
+					"metadata:\n  name: api\n",
+				"api/hooks/job/migrate.yaml":    applications[1].Hooks,
+				"crds/widgets.example.com.yaml": applications[1].CRDs,
+				"api/NOTES.txt":                 "Thanks for installing api\n",
+			},
+		},
+		{
+			name:   "json",
+			layout: OutputLayoutJSON,
+			wantFiles: map[string]string{
+				"manifests.json": `{
+  "apiVersion": "v1",
+  "items": [
+    {
+      "apiVersion": "apiextensions.k8s.io/v1",
+      "kind": "CustomResourceDefinition",
+      "metadata": {
+        "name": "widgets.example.com"
+      }
+    },
+    {
+      "apiVersion": "v1",
+      "kind": "ConfigMap",
+      "metadata": {
+        "name": "web"
+      }
+    },
+    {
+      "apiVersion": "v1",
+      "kind": "Service",
+      "metadata": {
+        "name": "api"
+      }
+    },
+    {
+      "apiVersion": "apps/v1",
+      "kind": "Deployment",
+      "metadata": {
+        "name": "api"
+      }
+    },
+    {
+      "apiVersion": "batch/v1",
+      "kind": "Job",
+      "metadata": {
+        "name": "migrate"
+      }
+    }
+  ],
+  "kind": "List"
+}
+`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+
+			outputDir := t.TempDir()
+
+			require.NoError(t, writeOutput(&Options{
+				OutputDir:     outputDir,
+				OutputLayout:  tt.layout,
+				Kustomization: tt.kustomization,
+				Stderr:        &stderr,
+			}, applications))
+
+			files := readOutputDir(t, outputDir)
+
+			checksums, ok := files[checksumsFileName]
+			require.True(t, ok, "Expected %s in the output", checksumsFileName)
+			delete(files, checksumsFileName)
+
+			assert.Equal(t, tt.wantFiles, files)
+
+			var wantChecksums strings.Builder
+
+			for _, path := range slices.Sorted(maps.Keys(tt.wantFiles)) {
+				hash := sha256.Sum256([]byte(tt.wantFiles[path]))
+				fmt.Fprintf(&wantChecksums, "%x  %s\n", hash, path)
+			}
+
+			assert.Equal(t, wantChecksums.String(), checksums)
+			assert.Equal(
+				t,
+				fmt.Sprintf(
+					"Wrote manifests to %s with digest sha256:%x\n",
+					outputDir,
+					sha256.Sum256([]byte(checksums)),
+				),
+				stderr.String(),
+				"Expected the digest of the checksums to be printed",
+			)
+		})
+	}
+}
+
+func TestChecksumsFile(t *testing.T) {
+	checksums := checksumsFile([]outputFile{
+		{Path: "web.yaml", Data: []byte("web\n")},
+		{Path: "api.yaml", Data: []byte("")},
+	})
+
+	assert.Equal(t, checksumsFileName, checksums.Path)
+	assert.Equal(
+		t,
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  api.yaml\n"+
+			"4ded89b3f9f03689b7032b92a091e742e1205e2a54277e52b32498d9fcdf3642  web.yaml\n",
+		string(checksums.Data),
+		"Expected the checksums sorted by path",
+	)
+}
+
+func TestWriteOutput_Stdout(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	outputDir := t.TempDir()
+	applications := testRenderedApplications()
+
+	require.NoError(t, writeOutput(&Options{
+		OutputDir:    outputDir,
+		OutputLayout: OutputLayoutStdout,
+		Stdout:       &stdout,
+		Stderr:       &stderr,
+	}, applications))
+
+	assert.Equal(
+		t,
+		applications[1].CRDs+applications[0].Manifest+applications[1].Manifest+applications[1].Hooks,
+		stdout.String(),
+		"Expected the separate CRDs first and the separate hooks last",
+	)
+	assert.Empty(t, readOutputDir(t, outputDir))
+	assert.Equal(
+		t,
+		fmt.Sprintf("Wrote manifests with digest sha256:%x\n", sha256.Sum256(stdout.Bytes())),
+		stderr.String(),
+		"Expected the digest of the stream to be printed",
+	)
+}
+
+func TestWriteOutput_Tar(t *testing.T) {
+	outputDir := t.TempDir()
+
+	require.NoError(t, writeOutput(&Options{
+		OutputDir:     outputDir,
+		OutputLayout:  OutputLayoutTar,
+		Kustomization: true,
+	}, testRenderedApplications()))
+
+	archive, err := os.Open(filepath.Join(outputDir, "manifests.tar"))
+	require.NoError(t, err)
+	defer archive.Close()
+
+	var names []string
+
+	reader := tar.NewReader(archive)
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(0o644), header.Mode)
+
+		names = append(names, header.Name)
+	}
+
+	assert.Equal(t, []string{
+		"web.yaml",
+		"api.yaml",
+		"api.hooks.yaml",
+		"crds/api.yaml",
+		"api.NOTES.txt",
+		"kustomization.yaml",
+	}, names)
+
+	_, err = os.Stat(filepath.Join(outputDir, checksumsFileName))
+	assert.NoError(t, err, "Expected the checksums next to the archive")
+}
+
+func TestWriteOutput_Errors(t *testing.T) {
+	duplicate := []*renderedApplication{{
+		Name: "web",
+		Manifest: "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n  namespace: a\n" +
+			"---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n  namespace: b\n",
+	}}
+
+	tests := []struct {
+		name         string
+		options      Options
+		applications []*renderedApplication
+		wantErr      string
+	}{
+		{
+			name:    "unknown layout",
+			options: Options{OutputLayout: "zip"},
+			wantErr: "unknown output layout zip, must be one of single, per-application, per-object, json, stdout, tar",
+		},
+		{
+			name:    "kustomization of a stream",
+			options: Options{OutputLayout: OutputLayoutStdout, Kustomization: true},
+			wantErr: "a kustomization cannot be written for the stdout output layout",
+		},
+		{
+			name:         "per-object file written twice",
+			options:      Options{OutputLayout: OutputLayoutPerObject},
+			applications: duplicate,
+			wantErr:      "ConfigMap web of web is written to web/configmap/web.yaml by another object",
+		},
+		{
+			name:    "per-object name with a path",
+			options: Options{OutputLayout: OutputLayoutPerObject},
+			applications: []*renderedApplication{{
+				Name:     "web",
+				Manifest: "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: ../../etc\n",
+			}},
+			wantErr: `name "../../etc" of ConfigMap of web cannot be used as a file name`,
+		},
+		{
+			name:    "per-object application name with a path",
+			options: Options{OutputLayout: OutputLayoutPerObject},
+			applications: []*renderedApplication{{
+				Name:     "../web",
+				Manifest: "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n",
+			}},
+			wantErr: `name "../web" of HelmApplication cannot be used as a file name`,
+		},
+		{
+			name:    "per-object generated name",
+			options: Options{OutputLayout: OutputLayoutPerObject},
+			applications: []*renderedApplication{{
+				Name:     "web",
+				Manifest: "---\napiVersion: batch/v1\nkind: Job\nmetadata:\n  generateName: migrate-\n",
+			}},
+			wantErr: "Job of web has no metadata.name",
+		},
+		{
+			name:    "application name outside of the output directory",
+			options: Options{OutputLayout: OutputLayoutPerApplication},
+			applications: []*renderedApplication{{
+				Name:     "../web",
+				Manifest: "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n",
+			}},
+			wantErr: `name "../web" of HelmApplication cannot be used as a file name`,
+		},
+		{
+			name:    "per-application name with a directory",
+			options: Options{OutputLayout: OutputLayoutTar},
+			applications: []*renderedApplication{{
+				Name:     "team/web",
+				Manifest: "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n",
+			}},
+			wantErr: `name "team/web" of HelmApplication cannot be used as a file name`,
+		},
+		{
+			name:    "single application name with a directory",
+			options: Options{OutputLayout: OutputLayoutSingle},
+			applications: []*renderedApplication{{
+				Name:     "team/web",
+				Manifest: "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n",
+				Notes:    "Web is installed",
+			}},
+			wantErr: `name "team/web" of HelmApplication cannot be used as a file name`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.OutputDir = t.TempDir()
+
+			require.ErrorContains(t, writeOutput(&tt.options, tt.applications), tt.wantErr)
+		})
+	}
+}
+
+func TestManifestsFromHelm_OutputLayout(t *testing.T) {
+	sourceDir := t.TempDir()
+
+	files := map[string]string{}
+
+	for _, name := range []string{"zeta", "alpha", "mid"} {
+		files[name+".yaml"] = `apiVersion: kubeit.komailo.github.io/v1alpha1
+kind: HelmApplication
+metadata:
+  name: ` + name + `
+spec:
+  chart:
+    path: charts/web
+    releaseName: ` + name + `
+`
+	}
+
+	files["charts/web/Chart.yaml"] = "apiVersion: v2\nname: web\nversion: 0.1.0\n"
+	files["charts/web/templates/configmap.yaml"] = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}
+`
+
+	writeFiles(t, sourceDir, files)
+
+	loaderInt := loader.NewLoader()
+	require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+
+	// The renderer of the run uses the Helm directories of the test renderer
+	newTestRenderer(t, t.TempDir(), true)
+
+	outputDir := t.TempDir()
+
+	require.Empty(t, ManifestsFromHelm(loaderInt, &Options{
+		OutputDir:    outputDir,
+		OutputLayout: OutputLayoutSingle,
+		WorkDir:      t.TempDir(),
+		CacheDir:     t.TempDir(),
+		Offline:      true,
+		Parallelism:  3,
+	}))
+
+	manifest, err := os.ReadFile(filepath.Join(outputDir, "manifests.yaml"))
+	require.NoError(t, err)
+
+	var names []string
+
+	require.NoError(t, forEachObject(string(manifest), func(object map[string]any) error {
+		names = append(names, objectName(object))
+		return nil
+	}))
+
+	var wantNames []string
+	for _, helmApplication := range loaderInt.HelmApplications {
+		wantNames = append(wantNames, helmApplication.Metadata.Name)
+	}
+
+	assert.Equal(t, wantNames, names, "Expected the applications in their order")
+}
+
+func TestWriteOutput_StaleFiles(t *testing.T) {
+	applications := testRenderedApplications()
+
+	options := &Options{
+		OutputDir:    t.TempDir(),
+		OutputLayout: OutputLayoutPerObject,
+		Stderr:       io.Discard,
+	}
+
+	require.NoError(t, writeOutput(options, applications))
+	require.NoError(t, writeOutput(options, applications), "Expected a rerun to overwrite every file")
+
+	// The Deployment of api is removed, its file would still be applied with the directory
+	applications[1].Manifest = "---\napiVersion: v1\nkind: Service\nmetadata:\n  name: api\n"
+
+	err := writeOutput(options, applications)
+	require.ErrorContains(t, err, "has files that would not be overwritten, remove them or use --clean: "+
+		"api/deployment/api.yaml")
+	assert.Contains(t, readOutputDir(t, options.OutputDir), "api/deployment/api.yaml",
+		"Expected nothing to be written")
+
+	options.Clean = true
+	require.NoError(t, writeOutput(options, applications))
+
+	files := readOutputDir(t, options.OutputDir)
+	assert.NotContains(t, files, "api/deployment/api.yaml")
+	assert.Contains(t, files, "api/service/api.yaml")
+}
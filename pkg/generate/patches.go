@@ -0,0 +1,204 @@
+package generate
+
+import (
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/komailo/kubeit/internal/logger"
+	"github.com/komailo/kubeit/pkg/api"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+// objectPatch is a patch of a HelmApplication or NamedValues ready to be applied
+type objectPatch struct {
+	// origin describes where the patch is declared for errors and logs
+	origin     string
+	patch      v1.Patch
+	selector   labels.Selector
+	operations jsonpatch.Patch
+	matched    int
+}
+
+// objectPatcher applies the patches of an application to its rendered objects
+type objectPatcher struct {
+	patches []*objectPatch
+	// namespace is the namespace of objects rendered without one
+	namespace string
+}
+
+// newObjectPatcher collects the patches of a HelmApplication followed by the patches of
+// the selected NamedValues, in the order they are selected
+func newObjectPatcher(
+	helmApplication *v1.HelmApplication,
+	loaderInt *loader.Loader,
+	generateSetOptions *Options,
+) (*objectPatcher, error) {
+	patcher := &objectPatcher{namespace: helmApplication.Spec.Chart.Namespace}
+
+	if err := patcher.add(
+		"HelmApplication",
+		helmApplication,
+		helmApplication.Spec.Patches,
+	); err != nil {
+		return nil, err
+	}
+
+	for _, name := range generateSetOptions.NamedValues {
+		selected := loader.FindResourcesByName(loaderInt.NamedValues, []string{name})
+
+		for _, namedValues := range selected {
+			if err := namedValues.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid NamedValues %s: %w", name, err)
+			}
+
+			if err := patcher.add("NamedValues", namedValues, namedValues.Spec.Patches); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return patcher, nil
+}
+
+func (p *objectPatcher) add(kind string, owner api.Object, patches []v1.Patch) error {
+	for i, patch := range patches {
+		compiled := &objectPatch{
+			origin:   fmt.Sprintf("patches[%d] of %s %s", i, kind, owner.GetObjectMeta().Name),
+			patch:    patch,
+			selector: labels.Everything(),
+		}
+
+		if patch.Target.LabelSelector != "" {
+			selector, err := labels.Parse(patch.Target.LabelSelector)
+			if err != nil {
+				return fmt.Errorf("invalid label selector of %s: %w", compiled.origin, err)
+			}
+
+			compiled.selector = selector
+		}
+
+		if len(patch.JSON6902) != 0 {
+			operations, err := jsonpatch.DecodePatch(patch.JSON6902)
+			if err != nil {
+				return fmt.Errorf("invalid JSON patch of %s: %w", compiled.origin, err)
+			}
+
+			compiled.operations = operations
+		}
+
+		p.patches = append(p.patches, compiled)
+	}
+
+	return nil
+}
+
+// apply applies the patches targeting an object in their order
+func (p *objectPatcher) apply(object map[string]any) (map[string]any, error) {
+	for _, patch := range p.patches {
+		if !patch.matches(object, p.namespace) {
+			continue
+		}
+
+		patch.matched++
+
+		original, err := json.Marshal(object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode rendered object: %w", err)
+		}
+
+		var patched []byte
+
+		if patch.operations != nil {
+			patched, err = patch.operations.Apply(original)
+		} else {
+			patched, err = strategicMergePatch(object, original, patch.patch.StrategicMerge)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to apply %s to %s %s: %w",
+				patch.origin,
+				object["kind"],
+				objectName(object),
+				err,
+			)
+		}
+
+		object = map[string]any{}
+		if err := json.Unmarshal(patched, &object); err != nil {
+			return nil, fmt.Errorf("failed to decode patched object: %w", err)
+		}
+	}
+
+	return object, nil
+}
+
+// warnUnmatched logs the patches that did not match any rendered object, as their
+// target is likely wrong
+func (p *objectPatcher) warnUnmatched(appName string) {
+	for _, patch := range p.patches {
+		if patch.matched == 0 {
+			logger.Warnf("%s: %s did not match any rendered object", appName, patch.origin)
+		}
+	}
+}
+
+func (o *objectPatch) matches(object map[string]any, defaultNamespace string) bool {
+	target := o.patch.Target
+	metadata, _ := object["metadata"].(map[string]any)
+
+	namespace, _ := metadata["namespace"].(string)
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	if target.Kind != "" && object["kind"] != target.Kind {
+		return false
+	}
+
+	if target.Name != "" && objectName(object) != target.Name {
+		return false
+	}
+
+	if target.Namespace != "" && namespace != target.Namespace {
+		return false
+	}
+
+	objectLabels := labels.Set{}
+
+	rawLabels, _ := metadata["labels"].(map[string]any)
+	for key, value := range rawLabels {
+		objectLabels[key] = fmt.Sprint(value)
+	}
+
+	return o.selector.Matches(objectLabels)
+}
+
+func objectName(object map[string]any) string {
+	metadata, _ := object["metadata"].(map[string]any)
+	name, _ := metadata["name"].(string)
+
+	return name
+}
+
+// strategicMergePatch applies a strategic merge patch using the schema of built-in
+// kinds, and a JSON merge patch for other kinds, such as custom resources, the same
+// way kubectl patch does
+func strategicMergePatch(object map[string]any, original, patch []byte) ([]byte, error) {
+	apiVersion, _ := object["apiVersion"].(string)
+	kind, _ := object["kind"].(string)
+
+	dataStruct, err := scheme.Scheme.New(schema.FromAPIVersionAndKind(apiVersion, kind))
+	if err != nil {
+		return jsonpatch.MergePatch(original, patch)
+	}
+
+	return strategicpatch.StrategicMergePatch(original, patch, dataStruct)
+}
@@ -0,0 +1,301 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/komailo/kubeit/pkg/api/loader"
+)
+
+const patchedDeployment = `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: web:1.0
+---
+# Source: web/templates/worker.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: worker
+  namespace: jobs
+  labels:
+    app: worker
+spec:
+  template:
+    spec:
+      containers:
+        - name: worker
+          image: worker:1.0
+---
+# Source: web/templates/widget.yaml
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: web
+spec:
+  sizes: [small]
+`
+
+const patchResources = `apiVersion: kubeit.komailo.github.io/v1alpha1
+kind: HelmApplication
+metadata:
+  name: web
+spec:
+  chart:
+    path: charts/web
+    releaseName: web
+    namespace: apps
+  patches:
+    - target:
+        kind: Deployment
+        labelSelector: app=web
+      strategicMerge:
+        spec:
+          template:
+            spec:
+              containers:
+                - name: proxy
+                  image: proxy:1.0
+              tolerations:
+                - key: dedicated
+                  operator: Exists
+    - target:
+        kind: Deployment
+        namespace: jobs
+      json6902:
+        - op: add
+          path: /spec/template/spec/securityContext
+          value:
+            runAsNonRoot: true
+    - target:
+        kind: Widget
+      strategicMerge:
+        spec:
+          sizes: [large]
+    - target:
+        kind: Service
+      strategicMerge:
+        spec: {}
+---
+apiVersion: kubeit.komailo.github.io/v1alpha1
+kind: NamedValues
+metadata:
+  name: production
+spec:
+  values: []
+  patches:
+    - target:
+        name: web
+        namespace: apps
+        kind: Deployment
+      json6902:
+        - op: replace
+          path: /spec/template/spec/containers/1/image
+          value: web:1.1
+`
+
+func TestObjectPatcher(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeFiles(t, sourceDir, map[string]string{"resources.yaml": patchResources})
+
+	loaderInt := loader.NewLoader()
+	require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+
+	patcher, err := newObjectPatcher(
+		loaderInt.HelmApplications[0],
+		loaderInt,
+		&Options{NamedValues: []string{"production"}},
+	)
+	require.NoError(t, err)
+
+	manifest, err := transformObjects(patchedDeployment, patcher.apply)
+	require.NoError(t, err)
+	assert.Equal(t, `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: web
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - image: proxy:1.0
+        name: proxy
+      - image: web:1.1
+        name: web
+      tolerations:
+      - key: dedicated
+        operator: Exists
+---
+# Source: web/templates/worker.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: worker
+  name: worker
+  namespace: jobs
+spec:
+  template:
+    spec:
+      containers:
+      - image: worker:1.0
+        name: worker
+      securityContext:
+        runAsNonRoot: true
+---
+# Source: web/templates/widget.yaml
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: web
+spec:
+  sizes:
+  - large
+`, manifest)
+
+	// Strategic merge patches add new containers before the rendered ones, the
+	// NamedValues patch applies after the HelmApplication patches
+	var matched []int
+	for _, patch := range patcher.patches {
+		matched = append(matched, patch.matched)
+	}
+
+	assert.Equal(t, []int{1, 1, 1, 0, 1}, matched)
+}
+
+func TestObjectPatcher_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		patches string
+		wantErr string
+	}{
+		{
+			name: "invalid label selector",
+			patches: `    - target:
+        labelSelector: "app in (web"
+      strategicMerge: {}
+`,
+			wantErr: "invalid label selector of patches[0] of HelmApplication web",
+		},
+		{
+			name: "both patch types",
+			patches: `    - strategicMerge: {}
+      json6902: []
+`,
+			wantErr: "exactly one of spec.patches[0].strategicMerge and spec.patches[0].json6902",
+		},
+		{
+			name: "invalid JSON patch",
+			patches: `    - json6902:
+        - path: /spec
+`,
+			wantErr: "failed to apply patches[0] of HelmApplication web to ConfigMap web",
+		},
+		{
+			name: "missing path",
+			patches: `    - json6902:
+        - op: remove
+          path: /spec/missing
+`,
+			wantErr: "failed to apply patches[0] of HelmApplication web to ConfigMap web",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceDir := t.TempDir()
+			writeFiles(t, sourceDir, map[string]string{
+				"app.yaml": localChartApplication + "  patches:\n" + tt.patches,
+			})
+
+			loaderInt := loader.NewLoader()
+			require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+
+			require.ErrorContains(t, applyTestPatches(loaderInt), tt.wantErr)
+		})
+	}
+}
+
+// applyTestPatches validates the patches of the first application and applies them
+// to a ConfigMap
+func applyTestPatches(loaderInt *loader.Loader) error {
+	helmApplication := loaderInt.HelmApplications[0]
+	if err := helmApplication.Validate(); err != nil {
+		return err
+	}
+
+	patcher, err := newObjectPatcher(helmApplication, loaderInt, &Options{})
+	if err != nil {
+		return err
+	}
+
+	_, err = transformObjects("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n", patcher.apply)
+
+	return err
+}
+
+func TestHelmRenderer_Manifest_Patches(t *testing.T) {
+	sourceDir := t.TempDir()
+
+	writeFiles(t, sourceDir, map[string]string{
+		"app.yaml": localChartApplication + `  commonLabels:
+    team: web
+  patches:
+    - target:
+        kind: ConfigMap
+      strategicMerge:
+        metadata:
+          labels:
+            team: patched
+            kubeit.komail.io/app-name: patched
+        data:
+          mode: patched
+`,
+		"charts/web/Chart.yaml": "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+		"charts/web/templates/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+data:
+  mode: default
+`,
+	})
+
+	loaderInt := loader.NewLoader()
+	require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+
+	outputDir := t.TempDir()
+	renderer := newTestRenderer(t, t.TempDir(), true)
+
+	require.NoError(t, renderer.manifest(
+		loaderInt.HelmApplications[0],
+		loaderInt,
+		&Options{OutputDir: outputDir, WorkDir: t.TempDir()},
+	))
+
+	manifest, err := os.ReadFile(filepath.Join(outputDir, "web.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(manifest), "  mode: patched\n")
+
+	// The common labels are added after the patches
+	assert.Contains(t, string(manifest), "    team: web\n")
+	assert.Contains(t, string(manifest), "    kubeit.komail.io/app-name: web\n")
+}
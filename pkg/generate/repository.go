@@ -0,0 +1,266 @@
+package generate
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/registry"
+
+	"github.com/komailo/kubeit/internal/logger"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+// chartRepository holds the settings charts are pulled from a repository with
+type chartRepository struct {
+	Username        string
+	Password        string
+	PassCredentials bool
+	PlainHTTP       bool
+	getters         getter.Providers
+	registryClient  *registry.Client
+}
+
+// chartRepository returns the URL and the pull settings of the HelmRepository with
+// the given name. An empty name returns the default settings, which only use the Helm
+// configuration. The settings of a repository are created once per run.
+func (r *helmRenderer) chartRepository(
+	name string,
+	loaderInt *loader.Loader,
+) (string, *chartRepository, error) {
+	if name == "" {
+		return "", r.defaultRepository, nil
+	}
+
+	helmRepositories := loader.FindResourcesByName(loaderInt.HelmRepositories, []string{name})
+	if len(helmRepositories) == 0 {
+		return "", nil, fmt.Errorf("HelmRepository %s not found", name)
+	}
+
+	helmRepository := helmRepositories[0]
+
+	r.repositoriesMu.Lock()
+	defer r.repositoriesMu.Unlock()
+
+	if repository, ok := r.repositories[name]; ok {
+		return helmRepository.Spec.URL, repository, nil
+	}
+
+	repository, err := newChartRepository(r.settings, helmRepository, loaderInt)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid HelmRepository %s: %w", name, err)
+	}
+
+	r.repositories[name] = repository
+
+	return helmRepository.Spec.URL, repository, nil
+}
+
+// newChartRepository reads the credentials of a HelmRepository and creates the
+// clients used for both chart repositories and OCI registries
+func newChartRepository(
+	settings *cli.EnvSettings,
+	helmRepository *v1.HelmRepository,
+	loaderInt *loader.Loader,
+) (*chartRepository, error) {
+	if err := helmRepository.Validate(); err != nil {
+		return nil, err
+	}
+
+	spec := helmRepository.Spec
+	repository := &chartRepository{PlainHTTP: spec.PlainHTTP}
+
+	if auth := spec.Auth; auth != nil {
+		repository.Username = auth.Username
+		repository.PassCredentials = auth.PassCredentials
+
+		if auth.UsernameFrom != nil {
+			username, err := readSecret(auth.UsernameFrom)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the username: %w", err)
+			}
+
+			repository.Username = username
+		}
+
+		password, err := readSecret(auth.PasswordFrom)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the password: %w", err)
+		}
+
+		repository.Password = password
+		logger.AddSensitiveValue(password)
+	}
+
+	transport, err := newRepositoryTransport(helmRepository, loaderInt)
+	if err != nil {
+		return nil, err
+	}
+
+	repository.getters = getter.Providers{
+		{
+			Schemes: []string{"http", "https"},
+			New: func(options ...getter.Option) (getter.Getter, error) {
+				return getter.NewHTTPGetter(append(options, getter.WithTransport(transport))...)
+			},
+		},
+		{
+			Schemes: []string{registry.OCIScheme},
+			New:     getter.NewOCIGetter,
+		},
+	}
+
+	registryOptions := []registry.ClientOption{
+		registry.ClientOptHTTPClient(&http.Client{Transport: transport}),
+		registry.ClientOptCredentialsFile(settings.RegistryConfig),
+	}
+
+	if repository.Username != "" || repository.Password != "" {
+		registryOptions = append(
+			registryOptions,
+			registry.ClientOptBasicAuth(repository.Username, repository.Password),
+		)
+	}
+
+	if repository.PlainHTTP {
+		registryOptions = append(registryOptions, registry.ClientOptPlainHTTP())
+	}
+
+	repository.registryClient, err = registry.NewClient(registryOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create registry client: %w", err)
+	}
+
+	return repository, nil
+}
+
+// newRepositoryTransport returns the HTTP transport of a repository with its TLS and
+// proxy settings
+func newRepositoryTransport(
+	helmRepository *v1.HelmRepository,
+	loaderInt *loader.Loader,
+) (*http.Transport, error) {
+	spec := helmRepository.Spec
+
+	defaultTransport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("unexpected default HTTP transport")
+	}
+
+	transport := defaultTransport.Clone()
+
+	// Chart archives are served compressed and must be stored as they are, as Helm does
+	transport.DisableCompression = true
+
+	if spec.Proxy != "" {
+		proxyURL, err := url.Parse(spec.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %s: %w", spec.Proxy, err)
+		}
+
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	repositoryTLS := spec.TLS
+	if repositoryTLS == nil {
+		return transport, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: repositoryTLS.InsecureSkipTLSVerify,
+	}
+
+	if repositoryTLS.CAFile != "" {
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			rootCAs = x509.NewCertPool()
+		}
+
+		caBundle, err := readRepositoryFile(helmRepository, loaderInt, repositoryTLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+
+		if !rootCAs.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", repositoryTLS.CAFile)
+		}
+
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if repositoryTLS.CertFile != "" {
+		certPEM, err := readRepositoryFile(helmRepository, loaderInt, repositoryTLS.CertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client certificate: %w", err)
+		}
+
+		keyPEM, err := readRepositoryFile(helmRepository, loaderInt, repositoryTLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client key: %w", err)
+		}
+
+		certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
+
+// readRepositoryFile reads a TLS file of a HelmRepository. Relative paths are resolved
+// against the file of the resource, as the files of file value entries are, so they can
+// be embedded in Docker images. Absolute paths are read from disk as they are.
+func readRepositoryFile(
+	helmRepository *v1.HelmRepository,
+	loaderInt *loader.Loader,
+	filePath string,
+) ([]byte, error) {
+	if filepath.IsAbs(filePath) {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
+		}
+
+		return data, nil
+	}
+
+	return loaderInt.ReadFile(helmRepository, filePath)
+}
+
+// readSecret reads a secret from its environment variable or file. Trailing newlines
+// of files are removed.
+func readSecret(source *v1.SecretSource) (string, error) {
+	switch {
+	case source == nil:
+		return "", nil
+	case source.Env != "":
+		value, ok := os.LookupEnv(source.Env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", source.Env)
+		}
+
+		return value, nil
+	default:
+		data, err := os.ReadFile(source.File)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+}
@@ -0,0 +1,311 @@
+package generate
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/komailo/kubeit/pkg/api"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+func TestHelmRenderer_ChartRepository(t *testing.T) {
+	handler := chartRepositoryHandler(t, "app", "1.0.0")
+
+	// A chart repository with a private CA that requires basic auth
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "robot" ||
+			password != "s3cr3t-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	// A plain HTTP server that also serves requests it receives as a proxy
+	proxy := httptest.NewServer(handler)
+	t.Cleanup(proxy.Close)
+
+	secretsDir := t.TempDir()
+	caFile := filepath.Join(secretsDir, "ca.crt")
+	passwordFile := filepath.Join(secretsDir, "password")
+
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.Certificate().Raw,
+	}), 0o644))
+	require.NoError(t, os.WriteFile(passwordFile, []byte("s3cr3t-password\n"), 0o600))
+	t.Setenv("KUBEIT_TEST_REPOSITORY_USERNAME", "robot")
+
+	auth := &v1.RepositoryAuth{
+		UsernameFrom: &v1.SecretSource{Env: "KUBEIT_TEST_REPOSITORY_USERNAME"},
+		PasswordFrom: &v1.SecretSource{File: passwordFile},
+	}
+
+	tests := []struct {
+		name    string
+		spec    v1.HelmRepositorySpec
+		wantErr string
+	}{
+		{
+			name: "credentials and CA file",
+			spec: v1.HelmRepositorySpec{
+				URL:  server.URL,
+				Auth: auth,
+				TLS:  &v1.RepositoryTLS{CAFile: caFile},
+			},
+		},
+		{
+			name: "insecure skip TLS verify",
+			spec: v1.HelmRepositorySpec{
+				URL:  server.URL,
+				Auth: auth,
+				TLS:  &v1.RepositoryTLS{InsecureSkipTLSVerify: true},
+			},
+		},
+		{
+			name: "proxy",
+			spec: v1.HelmRepositorySpec{URL: "http://charts.example.invalid", Proxy: proxy.URL},
+		},
+		{
+			name:    "unknown CA",
+			spec:    v1.HelmRepositorySpec{URL: server.URL, Auth: auth},
+			wantErr: "certificate",
+		},
+		{
+			name: "missing credentials",
+			spec: v1.HelmRepositorySpec{
+				URL: server.URL,
+				TLS: &v1.RepositoryTLS{CAFile: caFile},
+			},
+			wantErr: "401",
+		},
+		{
+			name: "unset environment variable",
+			spec: v1.HelmRepositorySpec{
+				URL: server.URL,
+				Auth: &v1.RepositoryAuth{
+					Username:     "robot",
+					PasswordFrom: &v1.SecretSource{Env: "KUBEIT_TEST_REPOSITORY_UNSET"},
+				},
+			},
+			wantErr: "environment variable KUBEIT_TEST_REPOSITORY_UNSET is not set",
+		},
+		{
+			name: "invalid secret source",
+			spec: v1.HelmRepositorySpec{
+				URL: server.URL,
+				Auth: &v1.RepositoryAuth{
+					PasswordFrom: &v1.SecretSource{Env: "PASSWORD", File: passwordFile},
+				},
+			},
+			wantErr: "exactly one of spec.auth.passwordFrom.env and spec.auth.passwordFrom.file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			renderer := newTestRenderer(t, t.TempDir(), false)
+
+			loaderInt := loader.NewLoader()
+			loaderInt.HelmRepositories = []*v1.HelmRepository{{
+				BaseObject: api.BaseObject{Metadata: api.ObjectMeta{Name: "internal"}},
+				Spec:       tt.spec,
+			}}
+
+			helmApplication := &v1.HelmApplication{
+				BaseObject: api.BaseObject{Metadata: api.ObjectMeta{Name: "web"}},
+				Spec: v1.HelmApplicationSpec{
+					Chart: v1.ChartSpec{RepositoryRef: "internal", Name: "app", Version: "1.0.0"},
+				},
+			}
+
+			helmChart, resolved, err := renderer.resolveChart(
+				helmApplication,
+				loaderInt,
+				false,
+				t.TempDir(),
+			)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "1.0.0", helmChart.Metadata.Version)
+			assert.Equal(t, "internal", resolved.Chart.RepositoryRef)
+
+			entries, err := renderer.charts.List()
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			assert.Equal(t, tt.spec.URL, entries[0].Repository)
+		})
+	}
+
+	renderer := newTestRenderer(t, t.TempDir(), false)
+
+	_, _, err := renderer.chartRepository("missing", loader.NewLoader())
+	assert.ErrorContains(t, err, "HelmRepository missing not found")
+}
+
+func TestHelmRenderer_ChartRepository_RelativeTLSFiles(t *testing.T) {
+	server := httptest.NewTLSServer(chartRepositoryHandler(t, "app", "1.0.0"))
+	t.Cleanup(server.Close)
+
+	sourceDir := t.TempDir()
+
+	writeFiles(t, sourceDir, map[string]string{
+		"repositories/internal.yaml": `apiVersion: kubeit.komailo.github.io/v1alpha1
+kind: HelmRepository
+metadata:
+  name: internal
+spec:
+  url: ` + server.URL + `
+  tls:
+    caFile: certs/ca.crt
+`,
+		"repositories/certs/ca.crt": string(pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: server.Certificate().Raw,
+		})),
+	})
+
+	loaderInt := loader.NewLoader()
+	require.Empty(t, loaderInt.FromSourceURI(sourceDir))
+	require.Len(t, loaderInt.HelmRepositories, 1)
+
+	helmApplication := &v1.HelmApplication{
+		BaseObject: api.BaseObject{Metadata: api.ObjectMeta{Name: "web"}},
+		Spec: v1.HelmApplicationSpec{
+			Chart: v1.ChartSpec{RepositoryRef: "internal", Name: "app", Version: "1.0.0"},
+		},
+	}
+
+	renderer := newTestRenderer(t, t.TempDir(), false)
+
+	_, _, err := renderer.resolveChart(helmApplication, loaderInt, false, t.TempDir())
+	require.NoError(t, err, "Expected the CA file relative to the resource")
+
+	// The CA file is embedded so an image source reads it from the embedded files
+	require.Empty(t, loaderInt.EmbedFiles())
+	assert.Equal(t, "repositories/certs/ca.crt", loaderInt.HelmRepositories[0].Spec.TLS.CAFile)
+	assert.Contains(t, loaderInt.Files, "repositories/certs/ca.crt")
+
+	imageLoader := loader.NewLoader()
+	imageLoader.SourceMeta.Scheme = "docker"
+	imageLoader.Files = loaderInt.Files
+	imageLoader.HelmRepositories = loaderInt.HelmRepositories
+
+	_, _, err = newTestRenderer(t, t.TempDir(), false).
+		resolveChart(helmApplication, imageLoader, false, t.TempDir())
+	require.NoError(t, err, "Expected the CA file from the embedded files")
+}
+
+func TestDockerLabels_RepositoryClientKey(t *testing.T) {
+	clientKey := testPEM("EC PRIVATE KEY", "client-key-bytes")
+
+	keyDir := t.TempDir()
+	keyFile := filepath.Join(keyDir, "client.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(clientKey), 0o600))
+
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+		wantErr  string
+	}{
+		{
+			name:     "relative key file",
+			certFile: filepath.Join(keyDir, "client.crt"),
+			keyFile:  "certs/client.key",
+			wantErr:  "spec.tls.keyFile certs/client.key must be an absolute path",
+		},
+		{
+			name:     "absolute key file",
+			certFile: filepath.Join(keyDir, "client.crt"),
+			keyFile:  keyFile,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sourceDir := t.TempDir()
+
+			writeFiles(t, sourceDir, map[string]string{
+				"repositories/internal.yaml": `apiVersion: kubeit.komailo.github.io/v1alpha1
+kind: HelmRepository
+metadata:
+  name: internal
+spec:
+  url: https://charts.example.com
+  tls:
+    caFile: certs/ca.crt
+    certFile: ` + tt.certFile + `
+    keyFile: ` + tt.keyFile + `
+`,
+				"repositories/certs/ca.crt":     testPEM("CERTIFICATE", "ca"),
+				"repositories/certs/client.crt": testPEM("CERTIFICATE", "cert"),
+				"repositories/certs/client.key": clientKey,
+			})
+
+			labelArgs, generateErrs, loadErrs := DockerLabels(
+				&Options{SourceConfigURI: sourceDir},
+			)
+			require.Empty(t, loadErrs)
+
+			if tt.wantErr != "" {
+				require.Len(t, generateErrs, 1)
+				assert.ErrorContains(t, generateErrs[0], tt.wantErr)
+				assert.Empty(t, labelArgs)
+
+				return
+			}
+
+			require.Empty(t, generateErrs)
+
+			for _, label := range strings.Fields(labelArgs) {
+				_, value, found := strings.Cut(label, "=")
+				if !found {
+					continue
+				}
+
+				decoded, err := base64.StdEncoding.DecodeString(value)
+				if err != nil {
+					continue
+				}
+
+				assert.NotContains(t, string(decoded), clientKey)
+
+				var files map[string][]byte
+				if json.Unmarshal(decoded, &files) != nil {
+					continue
+				}
+
+				assert.Contains(t, files, "repositories/certs/ca.crt")
+
+				for key, data := range files {
+					assert.NotContains(t, string(data), clientKey, key)
+				}
+			}
+		})
+	}
+}
+
+// testPEM encodes data as a PEM block, which the loader skips as it is not a resource
+func testPEM(blockType, data string) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: []byte(data)}))
+}
@@ -0,0 +1,323 @@
+package generate
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/copystructure"
+	"github.com/xeipuuv/gojsonschema"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/komailo/kubeit/pkg/api/loader"
+)
+
+// schemaContextDelimiter separates the keys of a schema error context. A control
+// character is used since keys can contain dots.
+const schemaContextDelimiter = "\x00"
+
+// chartDefaultsOrigin is the origin of values that are not set by a value entry
+const chartDefaultsOrigin = "the chart default values"
+
+var jsonPathIdentifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+// SchemaViolation is a value that does not satisfy the values schema of a chart
+type SchemaViolation struct {
+	// Path is the JSON path of the value, e.g. $.controller.image.tag
+	Path    string
+	Message string
+	// Origins are the value entries that set the value
+	Origins []string
+}
+
+func (v SchemaViolation) String() string {
+	if len(v.Origins) == 0 {
+		return fmt.Sprintf("%s: %s", v.Path, v.Message)
+	}
+
+	return fmt.Sprintf("%s: %s (set by %s)", v.Path, v.Message, strings.Join(v.Origins, ", "))
+}
+
+// SchemaError holds every violation of the values schemas of a chart and its
+// subcharts
+type SchemaError struct {
+	Chart      string
+	Violations []SchemaViolation
+}
+
+func (e *SchemaError) Error() string {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "values do not match the values schema of chart %s:", e.Chart)
+
+	for _, violation := range e.Violations {
+		fmt.Fprintf(&sb, "\n  - %s", violation)
+	}
+
+	return sb.String()
+}
+
+// validateValuesSchema validates the values against the values.schema.json of the
+// chart and its enabled subcharts, the same way Helm does before rendering. Every
+// violation is mapped back to the value entries that set the value.
+func validateValuesSchema(
+	helmChart *chart.Chart,
+	chartValues map[string]any,
+	layers []valueLayer,
+	loaderInt *loader.Loader,
+) error {
+	// Processing the dependencies prunes the disabled subcharts and imports values, so
+	// it runs on a copy to leave the chart Helm renders untouched
+	helmChart, err := copyChart(helmChart)
+	if err != nil {
+		return err
+	}
+
+	if err := chartutil.ProcessDependenciesWithMerge(helmChart, chartValues); err != nil {
+		return fmt.Errorf("failed to process chart dependencies: %w", err)
+	}
+
+	coalescedValues, err := chartutil.CoalesceValues(helmChart, chartValues)
+	if err != nil {
+		return fmt.Errorf("failed to coalesce chart values: %w", err)
+	}
+
+	violations, err := schemaViolations(helmChart, coalescedValues, nil)
+	if err != nil {
+		return err
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	provenance, err := explainValueLayers(layers, loaderInt)
+	if err != nil {
+		return err
+	}
+
+	schemaError := &SchemaError{Chart: helmChart.Name()}
+
+	for _, violation := range violations {
+		jsonPath, origins := provenance.locate(coalescedValues, violation.Keys)
+
+		// A missing required value is not set by anything
+		if len(origins) == 0 && violation.Type != "required" {
+			origins = []string{chartDefaultsOrigin}
+		}
+
+		schemaError.Violations = append(schemaError.Violations, SchemaViolation{
+			Path:    jsonPath,
+			Message: violation.Message,
+			Origins: origins,
+		})
+	}
+
+	return schemaError
+}
+
+// copyChart returns a copy of a chart and its subcharts whose metadata, values and
+// dependencies can be changed without changing the chart. Templates and files are
+// shared.
+func copyChart(helmChart *chart.Chart) (*chart.Chart, error) {
+	copied := *helmChart
+
+	if helmChart.Metadata != nil {
+		metadata := *helmChart.Metadata
+		metadata.Dependencies = make([]*chart.Dependency, 0, len(helmChart.Metadata.Dependencies))
+
+		for _, dependency := range helmChart.Metadata.Dependencies {
+			copiedDependency := *dependency
+			copiedDependency.ImportValues = slices.Clone(dependency.ImportValues)
+			metadata.Dependencies = append(metadata.Dependencies, &copiedDependency)
+		}
+
+		copied.Metadata = &metadata
+	}
+
+	values, err := copystructure.Copy(helmChart.Values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy values of chart %s: %w", helmChart.Name(), err)
+	}
+
+	copied.Values, _ = values.(map[string]any)
+
+	dependencies := make([]*chart.Chart, 0, len(helmChart.Dependencies()))
+
+	for _, dependency := range helmChart.Dependencies() {
+		copiedDependency, err := copyChart(dependency)
+		if err != nil {
+			return nil, err
+		}
+
+		dependencies = append(dependencies, copiedDependency)
+	}
+
+	copied.SetDependencies(dependencies...)
+
+	return &copied, nil
+}
+
+// schemaResult is a schema validation error with the path of the value as a list of
+// keys and list indexes
+type schemaResult struct {
+	Keys    []string
+	Type    string
+	Message string
+}
+
+// schemaViolations returns the violations of the chart schema and those of its
+// subcharts
+func schemaViolations(
+	helmChart *chart.Chart,
+	values map[string]any,
+	prefix []string,
+) ([]schemaResult, error) {
+	var violations []schemaResult
+
+	if len(helmChart.Schema) > 0 {
+		results, err := validateSingleSchema(helmChart.Schema, values)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"failed to validate values against the schema of chart %s: %w",
+				helmChart.Name(),
+				err,
+			)
+		}
+
+		for _, result := range results {
+			keys := strings.Split(result.Context().String(schemaContextDelimiter), schemaContextDelimiter)
+			keys = append(append([]string{}, prefix...), keys[1:]...)
+
+			if result.Type() == "required" {
+				if property, ok := result.Details()["property"].(string); ok {
+					keys = append(keys, property)
+				}
+			}
+
+			violations = append(violations, schemaResult{
+				Keys:    keys,
+				Type:    result.Type(),
+				Message: result.Description(),
+			})
+		}
+	}
+
+	for _, subchart := range helmChart.Dependencies() {
+		subchartValues, ok := values[subchart.Name()].(map[string]any)
+		if !ok {
+			continue
+		}
+
+		subchartViolations, err := schemaViolations(
+			subchart,
+			subchartValues,
+			append(append([]string{}, prefix...), subchart.Name()),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		violations = append(violations, subchartViolations...)
+	}
+
+	return violations, nil
+}
+
+// validateSingleSchema validates values against a single schema
+func validateSingleSchema(
+	schema []byte,
+	values map[string]any,
+) (results []gojsonschema.ResultError, err error) {
+	// gojsonschema panics on some invalid schemas
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("unable to validate schema: %s", r)
+		}
+	}()
+
+	valuesYAML, err := k8syaml.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode values: %w", err)
+	}
+
+	valuesJSON, err := k8syaml.YAMLToJSON(valuesYAML)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode values: %w", err)
+	}
+
+	if bytes.Equal(valuesJSON, []byte("null")) {
+		valuesJSON = []byte("{}")
+	}
+
+	result, err := gojsonschema.Validate(
+		gojsonschema.NewBytesLoader(schema),
+		gojsonschema.NewBytesLoader(valuesJSON),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate values: %w", err)
+	}
+
+	return result.Errors(), nil
+}
+
+// locate returns the JSON path of a value in the coalesced values and the value
+// entries that set it, or any value below it
+func (p valuesProvenance) locate(coalescedValues map[string]any, keys []string) (string, []string) {
+	var (
+		keyPath  string
+		jsonPath     = "$"
+		current  any = coalescedValues
+	)
+
+	for _, key := range keys {
+		list, isList := current.([]any)
+		index, err := strconv.Atoi(key)
+
+		if isList && err == nil && index >= 0 && index < len(list) {
+			keyPath = fmt.Sprintf("%s[%d]", keyPath, index)
+			jsonPath = fmt.Sprintf("%s[%d]", jsonPath, index)
+			current = list[index]
+
+			continue
+		}
+
+		keyPath = joinValuePath(keyPath, key)
+
+		if jsonPathIdentifier.MatchString(key) {
+			jsonPath += "." + key
+		} else {
+			jsonPath += fmt.Sprintf("[%q]", key)
+		}
+
+		currentMap, _ := current.(map[string]any)
+		current = currentMap[key]
+	}
+
+	var origins []string
+
+	seen := map[string]bool{}
+
+	for _, leafPath := range sortedKeys(p.History) {
+		if keyPath != "" && leafPath != keyPath &&
+			!strings.HasPrefix(leafPath, keyPath+".") &&
+			!strings.HasPrefix(leafPath, keyPath+"[") {
+			continue
+		}
+
+		history := p.History[leafPath]
+		origin := history[len(history)-1].String()
+
+		if !seen[origin] {
+			seen[origin] = true
+			origins = append(origins, origin)
+		}
+	}
+
+	return jsonPath, origins
+}
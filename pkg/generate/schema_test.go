@@ -0,0 +1,145 @@
+package generate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+
+	"github.com/komailo/kubeit/pkg/api"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+func TestValidateValuesSchema(t *testing.T) {
+	subchart := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "redis", APIVersion: "v2", Version: "1.0.0"},
+		Values:   map[string]any{"port": 6379},
+		Schema:   []byte(`{"properties": {"port": {"type": "integer", "maximum": 65535}}}`),
+	}
+
+	helmChart := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "app", APIVersion: "v2", Version: "1.0.0"},
+		Values: map[string]any{
+			"replicas": 1,
+			"image":    map[string]any{"repository": "app", "tag": "1.0"},
+			"args":     []any{"--port=8080"},
+		},
+		Schema: []byte(`{
+			"required": ["name"],
+			"properties": {
+				"replicas": {"type": "integer", "minimum": 1},
+				"image": {"properties": {"tag": {"type": "string"}}},
+				"args": {"items": {"type": "string"}},
+				"podAnnotations": {"additionalProperties": {"type": "string"}}
+			}
+		}`),
+	}
+	helmChart.AddDependency(subchart)
+
+	loaderInt := loader.NewLoader()
+	helmApplication := &v1.HelmApplication{
+		BaseObject: api.BaseObject{Metadata: api.ObjectMeta{Name: "app"}},
+		Spec: v1.HelmApplicationSpec{
+			Values: []v1.ValueEntry{
+				{
+					Type: "mapping",
+					Data: json.RawMessage(
+						`{"replicas": "0", "image.tag": "true", "redis.port": "70000"}`,
+					),
+				},
+				{
+					Type: "json",
+					Data: json.RawMessage(`{"podAnnotations": {"example.com/team": 1}}`),
+				},
+			},
+		},
+	}
+
+	chartValues, layers, err := generateHelmValues(helmApplication, loaderInt, &Options{})
+	require.NoError(t, err)
+
+	err = validateValuesSchema(helmChart, chartValues, layers, loaderInt)
+	require.Error(t, err)
+
+	var schemaError *SchemaError
+	require.ErrorAs(t, err, &schemaError)
+
+	violations := map[string][]string{}
+	for _, violation := range schemaError.Violations {
+		violations[violation.Path] = violation.Origins
+	}
+
+	assert.Equal(t, map[string][]string{
+		"$.name":                               nil,
+		"$.replicas":                           {"HelmApplication/app values[0] (mapping)"},
+		"$.image.tag":                          {"HelmApplication/app values[0] (mapping)"},
+		`$.podAnnotations["example.com/team"]`: {"HelmApplication/app values[1] (json)"},
+		"$.redis.port":                         {"HelmApplication/app values[0] (mapping)"},
+	}, violations)
+
+	helmApplication.Spec.Values = []v1.ValueEntry{
+		{Type: "mapping", Data: json.RawMessage(`{"name": "app"}`)},
+	}
+
+	chartValues, layers, err = generateHelmValues(helmApplication, loaderInt, &Options{})
+	require.NoError(t, err)
+	require.NoError(t, validateValuesSchema(helmChart, chartValues, layers, loaderInt))
+
+	helmChart.Values["args"] = []any{8080}
+
+	err = validateValuesSchema(helmChart, chartValues, layers, loaderInt)
+	require.ErrorAs(t, err, &schemaError)
+	require.Len(t, schemaError.Violations, 1)
+	assert.Equal(t, "$.args[0]", schemaError.Violations[0].Path)
+	assert.Equal(t, []string{chartDefaultsOrigin}, schemaError.Violations[0].Origins)
+}
+
+func TestValidateValuesSchema_DisabledSubchart(t *testing.T) {
+	newTemplate := func(name string) *chart.File {
+		return &chart.File{
+			Name: "templates/configmap.yaml",
+			Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\n"),
+		}
+	}
+
+	helmChart := &chart.Chart{
+		Metadata: &chart.Metadata{
+			Name:       "app",
+			APIVersion: "v2",
+			Version:    "1.0.0",
+			Dependencies: []*chart.Dependency{
+				{Name: "cache", Version: "1.0.0", Condition: "cache.enabled"},
+			},
+		},
+		Values:    map[string]any{"cache": map[string]any{"enabled": false}},
+		Templates: []*chart.File{newTemplate("app")},
+	}
+	helmChart.AddDependency(&chart.Chart{
+		Metadata:  &chart.Metadata{Name: "cache", APIVersion: "v2", Version: "1.0.0"},
+		Templates: []*chart.File{newTemplate("cache")},
+	})
+
+	loaderInt := loader.NewLoader()
+
+	require.NoError(t, validateValuesSchema(helmChart, map[string]any{}, nil, loaderInt))
+	require.Len(t, helmChart.Dependencies(), 1, "Expected validation to leave the chart unchanged")
+	assert.Equal(t, map[string]any{"cache": map[string]any{"enabled": false}}, helmChart.Values)
+
+	installClient := action.NewInstall(&action.Configuration{})
+	installClient.DryRun = true
+	installClient.ClientOnly = true
+	installClient.ReleaseName = "app"
+	installClient.Namespace = "default"
+
+	release, err := installClient.Run(
+		helmChart,
+		map[string]any{"cache": map[string]any{"enabled": true}},
+	)
+	require.NoError(t, err)
+	assert.Contains(t, release.Manifest, "name: app")
+	assert.Contains(t, release.Manifest, "name: cache", "Expected the subchart to render once enabled")
+}
@@ -1,13 +1,58 @@
 package generate
 
-import "github.com/komailo/kubeit/common"
+import (
+	"io"
+
+	"github.com/komailo/kubeit/common"
+)
 
 type Options struct {
-	OutputDir       string
+	OutputDir string
+	// OutputLayout is how the manifests are written, one of OutputLayouts,
+	// per-application when empty
+	OutputLayout string
+	// Kustomization also writes a kustomization.yaml with the written manifests as its
+	// resources
+	Kustomization bool
+	// Clean removes the contents of the output directory before the manifests are
+	// written. Without it the manifests are only written when every file in the output
+	// directory is overwritten.
+	Clean bool
+	// Stdout is where the stdout output layout writes to, os.Stdout when nil
+	Stdout io.Writer
+	// Stderr is where the digest of the written manifests is printed, os.Stderr when nil
+	Stderr          io.Writer
 	WorkDir         string
 	SourceConfigURI string
 	KubeVersion     string
+	// APIVersions are added to the API versions charts are rendered against
+	APIVersions []string
+	// CapabilitiesFile is a capabilities profile charts are rendered against
+	CapabilitiesFile string
+	// ClusterStateDir is a directory of Kubernetes objects returned by lookup calls
+	ClusterStateDir string
 	NamedValues     []string
+	AgeKeyFile      string
+	// Variables are name=value pairs that take precedence over the generated variables
+	Variables []string
+	// ValuesFormat is the format values are written in, yaml or json
+	ValuesFormat string
+	// RevealSensitive writes the values of sensitive and encrypted entries, and of keys
+	// matching a sensitive key pattern, in plaintext instead of masking them
+	RevealSensitive bool
+	// Parallelism is the number of applications rendered at the same time
+	Parallelism int
+	// ContinueOnError renders every application it can instead of stopping at the
+	// first failure
+	ContinueOnError bool
+	// CacheDir is the directory of the chart cache, the user cache directory is used
+	// when empty
+	CacheDir string
+	// Offline only uses charts from the cache instead of pulling them
+	Offline bool
+	// UpdateLock resolves the charts again instead of using the locked versions and
+	// writes them to the lock file
+	UpdateLock bool
 }
 
 type ManifestSource struct {
@@ -16,25 +61,67 @@ type ManifestSource struct {
 	RawURI string
 }
 
-type CommonK8sLabels struct{}
+// CommonK8sLabels are the labels added to every rendered object
+type CommonK8sLabels struct {
+	AppName     string
+	GeneratedBy string
+	// Labels are the common labels of the HelmApplication
+	Labels map[string]string
+}
 
+// CommonK8sAnnotations are the annotations added to every rendered object
 type CommonK8sAnnotations struct {
 	AppName     string
 	AppType     string
 	GeneratedBy string
+	Version     string
+	// SourceImage and SourceDigest are only set when the resources are loaded from a
+	// Docker image
+	SourceImage  string
+	SourceDigest string
+	// ConfigHash changes whenever the chart or the values of the application change
+	ConfigHash string
+	// Annotations are the common annotations of the HelmApplication
+	Annotations map[string]string
 }
 
 type stringMap map[string]string
 
+// GenerateAnnotations returns the common annotations. The kubeit annotations take
+// precedence over the ones of the HelmApplication, empty ones are left out.
 func (c *CommonK8sAnnotations) GenerateAnnotations() map[string]string {
-	return map[string]string{
-		common.KubeitDomain + "/app-name":     c.AppName,
-		common.KubeitDomain + "/app-type":     c.AppType,
-		common.KubeitDomain + "/generated-by": c.GeneratedBy,
+	annotations := make(map[string]string, len(c.Annotations)+7)
+	for key, value := range c.Annotations {
+		annotations[key] = value
+	}
+
+	for key, value := range map[string]string{
+		common.KubeitDomain + "/app-name":      c.AppName,
+		common.KubeitDomain + "/app-type":      c.AppType,
+		common.KubeitDomain + "/generated-by":  c.GeneratedBy,
+		common.KubeitDomain + "/version":       c.Version,
+		common.KubeitDomain + "/source-image":  c.SourceImage,
+		common.KubeitDomain + "/source-digest": c.SourceDigest,
+		common.KubeitDomain + "/config-hash":   c.ConfigHash,
+	} {
+		if value != "" {
+			annotations[key] = value
+		}
 	}
+
+	return annotations
 }
 
+// GenerateLabels returns the common labels. The kubeit labels take precedence over
+// the ones of the HelmApplication.
 func (c *CommonK8sLabels) GenerateLabels() map[string]string {
-	// TODO: Implement label generation logic here
-	return map[string]string{}
+	labels := make(map[string]string, len(c.Labels)+2)
+	for key, value := range c.Labels {
+		labels[key] = value
+	}
+
+	labels[common.KubeitDomain+"/app-name"] = c.AppName
+	labels[common.KubeitDomain+"/generated-by"] = c.GeneratedBy
+
+	return labels
 }
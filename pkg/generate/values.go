@@ -0,0 +1,209 @@
+package generate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/komailo/kubeit/internal/logger"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+// Formats the merged values can be written in
+const (
+	ValuesFormatYAML = "yaml"
+	ValuesFormatJSON = "json"
+)
+
+// Values writes the merged Helm values of every HelmApplication to the output
+// directory without pulling or rendering the charts
+func Values(generateSetOptions *Options) ([]error, map[string][]error) {
+	sourceConfigURI := generateSetOptions.SourceConfigURI
+	logger.Infof("Generating values from %s", sourceConfigURI)
+
+	loaderInt := loader.NewLoader()
+	loaderErr := loaderInt.FromSourceURI(sourceConfigURI)
+
+	if len(loaderErr) != 0 {
+		return nil, loaderErr
+	}
+
+	if loaderInt.ResourceCount == 0 {
+		return []error{
+			fmt.Errorf("no Kubeit resources found when traversing: %s", sourceConfigURI),
+		}, nil
+	}
+
+	loaderInt.LogResources()
+
+	if len(loaderInt.HelmApplications) == 0 {
+		return []error{errors.New("no HelmApplication resources found")}, nil
+	}
+
+	var errs []error
+
+	for _, helmApplication := range loaderInt.HelmApplications {
+		if err := ValuesFromHelm(helmApplication, loaderInt, generateSetOptions); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs, nil
+}
+
+// ValuesFromHelm writes the merged Helm values of a HelmApplication to
+// <app>.values.yaml, or <app>.values.json, in the output directory
+func ValuesFromHelm(
+	helmApplication *v1.HelmApplication,
+	loaderInt *loader.Loader,
+	generateSetOptions *Options,
+) error {
+	name := helmApplication.Metadata.Name
+
+	chartValues, layers, err := generateHelmValues(helmApplication, loaderInt, generateSetOptions)
+	if err != nil {
+		return newAppError(name, StageValues, fmt.Errorf("failed to generate Helm values: %w", err))
+	}
+
+	if !generateSetOptions.RevealSensitive {
+		chartValues, err = mergeValueLayers(maskSensitiveLayers(layers))
+		if err != nil {
+			return newAppError(
+				name,
+				StageValues,
+				fmt.Errorf("failed to generate Helm values: %w", err),
+			)
+		}
+
+		chartValues, _ = maskSensitiveKeys("", chartValues).(map[string]any)
+	}
+
+	var encodedValues []byte
+
+	switch generateSetOptions.ValuesFormat {
+	case ValuesFormatYAML, "":
+		encodedValues, err = k8syaml.Marshal(chartValues)
+	case ValuesFormatJSON:
+		encodedValues, err = json.MarshalIndent(chartValues, "", "  ")
+		encodedValues = append(encodedValues, '\n')
+	default:
+		return newAppError(
+			name,
+			StageWrite,
+			fmt.Errorf("unsupported values format: %s", generateSetOptions.ValuesFormat),
+		)
+	}
+
+	if err != nil {
+		return newAppError(name, StageWrite, fmt.Errorf("failed to encode Helm values: %w", err))
+	}
+
+	format := generateSetOptions.ValuesFormat
+	if format == "" {
+		format = ValuesFormatYAML
+	}
+
+	outputFile := filepath.Join(
+		generateSetOptions.OutputDir,
+		fmt.Sprintf("%s.values.%s", name, format),
+	)
+	// The values can hold secrets, e.g. with --reveal-sensitive
+	if err := os.WriteFile(outputFile, encodedValues, 0o600); err != nil {
+		return newAppError(name, StageWrite, fmt.Errorf("failed to write Helm values: %w", err))
+	}
+
+	logger.Infof("Helm values of %s written to %s", name, outputFile)
+
+	return nil
+}
+
+// maskSensitiveLayers returns the value layers with the values of sensitive and
+// encrypted entries masked, so decrypted values are not written in plaintext
+func maskSensitiveLayers(layers []valueLayer) []valueLayer {
+	masked := make([]valueLayer, 0, len(layers))
+
+	for _, layer := range layers {
+		if layer.Sensitive {
+			maskedValues, _ := maskValue(layer.Values).(map[string]any)
+			layer.Values = maskedValues
+
+			assignments := make([]valueAssignment, 0, len(layer.Assignments))
+			for _, assignment := range layer.Assignments {
+				assignments = append(assignments, valueAssignment{
+					Path:  assignment.Path,
+					Value: maskValue(assignment.Value),
+				})
+			}
+
+			layer.Assignments = assignments
+		}
+
+		masked = append(masked, layer)
+	}
+
+	return masked
+}
+
+// maskSensitiveKeys returns a copy of a values tree with the leaves whose dotted key
+// path matches a sensitive key pattern masked, as they are in logs
+func maskSensitiveKeys(keyPath string, value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		masked := make(map[string]any, len(typed))
+		for key, value := range typed {
+			childPath := key
+			if keyPath != "" {
+				childPath = keyPath + "." + key
+			}
+
+			masked[key] = maskSensitiveKeys(childPath, value)
+		}
+
+		return masked
+	case []any:
+		masked := make([]any, 0, len(typed))
+		for i, value := range typed {
+			masked = append(masked, maskSensitiveKeys(fmt.Sprintf("%s[%d]", keyPath, i), value))
+		}
+
+		return masked
+	case nil:
+		return nil
+	default:
+		if logger.IsSensitiveKey(keyPath) {
+			return logger.RedactedValue
+		}
+
+		return value
+	}
+}
+
+// maskValue returns a copy of a values tree with every leaf replaced by the redacted
+// value
+func maskValue(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		masked := make(map[string]any, len(typed))
+		for key, value := range typed {
+			masked[key] = maskValue(value)
+		}
+
+		return masked
+	case []any:
+		masked := make([]any, 0, len(typed))
+		for _, value := range typed {
+			masked = append(masked, maskValue(value))
+		}
+
+		return masked
+	case nil:
+		return nil
+	default:
+		return logger.RedactedValue
+	}
+}
@@ -0,0 +1,198 @@
+package generate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxValueIndex is the largest list index a key path can assign, the same limit Helm
+// applies to --set values
+const maxValueIndex = 65536
+
+// pathSegment is a map key or a list index of a key path
+type pathSegment struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+// valueAssignment sets a value at a key path
+type valueAssignment struct {
+	Path  string
+	Value any
+}
+
+// parseValuePath splits a key path such as controller.image.tag or args[0] into its
+// segments. Dots and brackets that are part of a key are escaped with a backslash,
+// e.g. podAnnotations.example\.com/team.
+func parseValuePath(keyPath string) ([]pathSegment, error) {
+	var (
+		segments []pathSegment
+		key      strings.Builder
+		hasKey   bool
+	)
+
+	endKey := func() error {
+		if !hasKey {
+			return fmt.Errorf("key path %q has an empty key", keyPath)
+		}
+
+		segments = append(segments, pathSegment{Key: key.String()})
+		key.Reset()
+		hasKey = false
+
+		return nil
+	}
+
+	runes := []rune(keyPath)
+	separated := false
+
+	for i := 0; i < len(runes); i++ {
+		separated = false
+
+		switch runes[i] {
+		case '\\':
+			if i+1 == len(runes) {
+				return nil, fmt.Errorf("key path %q ends with an escape character", keyPath)
+			}
+
+			i++
+
+			key.WriteRune(runes[i])
+			hasKey = true
+		case '.':
+			separated = true
+
+			// A list index already ended the previous segment
+			if !hasKey && len(segments) > 0 && segments[len(segments)-1].IsIndex {
+				continue
+			}
+
+			if err := endKey(); err != nil {
+				return nil, err
+			}
+		case '[':
+			if hasKey {
+				if err := endKey(); err != nil {
+					return nil, err
+				}
+			}
+
+			if len(segments) == 0 {
+				return nil, fmt.Errorf("key path %q must start with a key", keyPath)
+			}
+
+			end := i + 1
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+
+			if end == len(runes) {
+				return nil, fmt.Errorf("key path %q has an unclosed bracket", keyPath)
+			}
+
+			indexString := string(runes[i+1 : end])
+
+			index, err := strconv.Atoi(indexString)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("key path %q has an invalid list index %q", keyPath, indexString)
+			}
+
+			if index >= maxValueIndex {
+				return nil, fmt.Errorf(
+					"key path %q has a list index larger than %d",
+					keyPath,
+					maxValueIndex,
+				)
+			}
+
+			segments = append(segments, pathSegment{Index: index, IsIndex: true})
+			i = end
+
+			if i+1 < len(runes) && runes[i+1] != '.' && runes[i+1] != '[' {
+				return nil, fmt.Errorf("key path %q has a key directly after a list index", keyPath)
+			}
+		default:
+			key.WriteRune(runes[i])
+			hasKey = true
+		}
+	}
+
+	if separated || hasKey || len(segments) == 0 {
+		if err := endKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	return segments, nil
+}
+
+// assignValuePath returns a copy of values with value set at the key path. Maps and
+// lists along the path are copied so values is left unchanged, missing ones are
+// created and lists are padded with null to reach an index.
+func assignValuePath(values map[string]any, keyPath string, value any) (map[string]any, error) {
+	segments, err := parseValuePath(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Key paths always start with a key so the result is a map
+	assigned, _ := assignValue(values, segments, value).(map[string]any)
+
+	return assigned, nil
+}
+
+func assignValue(current any, segments []pathSegment, value any) any {
+	if len(segments) == 0 {
+		return value
+	}
+
+	segment := segments[0]
+
+	if segment.IsIndex {
+		currentList, _ := current.([]any)
+
+		list := make([]any, max(len(currentList), segment.Index+1))
+		copy(list, currentList)
+
+		list[segment.Index] = assignValue(list[segment.Index], segments[1:], value)
+
+		return list
+	}
+
+	currentMap, _ := current.(map[string]any)
+
+	valuesMap := make(map[string]any, len(currentMap)+1)
+	for key, child := range currentMap {
+		valuesMap[key] = child
+	}
+
+	valuesMap[segment.Key] = assignValue(valuesMap[segment.Key], segments[1:], value)
+
+	return valuesMap
+}
+
+// typedSetValue converts a mapping value the same way Helm types --set values.
+// Booleans, null and integers are converted, anything else stays a string.
+func typedSetValue(value string) any {
+	switch {
+	case strings.EqualFold(value, "true"):
+		return true
+	case strings.EqualFold(value, "false"):
+		return false
+	case strings.EqualFold(value, "null"):
+		return nil
+	case value == "0":
+		return int64(0)
+	}
+
+	// Values with a leading zero, such as 0755, stay strings like in Helm
+	if value != "" && value[0] != '0' {
+		if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return intValue
+		}
+	}
+
+	return value
+}
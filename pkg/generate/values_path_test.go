@@ -0,0 +1,102 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseValuePath(t *testing.T) {
+	testCases := []struct {
+		keyPath  string
+		expected []pathSegment
+	}{
+		{"replicas", []pathSegment{{Key: "replicas"}}},
+		{"controller.image.tag", []pathSegment{
+			{Key: "controller"},
+			{Key: "image"},
+			{Key: "tag"},
+		}},
+		{`podAnnotations.example\.com/team`, []pathSegment{
+			{Key: "podAnnotations"},
+			{Key: "example.com/team"},
+		}},
+		{`labels.a\,b\[0\]`, []pathSegment{{Key: "labels"}, {Key: "a,b[0]"}}},
+		{"args[1]", []pathSegment{{Key: "args"}, {Index: 1, IsIndex: true}}},
+		{"matrix[0][2].name", []pathSegment{
+			{Key: "matrix"},
+			{Index: 0, IsIndex: true},
+			{Index: 2, IsIndex: true},
+			{Key: "name"},
+		}},
+		{"with,comma=and space", []pathSegment{{Key: "with,comma=and space"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.keyPath, func(t *testing.T) {
+			segments, err := parseValuePath(tc.keyPath)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, segments)
+		})
+	}
+}
+
+func TestParseValuePath_Errors(t *testing.T) {
+	testCases := []string{
+		``,
+		`.replicas`,
+		`image..tag`,
+		`image.`,
+		`image\`,
+		`[0]`,
+		`args[`,
+		`args[a]`,
+		`args[-1]`,
+		`args[65536]`,
+		`args[0]name`,
+	}
+
+	for _, keyPath := range testCases {
+		t.Run(keyPath, func(t *testing.T) {
+			_, err := parseValuePath(keyPath)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestAssignValuePath(t *testing.T) {
+	values := map[string]any{
+		"image": map[string]any{"tag": "1.0"},
+		"args":  []any{"--port=8080"},
+	}
+
+	assigned, err := assignValuePath(values, "image.tag", "2.0")
+	require.NoError(t, err)
+
+	assigned, err = assignValuePath(assigned, "args[2]", "--verbose")
+	require.NoError(t, err)
+
+	assigned, err = assignValuePath(assigned, "image.tag.major", int64(2))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{
+		"image": map[string]any{"tag": map[string]any{"major": int64(2)}},
+		"args":  []any{"--port=8080", nil, "--verbose"},
+	}, assigned)
+	assert.Equal(t, map[string]any{
+		"image": map[string]any{"tag": "1.0"},
+		"args":  []any{"--port=8080"},
+	}, values, "Expected values to be left unchanged")
+}
+
+func TestTypedSetValue(t *testing.T) {
+	assert.Equal(t, true, typedSetValue("true"))
+	assert.Equal(t, false, typedSetValue("FALSE"))
+	assert.Nil(t, typedSetValue("null"))
+	assert.Equal(t, int64(0), typedSetValue("0"))
+	assert.Equal(t, int64(42), typedSetValue("42"))
+	assert.Equal(t, "0755", typedSetValue("0755"))
+	assert.Equal(t, "1.5", typedSetValue("1.5"))
+	assert.Equal(t, "", typedSetValue(""))
+}
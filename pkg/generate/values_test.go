@@ -0,0 +1,119 @@
+package generate
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/komailo/kubeit/pkg/api"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+func TestValuesFromHelm(t *testing.T) {
+	loaderInt := loader.NewLoader()
+
+	helmApplication := &v1.HelmApplication{
+		BaseObject: api.BaseObject{Metadata: api.ObjectMeta{Name: "app"}},
+		Spec: v1.HelmApplicationSpec{
+			Values: []v1.ValueEntry{
+				{Type: "raw", Data: json.RawMessage(`{"image": {"tag": "1.0"}, "replicas": 2}`)},
+				{Type: "mapping", Data: json.RawMessage(`{"image.tag": "$imageTag"}`)},
+			},
+		},
+	}
+
+	generateSetOptions := &Options{
+		WorkDir:   t.TempDir(),
+		OutputDir: t.TempDir(),
+		Variables: []string{"imageTag=2.0"},
+	}
+
+	require.NoError(t, ValuesFromHelm(helmApplication, loaderInt, generateSetOptions))
+
+	values, err := os.ReadFile(filepath.Join(generateSetOptions.OutputDir, "app.values.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "image:\n  tag: \"2.0\"\nreplicas: 2\n", string(values))
+
+	generateSetOptions.ValuesFormat = ValuesFormatJSON
+	require.NoError(t, ValuesFromHelm(helmApplication, loaderInt, generateSetOptions))
+
+	values, err = os.ReadFile(filepath.Join(generateSetOptions.OutputDir, "app.values.json"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"image": {"tag": "2.0"}, "replicas": 2}`, string(values))
+
+	generateSetOptions.ValuesFormat = "toml"
+	require.Error(t, ValuesFromHelm(helmApplication, loaderInt, generateSetOptions))
+}
+
+func TestValuesFromHelm_Sensitive(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	t.Setenv(AgeKeyEnv, identity.String())
+
+	loaderInt := loader.NewLoader()
+
+	helmApplication := &v1.HelmApplication{
+		BaseObject: api.BaseObject{Metadata: api.ObjectMeta{Name: "app"}},
+		Spec: v1.HelmApplicationSpec{
+			Values: []v1.ValueEntry{
+				{
+					Type: "raw",
+					Data: json.RawMessage(`{"replicas": 2, "api": {"token": "plain-token", "timeout": 30}}`),
+				},
+				{
+					Type:      "string",
+					Data:      json.RawMessage(`{"auth.pin": "913"}`),
+					Sensitive: true,
+				},
+				{
+					Type: "encrypted",
+					Data: encryptForTest(
+						t,
+						identity.Recipient(),
+						"database:\n  password: s3cr3t\n  ports: [5432]\n",
+					),
+				},
+			},
+		},
+	}
+
+	generateSetOptions := &Options{
+		WorkDir:   t.TempDir(),
+		OutputDir: t.TempDir(),
+	}
+	valuesFile := filepath.Join(generateSetOptions.OutputDir, "app.values.yaml")
+
+	require.NoError(t, ValuesFromHelm(helmApplication, loaderInt, generateSetOptions))
+
+	values, err := os.ReadFile(valuesFile)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"api:\n  timeout: 30\n  token: '******'\nauth:\n  pin: '******'\n"+
+			"database:\n  password: '******'\n  ports:\n  - '******'\nreplicas: 2\n",
+		string(values),
+		"Expected the values of sensitive and encrypted entries and sensitive keys to be masked",
+	)
+
+	info, err := os.Stat(valuesFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	generateSetOptions.RevealSensitive = true
+	require.NoError(t, ValuesFromHelm(helmApplication, loaderInt, generateSetOptions))
+
+	values, err = os.ReadFile(valuesFile)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"api:\n  timeout: 30\n  token: plain-token\nauth:\n  pin: \"913\"\n"+
+			"database:\n  password: s3cr3t\n  ports:\n  - 5432\nreplicas: 2\n",
+		string(values),
+	)
+}
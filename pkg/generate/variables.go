@@ -0,0 +1,175 @@
+package generate
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/komailo/kubeit/internal/version"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	"github.com/komailo/kubeit/pkg/utils"
+)
+
+const (
+	dockerImageVarPrefix   = "dockerImage"
+	dockerImageEnvPrefix   = dockerImageVarPrefix + "Env."
+	dockerImageLabelPrefix = dockerImageVarPrefix + "Labels."
+	notFromDockerImage     = "!!NOT_GENERATED_FROM_DOCKER_IMAGE!!"
+)
+
+// generateVariables returns the variables that can be referenced as $VAR or ${VAR}
+// in mapping values.
+func generateVariables(loaderInt *loader.Loader) (map[string]string, error) {
+	variables := map[string]string{
+		"kubeitVersion": version.GetBuildInfo().Version,
+	}
+
+	if loaderInt.SourceMeta.Scheme != "docker" {
+		return variables, nil
+	}
+
+	dockerRepo, dockerTag, err := utils.ParseDockerImage(loaderInt.SourceMeta.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Docker image: %w", err)
+	}
+
+	variables["dockerImageRepository"] = dockerRepo
+	variables["dockerImageTag"] = dockerTag
+
+	imageVariables, err := imageMetadataVariables(loaderInt.ImageMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range imageVariables {
+		variables[key] = value
+	}
+
+	return variables, nil
+}
+
+// imageMetadataVariables flattens the image metadata into variables. List values are
+// JSON encoded and durations are expressed in whole seconds so they can be used as
+// Kubernetes probe settings.
+func imageMetadataVariables(metadata utils.ImageMetadata) (map[string]string, error) {
+	variables := map[string]string{
+		"dockerImageDigest":     metadata.Digest,
+		"dockerImageUser":       metadata.User,
+		"dockerImageWorkingDir": metadata.WorkingDir,
+	}
+
+	ports := make([]int, 0, len(metadata.ExposedPorts))
+	for _, port := range metadata.ExposedPorts {
+		ports = append(ports, port.Port)
+	}
+
+	// The ports are sorted, a port exposed for both tcp and udp is listed once
+	ports = slices.Compact(ports)
+
+	if len(ports) > 0 {
+		variables["dockerImageExposedPort"] = strconv.Itoa(ports[0])
+	}
+
+	lists := map[string]any{
+		"dockerImageEntrypoint":   nonNilStrings(metadata.Entrypoint),
+		"dockerImageCmd":          nonNilStrings(metadata.Cmd),
+		"dockerImageExposedPorts": ports,
+	}
+
+	if healthcheck := metadata.Healthcheck; healthcheck != nil && len(healthcheck.Test) > 0 &&
+		healthcheck.Test[0] != "NONE" {
+		lists["dockerImageHealthcheckTest"] = healthcheck.Test
+		lists["dockerImageHealthcheckCommand"] = healthcheckCommand(healthcheck.Test)
+
+		variables["dockerImageHealthcheckIntervalSeconds"] = durationSeconds(healthcheck.Interval)
+		variables["dockerImageHealthcheckTimeoutSeconds"] = durationSeconds(healthcheck.Timeout)
+		variables["dockerImageHealthcheckStartPeriodSeconds"] = durationSeconds(
+			healthcheck.StartPeriod,
+		)
+		variables["dockerImageHealthcheckRetries"] = strconv.Itoa(healthcheck.Retries)
+	}
+
+	for key, list := range lists {
+		encoded, err := json.Marshal(list)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", key, err)
+		}
+
+		variables[key] = string(encoded)
+	}
+
+	for key, value := range metadata.Env {
+		variables[dockerImageEnvPrefix+key] = value
+	}
+
+	for key, value := range metadata.Labels {
+		variables[dockerImageLabelPrefix+key] = value
+	}
+
+	return variables, nil
+}
+
+// healthcheckCommand converts a Docker HEALTHCHECK test into an exec command
+func healthcheckCommand(test []string) []string {
+	switch test[0] {
+	case "CMD":
+		return nonNilStrings(test[1:])
+	case "CMD-SHELL":
+		return []string{"/bin/sh", "-c", strings.Join(test[1:], " ")}
+	default:
+		return nonNilStrings(test)
+	}
+}
+
+func durationSeconds(d time.Duration) string {
+	return strconv.Itoa(int(d / time.Second))
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+
+	return s
+}
+
+// substituteVariables replaces $VAR or ${VAR} in value with the matching variable.
+// Unknown variables are left untouched, except for Docker image variables which are
+// marked as not generated when the source is not a Docker image.
+func substituteVariables(value string, variables map[string]string) string {
+	return varPattern.ReplaceAllStringFunc(value, func(match string) string {
+		// Extract variable name
+		varName := strings.TrimPrefix(strings.Trim(match, "${}"), "$")
+		if varValue, ok := variables[varName]; ok {
+			return varValue
+		}
+
+		if strings.HasPrefix(varName, dockerImageVarPrefix) {
+			if _, ok := variables["dockerImageRepository"]; !ok {
+				return "$" + varName + notFromDockerImage
+			}
+		}
+
+		// If not found, return the original match unchanged
+		return match
+	})
+}
+
+// parseVariables parses variables provided as name=value
+func parseVariables(assignments []string) (map[string]string, error) {
+	variables := make(map[string]string, len(assignments))
+
+	for _, assignment := range assignments {
+		name, value, ok := strings.Cut(assignment, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("variable %q must be in the form name=value", assignment)
+		}
+
+		variables[name] = value
+	}
+
+	return variables, nil
+}
@@ -0,0 +1,134 @@
+package generate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/komailo/kubeit/pkg/api"
+	"github.com/komailo/kubeit/pkg/api/loader"
+	"github.com/komailo/kubeit/pkg/utils"
+)
+
+func TestGenerateVariables_DockerImage(t *testing.T) {
+	loaderInt := loader.NewLoader()
+	loaderInt.SourceMeta = api.SourceMeta{
+		Scheme: "docker",
+		Source: "docker.io/library/app:1.2.3",
+	}
+	loaderInt.ImageMetadata = utils.ImageMetadata{
+		Digest:     "sha256:abc",
+		User:       "app",
+		WorkingDir: "/app",
+		Entrypoint: []string{"/app/server"},
+		Env:        map[string]string{"LOG_LEVEL": "debug"},
+		ExposedPorts: []utils.ExposedPort{
+			{Port: 8080, Protocol: "tcp"},
+			{Port: 9090, Protocol: "tcp"},
+			{Port: 9090, Protocol: "udp"},
+		},
+		Healthcheck: &container.HealthConfig{
+			Test:     []string{"CMD-SHELL", "curl -f http://localhost:8080/healthz"},
+			Interval: 30 * time.Second,
+			Timeout:  5 * time.Second,
+			Retries:  3,
+		},
+		Labels: map[string]string{"org.opencontainers.image.revision": "0123abc"},
+	}
+
+	variables, err := generateVariables(loaderInt)
+	require.NoError(t, err)
+
+	expected := map[string]string{
+		"dockerImageRepository":                               "docker.io/library/app",
+		"dockerImageTag":                                      "1.2.3",
+		"dockerImageDigest":                                   "sha256:abc",
+		"dockerImageUser":                                     "app",
+		"dockerImageWorkingDir":                               "/app",
+		"dockerImageEntrypoint":                               `["/app/server"]`,
+		"dockerImageCmd":                                      `[]`,
+		"dockerImageExposedPort":                              "8080",
+		"dockerImageExposedPorts":                             `[8080,9090]`,
+		"dockerImageEnv.LOG_LEVEL":                            "debug",
+		"dockerImageLabels.org.opencontainers.image.revision": "0123abc",
+		"dockerImageHealthcheckTest":                          `["CMD-SHELL","curl -f http://localhost:8080/healthz"]`,
+		"dockerImageHealthcheckCommand":                       `["/bin/sh","-c","curl -f http://localhost:8080/healthz"]`,
+		"dockerImageHealthcheckIntervalSeconds":               "30",
+		"dockerImageHealthcheckTimeoutSeconds":                "5",
+		"dockerImageHealthcheckStartPeriodSeconds":            "0",
+		"dockerImageHealthcheckRetries":                       "3",
+	}
+
+	for key, value := range expected {
+		assert.Equal(t, value, variables[key], "Expected variable %s mismatch", key)
+	}
+}
+
+func TestSubstituteVariables(t *testing.T) {
+	dockerVariables := map[string]string{
+		"dockerImageRepository":         "docker.io/library/app",
+		"dockerImageTag":                "1.2.3",
+		"dockerImageEnv.LOG_LEVEL":      "debug",
+		"dockerImageLabels.org.example": "label",
+	}
+	fileVariables := map[string]string{
+		"kubeitVersion": "v1.0.0",
+	}
+
+	testCases := []struct {
+		name      string
+		value     string
+		variables map[string]string
+		expected  string
+	}{
+		{
+			name:      "Plain and braced variables",
+			value:     "$dockerImageRepository:${dockerImageTag}",
+			variables: dockerVariables,
+			expected:  "docker.io/library/app:1.2.3",
+		},
+		{
+			name:      "Dotted variable names need braces",
+			value:     "${dockerImageEnv.LOG_LEVEL}/${dockerImageLabels.org.example}",
+			variables: dockerVariables,
+			expected:  "debug/label",
+		},
+		{
+			name:      "Unknown variable is left untouched",
+			value:     "${dockerImageEnv.MISSING}-$unknown",
+			variables: dockerVariables,
+			expected:  "${dockerImageEnv.MISSING}-$unknown",
+		},
+		{
+			name:      "Docker image variable outside of a Docker image",
+			value:     "${dockerImageUser}",
+			variables: fileVariables,
+			expected:  "$dockerImageUser!!NOT_GENERATED_FROM_DOCKER_IMAGE!!",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, substituteVariables(tc.value, tc.variables))
+		})
+	}
+}
+
+func TestParseVariables(t *testing.T) {
+	variables, err := parseVariables([]string{"environment=production", "query=a=b", "empty="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"environment": "production",
+		"query":       "a=b",
+		"empty":       "",
+	}, variables)
+
+	_, err = parseVariables([]string{"environment"})
+	require.Error(t, err, "Expected an error for a variable without a value")
+
+	_, err = parseVariables([]string{"=production"})
+	require.Error(t, err, "Expected an error for a variable without a name")
+}
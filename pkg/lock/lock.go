@@ -0,0 +1,174 @@
+// Package lock reads and writes kubeit.lock files. A lock file records the exact chart
+// version and archive digest each HelmApplication resolved to, so the same resources
+// always render the same manifests.
+package lock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	k8syaml "sigs.k8s.io/yaml"
+
+	"github.com/komailo/kubeit/common"
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+// FileName is the name of the lock file in the source root
+const FileName = "kubeit.lock"
+
+// Kind is the kind of a lock file
+const Kind = "Lock"
+
+// Lock pins the charts of the HelmApplications of a source
+type Lock struct {
+	APIVersion   string        `json:"apiVersion"`
+	Kind         string        `json:"kind"`
+	Applications []Application `json:"applications"`
+}
+
+// Application is the resolved chart of a HelmApplication
+type Application struct {
+	Name string `json:"name"`
+	// Chart is the chart as requested by the HelmApplication
+	Chart Chart `json:"chart"`
+	// Version is the exact version the chart resolved to
+	Version string `json:"version"`
+	// Digest is the digest of the chart archive, e.g. sha256:<hex>
+	Digest string `json:"digest"`
+}
+
+// Chart is the chart reference of a HelmApplication
+type Chart struct {
+	Repository    string `json:"repository,omitempty"`
+	RepositoryRef string `json:"repositoryRef,omitempty"`
+	Name          string `json:"name,omitempty"`
+	URL           string `json:"url,omitempty"`
+	Version       string `json:"version,omitempty"`
+}
+
+// ChartOf returns the chart reference of a chart spec
+func ChartOf(spec v1.ChartSpec) Chart {
+	return Chart{
+		Repository:    spec.Repository,
+		RepositoryRef: spec.RepositoryRef,
+		Name:          spec.Name,
+		URL:           spec.URL,
+		Version:       spec.Version,
+	}
+}
+
+// New returns an empty lock
+func New() *Lock {
+	return &Lock{APIVersion: common.APIVersionV1Alpha1, Kind: Kind}
+}
+
+// Parse decodes a lock file
+func Parse(data []byte) (*Lock, error) {
+	lockFile := New()
+
+	if err := k8syaml.UnmarshalStrict(data, lockFile); err != nil {
+		return nil, fmt.Errorf("failed to decode lock file: %w", err)
+	}
+
+	if lockFile.Kind != Kind {
+		return nil, fmt.Errorf("unexpected kind %q in lock file", lockFile.Kind)
+	}
+
+	if lockFile.APIVersion != common.APIVersionV1Alpha1 {
+		return nil, fmt.Errorf("unknown version %s of lock file", lockFile.APIVersion)
+	}
+
+	return lockFile, nil
+}
+
+// Load reads the lock file at path. A missing lock file returns nil without an error.
+func Load(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// Marshal encodes the lock file with the applications ordered by name
+func (l *Lock) Marshal() ([]byte, error) {
+	sort.Slice(l.Applications, func(i, j int) bool {
+		return l.Applications[i].Name < l.Applications[j].Name
+	})
+
+	data, err := k8syaml.Marshal(l)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode lock file: %w", err)
+	}
+
+	return data, nil
+}
+
+// Write writes the lock file to path
+func (l *Lock) Write(path string) error {
+	data, err := l.Marshal()
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+
+	return nil
+}
+
+// Find returns the locked chart of an application
+func (l *Lock) Find(name string) (Application, bool) {
+	if l == nil {
+		return Application{}, false
+	}
+
+	for _, application := range l.Applications {
+		if application.Name == name {
+			return application, true
+		}
+	}
+
+	return Application{}, false
+}
+
+// Set adds the locked chart of an application, replacing the one with the same name
+func (l *Lock) Set(application Application) {
+	for i := range l.Applications {
+		if l.Applications[i].Name == application.Name {
+			l.Applications[i] = application
+			return
+		}
+	}
+
+	l.Applications = append(l.Applications, application)
+}
+
+// Digest returns the digest of a chart archive
+func Digest(chartPath string) (string, error) {
+	file, err := os.Open(chartPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open chart archive: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read chart archive %s: %w", filepath.Base(chartPath), err)
+	}
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,125 @@
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	v1 "github.com/komailo/kubeit/pkg/api/v1"
+)
+
+func TestLock_WriteLoad(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), FileName)
+
+	lockFile := New()
+	lockFile.Set(Application{
+		Name:    "web",
+		Chart:   ChartOf(v1.ChartSpec{URL: "oci://registry.example.com/charts/web"}),
+		Version: "2.0.0",
+		Digest:  "sha256:b",
+	})
+	lockFile.Set(Application{
+		Name:    "ingress",
+		Chart:   Chart{Repository: "https://charts.example.com", Name: "ingress", Version: ">=3.7.3"},
+		Version: "3.7.3",
+		Digest:  "sha256:a",
+	})
+	lockFile.Set(Application{
+		Name:    "ingress",
+		Chart:   Chart{Repository: "https://charts.example.com", Name: "ingress", Version: ">=3.7.3"},
+		Version: "3.8.0",
+		Digest:  "sha256:c",
+	})
+
+	require.NoError(t, lockFile.Write(lockPath))
+
+	data, err := os.ReadFile(lockPath)
+	require.NoError(t, err)
+	assert.Equal(t, `apiVersion: kubeit.komailo.github.io/v1alpha1
+applications:
+- chart:
+    name: ingress
+    repository: https://charts.example.com
+    version: '>=3.7.3'
+  digest: sha256:c
+  name: ingress
+  version: 3.8.0
+- chart:
+    url: oci://registry.example.com/charts/web
+  digest: sha256:b
+  name: web
+  version: 2.0.0
+kind: Lock
+`, string(data))
+
+	loaded, err := Load(lockPath)
+	require.NoError(t, err)
+	assert.Equal(t, lockFile, loaded)
+
+	application, ok := loaded.Find("web")
+	assert.True(t, ok)
+	assert.Equal(t, "2.0.0", application.Version)
+
+	_, ok = loaded.Find("missing")
+	assert.False(t, ok)
+}
+
+func TestLoad(t *testing.T) {
+	lockDir := t.TempDir()
+
+	lockFile, err := Load(filepath.Join(lockDir, FileName))
+	require.NoError(t, err)
+	assert.Nil(t, lockFile)
+
+	_, ok := lockFile.Find("web")
+	assert.False(t, ok)
+
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "unknown kind",
+			content: "apiVersion: kubeit.komailo.github.io/v1alpha1\nkind: HelmApplication\n",
+			wantErr: `unexpected kind "HelmApplication" in lock file`,
+		},
+		{
+			name:    "unknown version",
+			content: "apiVersion: kubeit.komailo.github.io/v2\nkind: Lock\n",
+			wantErr: "unknown version kubeit.komailo.github.io/v2 of lock file",
+		},
+		{
+			name:    "unknown field",
+			content: "apiVersion: kubeit.komailo.github.io/v1alpha1\nkind: Lock\nextra: true\n",
+			wantErr: "failed to decode lock file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lockPath := filepath.Join(t.TempDir(), FileName)
+			require.NoError(t, os.WriteFile(lockPath, []byte(tt.content), 0o644))
+
+			_, err := Load(lockPath)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestDigest(t *testing.T) {
+	chartPath := filepath.Join(t.TempDir(), "chart.tgz")
+	require.NoError(t, os.WriteFile(chartPath, []byte("chart"), 0o644))
+
+	digest, err := Digest(chartPath)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"sha256:cc57fc1903e444cf6a726490b43b27ee9f87facc037f86872201847c565b45fb",
+		digest,
+	)
+}
@@ -6,7 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
 
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/client"
 
@@ -133,3 +136,75 @@ func CheckDockerImageExists(
 
 	return true, nil
 }
+
+// ImageMetadata holds the parts of a Docker image configuration that Kubeit exposes
+// as variables while generating Helm values.
+type ImageMetadata struct {
+	Digest       string
+	User         string
+	WorkingDir   string
+	Entrypoint   []string
+	Cmd          []string
+	Env          map[string]string
+	ExposedPorts []ExposedPort
+	Healthcheck  *container.HealthConfig
+	Labels       map[string]string
+}
+
+// ExposedPort is a port declared with EXPOSE in the image
+type ExposedPort struct {
+	Port     int
+	Protocol string
+}
+
+// ImageMetadataFromInspect extracts the ImageMetadata from the result of an image inspect.
+// Exposed ports are sorted by port number and protocol so the result is stable.
+func ImageMetadataFromInspect(inspect image.InspectResponse) ImageMetadata {
+	metadata := ImageMetadata{
+		Env:    map[string]string{},
+		Labels: map[string]string{},
+	}
+
+	if len(inspect.RepoDigests) > 0 {
+		if _, digest, found := strings.Cut(inspect.RepoDigests[0], "@"); found {
+			metadata.Digest = digest
+		}
+	}
+
+	config := inspect.Config
+	if config == nil {
+		return metadata
+	}
+
+	metadata.User = config.User
+	metadata.WorkingDir = config.WorkingDir
+	metadata.Entrypoint = config.Entrypoint
+	metadata.Cmd = config.Cmd
+	metadata.Healthcheck = config.Healthcheck
+
+	for _, env := range config.Env {
+		key, value, _ := strings.Cut(env, "=")
+		metadata.Env[key] = value
+	}
+
+	for key, value := range config.Labels {
+		metadata.Labels[key] = value
+	}
+
+	for port := range config.ExposedPorts {
+		metadata.ExposedPorts = append(metadata.ExposedPorts, ExposedPort{
+			Port:     port.Int(),
+			Protocol: port.Proto(),
+		})
+	}
+
+	sort.Slice(metadata.ExposedPorts, func(i, j int) bool {
+		if metadata.ExposedPorts[i].Port != metadata.ExposedPorts[j].Port {
+			return metadata.ExposedPorts[i].Port < metadata.ExposedPorts[j].Port
+		}
+
+		return metadata.ExposedPorts[i].Protocol < metadata.ExposedPorts[j].Protocol
+	})
+
+	return metadata
+}
@@ -5,9 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/image"
+	"github.com/docker/go-connections/nat"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -117,3 +120,57 @@ func TestRealDockerClient_ImagePull(t *testing.T) {
 		"Expected error message to contain 'failed to pull image'",
 	)
 }
+
+func TestImageMetadataFromInspect(t *testing.T) {
+	inspect := image.InspectResponse{
+		RepoDigests: []string{"docker.io/library/app@sha256:abc"},
+		Config: &container.Config{
+			User:       "1000:1000",
+			WorkingDir: "/app",
+			Entrypoint: []string{"/app/server"},
+			Cmd:        []string{"--port", "8080"},
+			Env:        []string{"PATH=/usr/bin", "EMPTY=", "WITH_EQUALS=a=b"},
+			ExposedPorts: nat.PortSet{
+				"9090/tcp": {},
+				"8080/udp": {},
+				"8080/tcp": {},
+			},
+			Healthcheck: &container.HealthConfig{
+				Test:     []string{"CMD", "curl", "-f", "http://localhost:8080"},
+				Interval: 30 * time.Second,
+			},
+			Labels: map[string]string{
+				"org.opencontainers.image.revision": "0123abc",
+			},
+		},
+	}
+
+	metadata := ImageMetadataFromInspect(inspect)
+
+	assert.Equal(t, "sha256:abc", metadata.Digest)
+	assert.Equal(t, "1000:1000", metadata.User)
+	assert.Equal(t, "/app", metadata.WorkingDir)
+	assert.Equal(t, []string{"/app/server"}, metadata.Entrypoint)
+	assert.Equal(t, []string{"--port", "8080"}, metadata.Cmd)
+	assert.Equal(
+		t,
+		map[string]string{"PATH": "/usr/bin", "EMPTY": "", "WITH_EQUALS": "a=b"},
+		metadata.Env,
+	)
+	assert.Equal(t, []ExposedPort{
+		{Port: 8080, Protocol: "tcp"},
+		{Port: 8080, Protocol: "udp"},
+		{Port: 9090, Protocol: "tcp"},
+	}, metadata.ExposedPorts)
+	assert.Equal(t, inspect.Config.Healthcheck, metadata.Healthcheck)
+	assert.Equal(t, "0123abc", metadata.Labels["org.opencontainers.image.revision"])
+}
+
+func TestImageMetadataFromInspect_NoConfig(t *testing.T) {
+	metadata := ImageMetadataFromInspect(image.InspectResponse{})
+
+	assert.Empty(t, metadata.Digest)
+	assert.Empty(t, metadata.Env)
+	assert.Empty(t, metadata.ExposedPorts)
+	assert.Nil(t, metadata.Healthcheck)
+}